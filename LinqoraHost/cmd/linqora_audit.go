@@ -0,0 +1,145 @@
+package main
+
+import (
+	"LinqoraHost/internal/audit"
+	"LinqoraHost/internal/config"
+	"LinqoraHost/internal/ws"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// auditPath is where the audit log is kept, next to the config file.
+func auditPath() string {
+	return config.DataPath("audit.log")
+}
+
+// startAudit records the server's commands in the audit log until ctx is
+// done. Failing to open the log is reported but does not stop the server.
+func startAudit(ctx context.Context, server *ws.WSServer) {
+	l, err := audit.Open(auditPath(), int64(cfg.AuditMaxSizeMB)<<20, cfg.AuditKeep)
+	if err != nil {
+		slog.Error("Audit log unavailable, commands are not recorded", "err", err)
+		return
+	}
+	server.SetAuditLog(l)
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+}
+
+var (
+	auditDevice string
+	auditType   string
+	auditSince  string
+	auditUntil  string
+	auditLimit  int
+	auditJSON   bool
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Show the audit log of remote commands",
+	Long:  "Show the audit log of remote commands, newest last. --since and --until take a time (2006-01-02 or RFC 3339) or a duration back from now, such as 24h.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filter := audit.Filter{Device: auditDevice, Type: auditType}
+		var err error
+		if filter.Since, err = parseAuditTime(auditSince); err != nil {
+			return err
+		}
+		if filter.Until, err = parseAuditTime(auditUntil); err != nil {
+			return err
+		}
+
+		entries, err := audit.Read(auditPath(), filter)
+		if err != nil {
+			return err
+		}
+		if auditLimit > 0 && len(entries) > auditLimit {
+			entries = entries[len(entries)-auditLimit:]
+		}
+
+		if auditJSON {
+			enc := json.NewEncoder(os.Stdout)
+			for _, e := range entries {
+				enc.Encode(e)
+			}
+			return nil
+		}
+		if len(entries) == 0 {
+			fmt.Println("No matching entries.")
+			return nil
+		}
+		fmt.Printf("%-19s  %-20s  %-21s  %-22s  %-9s  %7s  %s\n", "Time", "Device", "IP", "Type", "Outcome", "ms", "Params")
+		fmt.Println(strings.Repeat("-", 130))
+		for _, e := range entries {
+			outcome := e.Outcome
+			if e.Code != 0 {
+				outcome = fmt.Sprintf("%s %d", e.Outcome, e.Code)
+			}
+			device := e.DeviceName
+			if device == "" {
+				device = e.DeviceID
+			}
+			fmt.Printf("%-19s  %-20s  %-21s  %-22s  %-9s  %7d  %s\n", e.Time.Local().Format("2006-01-02 15:04:05"),
+				device, e.IP, e.Type, outcome, e.DurationMs, string(e.Params))
+		}
+		return nil
+	},
+}
+
+var auditVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check that no audit entry was changed or removed",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		result, err := audit.Verify(auditPath())
+		if err != nil {
+			return fmt.Errorf("audit log is NOT intact: %w", err)
+		}
+		if result.Entries == 0 {
+			fmt.Println("The audit log is empty.")
+			return nil
+		}
+		fmt.Printf("Audit log intact: %d entries, %d to %d.\n", result.Entries, result.FirstSeq, result.LastSeq)
+		if result.FirstSeq > 1 {
+			fmt.Println("Older entries were rotated away; the chain is checked from the oldest kept file.")
+		}
+		return nil
+	},
+}
+
+// parseAuditTime reads a --since or --until value.
+func parseAuditTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: use 2006-01-02, RFC 3339 or a duration such as 24h", value)
+}
+
+func init() {
+	auditCmd.Flags().StringVar(&auditDevice, "device", "", "Only this device ID or name")
+	auditCmd.Flags().StringVar(&auditType, "type", "", "Only this message type, or REST call such as \"POST /api/v1/power\"")
+	auditCmd.Flags().StringVar(&auditSince, "since", "", "Only entries from this time on")
+	auditCmd.Flags().StringVar(&auditUntil, "until", "", "Only entries up to this time")
+	auditCmd.Flags().IntVarP(&auditLimit, "lines", "n", 50, "Show at most this many of the newest entries (0 for all)")
+	auditCmd.Flags().BoolVar(&auditJSON, "json", false, "Print entries as JSON lines")
+
+	auditCmd.AddCommand(auditVerifyCmd)
+	rootCmd.AddCommand(auditCmd)
+}
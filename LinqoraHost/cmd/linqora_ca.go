@@ -0,0 +1,50 @@
+package main
+
+import (
+	"LinqoraHost/internal/ca"
+	"LinqoraHost/internal/config"
+	"LinqoraHost/internal/ws"
+	"fmt"
+	"log/slog"
+
+	"github.com/spf13/cobra"
+)
+
+// caDir is where the host's certificate authority is kept, next to the
+// config file.
+func caDir() string {
+	return config.DataPath("ca")
+}
+
+// startCA lets the server issue and accept client certificates. They need
+// TLS; without it, or if the CA cannot be opened, devices keep to the HMAC
+// challenge.
+func startCA(server *ws.WSServer) {
+	if !cfg.EnableTLS {
+		return
+	}
+	authority, err := ca.Open(caDir())
+	if err != nil {
+		slog.Error("Certificate authority unavailable, client certificates are off", "err", err)
+		return
+	}
+	authManager.SetCA(authority)
+	server.SetCA(authority)
+}
+
+var caCmd = &cobra.Command{
+	Use:   "ca",
+	Short: "Print the CA certificate that signs device client certificates",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		authority, err := ca.Open(caDir())
+		if err != nil {
+			return err
+		}
+		fmt.Print(authority.CertPEM())
+		return nil
+	},
+}
+
+func init() {
+	authCmd.AddCommand(caCmd)
+}
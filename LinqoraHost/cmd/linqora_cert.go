@@ -0,0 +1,164 @@
+package main
+
+import (
+	"LinqoraHost/internal/certutils"
+	"LinqoraHost/internal/config"
+	"LinqoraHost/internal/control"
+	"LinqoraHost/internal/events"
+	"LinqoraHost/internal/mdns"
+	"LinqoraHost/internal/ws"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// setupTLS picks the certificate the server uses: certFile and keyFile when
+// given, otherwise this host's own, generated on first run. It returns the
+// paths and whether TLS can be enabled with them.
+func setupTLS(certFile, keyFile string) (string, string, bool) {
+	if certFile == "" && keyFile == "" {
+		certFile, keyFile = certutils.HostCertPaths()
+		generated, err := certutils.EnsureHostCert(certFile, keyFile)
+		if err != nil {
+			fmt.Printf("Error generating a TLS certificate: %v\n", err)
+			return certFile, keyFile, false
+		}
+		if generated {
+			fmt.Printf("Generated a TLS certificate for this host: %s\n", certFile)
+		}
+	} else if !certutils.UserCertExists(certFile, keyFile) {
+		fmt.Printf("TLS certificate or key not found (%s, %s)\n", certFile, keyFile)
+		return certFile, keyFile, false
+	}
+	return certFile, keyFile, certutils.IsValidCertificate(certFile)
+}
+
+var certCmd = &cobra.Command{
+	Use:   "cert",
+	Short: "Inspect or replace the host's TLS certificate",
+}
+
+var certShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the TLS certificate and the fingerprint apps pin",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		certFile, _ := certutils.HostCertPaths()
+		if configured := configuredCertFile(); configured != "" {
+			certFile = configured
+		}
+		cert, err := certutils.LoadCertificate(certFile)
+		if err != nil {
+			return err
+		}
+		fp, err := certutils.Fingerprint(certFile)
+		if err != nil {
+			return err
+		}
+		names := append([]string(nil), cert.DNSNames...)
+		for _, ip := range cert.IPAddresses {
+			names = append(names, ip.String())
+		}
+		fmt.Printf("File:        %s\n", certFile)
+		fmt.Printf("Subject:     %s\n", cert.Subject)
+		fmt.Printf("Names:       %s\n", strings.Join(names, ", "))
+		fmt.Printf("Valid:       %s to %s\n", cert.NotBefore.Format("2006-01-02"), cert.NotAfter.Format("2006-01-02"))
+		if time.Now().After(cert.NotAfter) {
+			fmt.Println("             (expired)")
+		}
+		fmt.Printf("Generated:   %t\n", certutils.IsHostCert(cert))
+		fmt.Printf("SHA-256:     %s\n", certutils.FormatFingerprint(fp))
+		return nil
+	},
+}
+
+var certRegenerateCmd = &cobra.Command{
+	Use:   "regenerate",
+	Short: "Replace the host's generated certificate with a new key and certificate",
+	Long: "Generate a new key and self-signed certificate for this host, with its current " +
+		"addresses and names. Apps that pinned the old fingerprint must pair again or scan the new QR code.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		certFile, keyFile := certutils.HostCertPaths()
+		if configured := configuredCertFile(); configured != "" && configured != certFile {
+			fmt.Printf("Note: the host is configured to serve %s, not the generated certificate.\n", configured)
+		}
+		if err := certutils.GenerateHostCert(certFile, keyFile); err != nil {
+			return err
+		}
+		fp, err := certutils.Fingerprint(certFile)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("New certificate written to %s\n", certFile)
+		fmt.Printf("SHA-256: %s\n", certutils.FormatFingerprint(fp))
+		reloadRunningCertificate()
+		fmt.Println("Apps that pinned the old fingerprint must scan the new QR code.")
+		return nil
+	},
+}
+
+var certReloadCmd = &cobra.Command{
+	Use:   "reload",
+	Short: "Make the running host serve the certificate files again",
+	Long: "The host watches its certificate and key and reloads them when they change. " +
+		"This reloads them at once. Connected clients stay connected.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var result control.CertReloadResult
+		if err := control.Call(control.CmdCertReload, nil, &result); err != nil {
+			if errors.Is(err, control.ErrNotRunning) {
+				fmt.Println("The host is not running; the certificate is read when it starts.")
+				return nil
+			}
+			return err
+		}
+		printCertReload(result)
+		return nil
+	},
+}
+
+// reloadRunningCertificate asks a running host to serve the certificate a
+// CLI command has just written. Without a running host it does nothing.
+func reloadRunningCertificate() {
+	var result control.CertReloadResult
+	err := control.Call(control.CmdCertReload, nil, &result)
+	if errors.Is(err, control.ErrNotRunning) {
+		return
+	}
+	if err != nil {
+		fmt.Printf("Warning: the running host did not reload its certificate: %v\n", err)
+		return
+	}
+	printCertReload(result)
+}
+
+func printCertReload(result control.CertReloadResult) {
+	fmt.Printf("Running host now serves %s (expires %s).\n",
+		certutils.FormatFingerprint(result.Fingerprint), result.NotAfter.Local().Format("2006-01-02"))
+}
+
+// followCertificate keeps the fingerprint announced over mDNS in step with
+// the certificate server serves after a reload.
+func followCertificate(server *ws.WSServer, announcer *mdns.MDNSServer) {
+	events.Subscribe(server.Events(), ws.CertificateTopic, func(status certutils.CertStatus) {
+		announcer.SetFingerprint(status.Fingerprint)
+	})
+}
+
+// configuredCertFile returns the certificate the saved configuration
+// serves, or "" when there is none.
+func configuredCertFile() string {
+	loaded, err := config.LoadConfig()
+	if err != nil {
+		return ""
+	}
+	return loaded.CertFile
+}
+
+func init() {
+	certCmd.AddCommand(certShowCmd)
+	certCmd.AddCommand(certRegenerateCmd)
+	certCmd.AddCommand(certReloadCmd)
+	rootCmd.AddCommand(certCmd)
+}
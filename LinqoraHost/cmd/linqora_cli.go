@@ -2,9 +2,10 @@ package main
 
 import (
 	"LinqoraHost/internal/auth"
-	"LinqoraHost/internal/certutils"
 	"LinqoraHost/internal/config"
+	"LinqoraHost/internal/control"
 	"LinqoraHost/internal/deviceinfo"
+	"LinqoraHost/internal/events"
 	"LinqoraHost/internal/interfaces"
 	"LinqoraHost/internal/mdns"
 	"LinqoraHost/internal/ws"
@@ -13,7 +14,9 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -34,6 +37,7 @@ var (
 	stopOnce       sync.Once // prevents double-close panic on stopCh
 	restart        = make(chan struct{})
 	serverMu       sync.Mutex
+	activeServer   *ws.WSServer // guarded by serverMu
 	cfg            *config.ServerConfig
 	consoleHandler *auth.ConsoleAuthHandler
 
@@ -77,7 +81,7 @@ var configShowCmd = &cobra.Command{
 var configSetCmd = &cobra.Command{
 	Use:   "set <key> <value>",
 	Short: "Update a configuration value",
-	Long:  "Supported keys: port, e2ee (true/false), shared_secret",
+	Long:  "Supported keys: port, e2ee (true/false), require_key_exchange (true/false), shared_secret, confirm_actions (" + strings.Join(config.ConfirmActions, ",") + ", all or none), confirm_timeout (seconds), shell_exec (on/off), allow_cidrs and deny_cidrs (comma-separated networks, or none), max_conns_per_ip, max_conns (0 for the default), step_up_types (" + strings.Join(config.StepUpTypes, ",") + ", all or none), step_up_grace (seconds)",
 	Args:  cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		key := strings.ToLower(args[0])
@@ -96,8 +100,61 @@ var configSetCmd = &cobra.Command{
 			}
 		case "e2ee":
 			cfg.EnableE2EE = (value == "true" || value == "1" || value == "yes")
+		case "require_key_exchange":
+			cfg.RequireKeyExchange = (value == "true" || value == "1" || value == "yes")
 		case "shared_secret":
 			cfg.SharedSecret = value
+		case "confirm_actions":
+			actions, err := config.ParseConfirmActions(value)
+			if err != nil {
+				return err
+			}
+			cfg.ConfirmActions = actions
+		case "shell_exec":
+			switch value {
+			case "on", "true", "yes":
+				cfg.ExecPolicy.DisableShell = false
+			case "off", "false", "no":
+				cfg.ExecPolicy.DisableShell = true
+			default:
+				return fmt.Errorf("shell_exec must be on or off")
+			}
+		case "allow_cidrs", "deny_cidrs":
+			list, err := config.ParseCIDRList(value)
+			if err != nil {
+				return err
+			}
+			if key == "allow_cidrs" {
+				cfg.AllowCIDRs = list
+			} else {
+				cfg.DenyCIDRs = list
+			}
+		case "max_conns_per_ip", "max_conns":
+			var n int
+			if p, err := fmt.Sscanf(value, "%d", &n); err != nil || p != 1 || n < 0 {
+				return fmt.Errorf("invalid limit: %s", value)
+			}
+			if key == "max_conns_per_ip" {
+				cfg.MaxConnsPerIP = n
+			} else {
+				cfg.MaxConns = n
+			}
+		case "confirm_timeout":
+			p, err := fmt.Sscanf(value, "%d", &cfg.ConfirmTimeout)
+			if err != nil || p != 1 || cfg.ConfirmTimeout < 0 {
+				return fmt.Errorf("invalid timeout: %s", value)
+			}
+		case "step_up_types":
+			types, err := config.ParseStepUpTypes(value)
+			if err != nil {
+				return err
+			}
+			cfg.StepUpTypes = types
+		case "step_up_grace":
+			p, err := fmt.Sscanf(value, "%d", &cfg.StepUpGrace)
+			if err != nil || p != 1 || cfg.StepUpGrace < 0 {
+				return fmt.Errorf("invalid grace period: %s", value)
+			}
 		default:
 			return fmt.Errorf("unsupported configuration key: %s", key)
 		}
@@ -106,6 +163,7 @@ var configSetCmd = &cobra.Command{
 			return err
 		}
 		fmt.Printf("Config %s updated to %s\n", key, value)
+		reloadRunningServer()
 		return nil
 	},
 }
@@ -122,10 +180,25 @@ var deviceListCmd = &cobra.Command{
 			fmt.Println("No authorized devices.")
 			return nil
 		}
-		fmt.Printf("%-36s  %-24s  %s\n", "Device ID", "Name", "Last Auth")
-		fmt.Println(strings.Repeat("-", 80))
+		fmt.Printf("%-36s  %-24s  %-20s  %-14s  %s\n", "Device ID", "Name", "Last Auth", "Expires", "Scopes")
+		fmt.Println(strings.Repeat("-", 116))
+		now := time.Now()
 		for _, d := range cfg.AuthorizedDevs {
-			fmt.Printf("%-36s  %-24s  %s\n", d.DeviceID, d.DeviceName, d.LastAuth)
+			expires := d.RemainingString(now)
+			if expires == "" {
+				expires = "never"
+			}
+			scopes := d.ScopesString()
+			if d.Admin {
+				scopes += " (admin)"
+			}
+			if d.CertSerial != "" {
+				scopes += " (cert)"
+			}
+			if d.StepUpKey != "" {
+				scopes += " (pin)"
+			}
+			fmt.Printf("%-36s  %-24s  %-20s  %-14s  %s\n", d.DeviceID, d.DeviceName, d.LastAuth, expires, scopes)
 		}
 		return nil
 	},
@@ -134,17 +207,29 @@ var deviceListCmd = &cobra.Command{
 var deviceRevokeCmd = &cobra.Command{
 	Use:   "revoke <device-id>",
 	Short: "Revoke authorization for a device",
-	Args:  cobra.ExactArgs(1),
+	Long: "Revoke authorization for a device. A running host drops the device's connections " +
+		"at once; otherwise the config file is edited.",
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		deviceID := args[0]
+
+		var result control.RevokeResult
+		err := control.Call(control.CmdRevoke, control.RevokeArgs{DeviceID: deviceID}, &result)
+		if err == nil {
+			fmt.Printf("Device %q (%s) revoked; %d connection(s) closed.\n", deviceID, result.DeviceName, result.Disconnected)
+			return nil
+		}
+		if !errors.Is(err, control.ErrNotRunning) {
+			return err
+		}
+
 		cfg, err := config.LoadConfig()
 		if err != nil {
 			return fmt.Errorf("failed to load config: %w", err)
 		}
-		if _, ok := cfg.AuthorizedDevs[deviceID]; !ok {
+		if _, ok := cfg.RevokeDevice(deviceID); !ok {
 			return fmt.Errorf("device %q not found in authorized devices", deviceID)
 		}
-		delete(cfg.AuthorizedDevs, deviceID)
 		if err := cfg.SaveConfig(); err != nil {
 			return fmt.Errorf("failed to save config: %w", err)
 		}
@@ -153,6 +238,242 @@ var deviceRevokeCmd = &cobra.Command{
 	},
 }
 
+var deviceScopesCmd = &cobra.Command{
+	Use:   "scopes <device-id> <scope,...|all>",
+	Short: "Set the permission scopes granted to a device",
+	Long:  "Set the permission scopes granted to a device. Valid scopes: " + strings.Join(config.AllScopes, ", "),
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		deviceID := args[0]
+		scopes, err := config.ParseScopes(args[1])
+		if err != nil {
+			return err
+		}
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		device, ok := cfg.AuthorizedDevs[deviceID]
+		if !ok {
+			return fmt.Errorf("device %q not found in authorized devices", deviceID)
+		}
+		device.Scopes = scopes
+		cfg.AuthorizedDevs[deviceID] = device
+		if err := cfg.SaveConfig(); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+		fmt.Printf("Device %q scopes set to %s.\n", deviceID, device.ScopesString())
+		reloadRunningServer()
+		return nil
+	},
+}
+
+var deviceAdminCmd = &cobra.Command{
+	Use:   "admin <device-id> <on|off>",
+	Short: "Allow or stop a device from approving other devices",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		deviceID := args[0]
+		var admin bool
+		switch strings.ToLower(args[1]) {
+		case "on", "true", "yes":
+			admin = true
+		case "off", "false", "no":
+		default:
+			return fmt.Errorf("expected on or off, got %q", args[1])
+		}
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		device, ok := cfg.AuthorizedDevs[deviceID]
+		if !ok {
+			return fmt.Errorf("device %q not found in authorized devices", deviceID)
+		}
+		device.Admin = admin
+		cfg.AuthorizedDevs[deviceID] = device
+		if err := cfg.SaveConfig(); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+		if admin {
+			fmt.Printf("Device %q can now approve other devices.\n", deviceID)
+		} else {
+			fmt.Printf("Device %q is no longer an admin.\n", deviceID)
+		}
+		reloadRunningServer()
+		return nil
+	},
+}
+
+var deviceResetPINCmd = &cobra.Command{
+	Use:   "reset-pin <device-id>",
+	Short: "Forget a device's step-up PIN",
+	Long: "Forget the PIN a device answers step-up challenges with, for example when its " +
+		"user forgot it. The device then steps up with the shared secret, or sets a new PIN " +
+		"that has to be allowed at the host.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		deviceID := args[0]
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		device, ok := cfg.AuthorizedDevs[deviceID]
+		if !ok {
+			return fmt.Errorf("device %q not found in authorized devices", deviceID)
+		}
+		if device.StepUpKey == "" {
+			fmt.Printf("Device %q has no PIN set.\n", deviceID)
+			return nil
+		}
+		device.StepUpKey = ""
+		cfg.AuthorizedDevs[deviceID] = device
+		if err := cfg.SaveConfig(); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+		fmt.Printf("Device %q PIN cleared.\n", deviceID)
+		reloadRunningServer()
+		return nil
+	},
+}
+
+var (
+	pairScopes  string
+	pairExpires string
+)
+
+var pairCmd = &cobra.Command{
+	Use:   "pair",
+	Short: "Show a one-time code to pair a new device",
+	Long: "Issue a 6-digit pairing code for the running host. Enter it on the phone to " +
+		"authorize the device without approving it at the console. Valid scopes: " + strings.Join(config.AllScopes, ", "),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		scopes, err := config.ParseScopes(pairScopes)
+		if err != nil {
+			return err
+		}
+		code, expires, err := auth.StartPairing(scopes, pairExpires)
+		if err != nil {
+			return fmt.Errorf("failed to start pairing: %w", err)
+		}
+		printPairingCode(code, expires)
+		return nil
+	},
+}
+
+// printPairingCode shows a pairing code and when it expires.
+func printPairingCode(code string, expires time.Time) {
+	fmt.Printf("\nPairing code:  %s %s\n", code[:3], code[3:])
+	fmt.Printf("Enter it in Linqora Remote before %s. It can be used once.\n\n", expires.Format("15:04:05"))
+}
+
+var (
+	tokenCmd = &cobra.Command{
+		Use:   "token",
+		Short: "Manage REST API tokens",
+	}
+
+	tokenScopes  string
+	tokenExpires time.Duration
+)
+
+var tokenCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a named REST API token",
+	Long:  "Create a named REST API token. Valid scopes: " + strings.Join(config.TokenScopes, ", ") + ", " + config.ScopeAdmin + ` ("all" grants every scope except admin)`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		scopes, err := config.ParseTokenScopes(tokenScopes)
+		if err != nil {
+			return err
+		}
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		if cfg.FindToken(name) >= 0 {
+			return fmt.Errorf("token %q already exists", name)
+		}
+
+		token, hash, err := config.GenerateToken()
+		if err != nil {
+			return fmt.Errorf("failed to generate token: %w", err)
+		}
+		entry := config.APIToken{Name: name, Hash: hash, Scopes: scopes, CreatedAt: time.Now()}
+		if tokenExpires > 0 {
+			expires := entry.CreatedAt.Add(tokenExpires)
+			entry.ExpiresAt = &expires
+		}
+		cfg.APITokens = append(cfg.APITokens, entry)
+		if err := cfg.SaveConfig(); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+
+		fmt.Printf("Token %q created with scopes %s.\n", name, entry.ScopesString())
+		fmt.Println("Store it now, it will not be shown again:")
+		fmt.Println(token)
+		reloadRunningServer()
+		return nil
+	},
+}
+
+var tokenListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List REST API tokens",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		if len(cfg.APITokens) == 0 {
+			fmt.Println("No API tokens.")
+			return nil
+		}
+		const layout = "2006-01-02 15:04"
+		fmt.Printf("%-20s  %-16s  %-16s  %-16s  %s\n", "Name", "Created", "Expires", "Last Used", "Scopes")
+		fmt.Println(strings.Repeat("-", 100))
+		for _, t := range cfg.APITokens {
+			expires, lastUsed := "never", "never"
+			if t.ExpiresAt != nil {
+				expires = t.ExpiresAt.Format(layout)
+				if t.Expired(time.Now()) {
+					expires += " (expired)"
+				}
+			}
+			if t.LastUsed != nil {
+				lastUsed = t.LastUsed.Format(layout)
+			}
+			fmt.Printf("%-20s  %-16s  %-16s  %-16s  %s\n", t.Name, t.CreatedAt.Format(layout), expires, lastUsed, t.ScopesString())
+		}
+		return nil
+	},
+}
+
+var tokenRevokeCmd = &cobra.Command{
+	Use:   "revoke <name>",
+	Short: "Revoke a REST API token",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		i := cfg.FindToken(name)
+		if i < 0 {
+			return fmt.Errorf("token %q not found", name)
+		}
+		cfg.APITokens = append(cfg.APITokens[:i], cfg.APITokens[i+1:]...)
+		if err := cfg.SaveConfig(); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+		fmt.Printf("Token %q revoked successfully.\n", name)
+		reloadRunningServer()
+		return nil
+	},
+}
+
 var genSecretCmd = &cobra.Command{
 	Use:   "gen-secret",
 	Short: "Generate a new shared secret",
@@ -173,6 +494,7 @@ var genSecretCmd = &cobra.Command{
 		}
 
 		fmt.Printf("New shared secret written to config:\n%s\n", secret)
+		reloadRunningServer()
 		return nil
 	},
 }
@@ -180,14 +502,28 @@ var genSecretCmd = &cobra.Command{
 func init() {
 	serveCmd.Flags().IntVarP(&port, "port", "p", 0, "Port for LinqoraHost server (overrides config)")
 	serveCmd.Flags().BoolP("notls", "s", false, "Disable TLS/SSL for LinqoraHost server")
-	serveCmd.Flags().String("cert", "./certificates/dev_cert.pem", "Path to the TLS certificate file")
-	serveCmd.Flags().String("key", "./certificates/dev_key.pem", "Path to the TLS key file")
+	serveCmd.Flags().String("cert", "", "Path to the TLS certificate file (default: this host's generated certificate)")
+	serveCmd.Flags().String("key", "", "Path to the TLS key file (default: this host's generated key)")
 
 	rootCmd.PersistentFlags().Bool("headless", false, "Run in headless server mode without GUI")
 
 	authCmd.AddCommand(deviceListCmd)
 	authCmd.AddCommand(deviceRevokeCmd)
+	authCmd.AddCommand(deviceScopesCmd)
+	authCmd.AddCommand(deviceAdminCmd)
+	authCmd.AddCommand(deviceResetPINCmd)
 	authCmd.AddCommand(genSecretCmd)
+	authCmd.AddCommand(tokenCmd)
+	authCmd.AddCommand(pairCmd)
+
+	pairCmd.Flags().StringVar(&pairScopes, "scopes", "all", "Comma-separated scopes granted to the paired device")
+	pairCmd.Flags().StringVar(&pairExpires, "expires", "never", "How long the paired device stays authorized: "+config.ExpiryChoices)
+
+	tokenCreateCmd.Flags().StringVar(&tokenScopes, "scopes", config.ScopeMetrics, "Comma-separated scopes, or \"all\"")
+	tokenCreateCmd.Flags().DurationVar(&tokenExpires, "expires", 0, "Lifetime of the token, e.g. 720h (default: no expiry)")
+	tokenCmd.AddCommand(tokenCreateCmd)
+	tokenCmd.AddCommand(tokenListCmd)
+	tokenCmd.AddCommand(tokenRevokeCmd)
 
 	configCmd.AddCommand(configShowCmd)
 	configCmd.AddCommand(configSetCmd)
@@ -197,6 +533,20 @@ func init() {
 	rootCmd.AddCommand(configCmd)
 }
 
+// setActiveServer records the running server, or nil once it stops.
+func setActiveServer(server *ws.WSServer) {
+	serverMu.Lock()
+	defer serverMu.Unlock()
+	activeServer = server
+}
+
+// runningServer returns the running server, or nil.
+func runningServer() *ws.WSServer {
+	serverMu.Lock()
+	defer serverMu.Unlock()
+	return activeServer
+}
+
 // safeCloseStop closes stopCh exactly once; subsequent calls are no-ops.
 func safeCloseStop() {
 	stopOnce.Do(func() { close(stopCh) })
@@ -223,14 +573,34 @@ func startCommandProcessor() {
 // handleCommand обробляє команди користувача
 func handleCommand(command string) {
 	auth.ConsoleMutex.Lock()
-	fmt.Printf("command> %s\n", command)
-	auth.ConsoleMutex.Unlock()
+	defer auth.ConsoleMutex.Unlock()
+
+	fields := strings.Fields(strings.ToLower(command))
+	if len(fields) > 0 && (fields[0] == "allow" || fields[0] == "deny") {
+		if server := runningServer(); server != nil {
+			answerConfirmation(server.Confirmations(), fields)
+			return
+		}
+	}
+
+	switch strings.TrimSpace(strings.ToLower(command)) {
+	case "pair":
+		code, expires, err := auth.StartPairing(config.AllScopes, "")
+		if err != nil {
+			fmt.Printf("Failed to start pairing: %v\n", err)
+			return
+		}
+		printPairingCode(code, expires)
+	default:
+		fmt.Printf("command> %s\n", command)
+	}
 }
 
 // gracefulShutdown handles stopping the server cleanly
 func gracefulShutdown(cancel context.CancelFunc) {
 	fmt.Println("Stopping server...")
 	cancel()
+	setActiveServer(nil)
 
 	// Stop the mDNS server
 	if mdnsServer != nil {
@@ -277,22 +647,11 @@ func runServer(cmd *cobra.Command, args []string) {
 		cfg = config.DefaultConfig()
 	}
 
-	// We will check the availability of certificates to confirm the TLS status
+	// Use the given certificate, or this host's own generated one
 	if enableTLS {
-		if !certutils.UserCertExists(certFile, keyFile) {
-			// User certificates do not exist, extract from embedded resources
-			certExists, err := certutils.EnsureCertsExist()
-			if err != nil {
-				fmt.Printf("Error ensuring certificates exist: %v\n", err)
-			}
-			enableTLS = certExists
-
-		}
+		certFile, keyFile, enableTLS = setupTLS(certFile, keyFile)
 	}
 
-	// Check if the certificate is valid
-	enableTLS = certutils.IsValidCertificate(certFile)
-
 	// Update configuration with command line flags
 	if port != 0 {
 		cfg.Port = port
@@ -340,13 +699,23 @@ func runServer(cmd *cobra.Command, args []string) {
 
 	// Initialize the Linqora Host server
 	server := ws.NewWSServer(cfg, authManager)
+	authManager.SetEventBus(server.Events())
+	startCA(server)
+	followCertificate(server, mdnsServer)
 
 	// Run the server in a separate goroutine
 	go startCommandProcessor()
+	go promptConfirmations(server.Confirmations(), stopCh)
+	events.Subscribe(server.Events(), auth.LockoutTopic, announceLockout)
 
 	// Create a context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 
+	// Serve the admin socket for CLI commands
+	setActiveServer(server)
+	startControl(ctx, server)
+	startAudit(ctx, server)
+
 	// Start the WebSocket server
 	go func() {
 		if err := server.Start(ctx); err != nil {
@@ -383,15 +752,7 @@ func StartServerBackground(onStatus func(running bool, ip string, port int)) (co
 		cfg = config.DefaultConfig()
 	}
 
-	certFile := "./certificates/dev_cert.pem"
-	keyFile := "./certificates/dev_key.pem"
-	enableTLS := true
-
-	if !certutils.UserCertExists(certFile, keyFile) {
-		certExists, _ := certutils.EnsureCertsExist()
-		enableTLS = certExists
-	}
-	enableTLS = certutils.IsValidCertificate(certFile)
+	certFile, keyFile, enableTLS := setupTLS("", "")
 
 	cfg.EnableTLS = enableTLS
 	cfg.CertFile = certFile
@@ -412,7 +773,13 @@ func StartServerBackground(onStatus func(running bool, ip string, port int)) (co
 	}
 
 	server := ws.NewWSServer(cfg, authManager)
+	authManager.SetEventBus(server.Events())
+	startCA(server)
+	followCertificate(server, mdnsServer)
 	ctx, cancel := context.WithCancel(context.Background())
+	setActiveServer(server)
+	startControl(ctx, server)
+	startAudit(ctx, server)
 
 	go func() {
 		if err := server.Start(ctx); err != nil {
@@ -434,7 +801,7 @@ func StopServer(cancel context.CancelFunc) {
 }
 
 func main() {
-	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo})))
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.MultiWriter(os.Stderr, logBuffer), &slog.HandlerOptions{Level: slog.LevelInfo})))
 
 	// If no arguments provided, launch GUI
 	if len(os.Args) == 1 {
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"LinqoraHost/internal/auth"
+	"LinqoraHost/internal/confirm"
+	"fmt"
+)
+
+// promptConfirmations prints each remote action waiting for confirmation
+// until stop is closed. The operator answers with `allow` or `deny`.
+func promptConfirmations(broker *confirm.Broker, stop <-chan struct{}) {
+	for {
+		select {
+		case req := <-broker.Requests():
+			auth.ConsoleMutex.Lock()
+			fmt.Printf("\n\n===> CONFIRMATION REQUIRED <===\n")
+			fmt.Printf("Action:  %s\n", req.Action)
+			fmt.Printf("Request: %s\n", req.Summary)
+			fmt.Printf("Device:  %s\n", req.DeviceName)
+			fmt.Printf("IP:      %s\n", req.IP)
+			fmt.Printf("Expires: %s\n\n", req.Expires.Format("15:04:05"))
+			fmt.Printf("Type \"allow %s\" or \"deny %s\": ", req.ID, req.ID)
+			auth.ConsoleMutex.Unlock()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// answerConfirmation handles `allow [id]` and `deny [id]` at the console.
+// Without an ID it answers the only waiting request. The caller holds
+// auth.ConsoleMutex.
+func answerConfirmation(broker *confirm.Broker, fields []string) {
+	allow := fields[0] == "allow"
+	pending := broker.Pending()
+
+	id := ""
+	switch {
+	case len(fields) > 1:
+		id = fields[1]
+	case len(pending) == 1:
+		id = pending[0].ID
+	case len(pending) == 0:
+		fmt.Println("No actions are waiting for confirmation.")
+		return
+	default:
+		fmt.Println("Several actions are waiting; give the ID:")
+		for _, req := range pending {
+			fmt.Printf("  %-4s %-12s %s (%s)\n", req.ID, req.Action, req.Summary, req.DeviceName)
+		}
+		return
+	}
+
+	if !broker.Respond(id, allow) {
+		fmt.Printf("No action %q is waiting for confirmation.\n", id)
+		return
+	}
+	if allow {
+		fmt.Printf("Action %s allowed\n", id)
+	} else {
+		fmt.Printf("Action %s denied\n", id)
+	}
+}
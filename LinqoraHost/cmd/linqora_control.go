@@ -0,0 +1,191 @@
+package main
+
+import (
+	"LinqoraHost/internal/auth"
+	"LinqoraHost/internal/config"
+	"LinqoraHost/internal/control"
+	"LinqoraHost/internal/ws"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// logBuffer keeps recent log lines for `linqorahost logs`.
+var logBuffer = control.NewLogBuffer(1000)
+
+// startControl serves the local admin socket for the running server until
+// ctx is done. Failing to open it is logged but does not stop the server.
+func startControl(ctx context.Context, server *ws.WSServer) {
+	am := authManager
+	ctl := control.NewServer(logBuffer)
+
+	ctl.Handle(control.CmdRevoke, func(raw json.RawMessage) (interface{}, error) {
+		var args control.RevokeArgs
+		if err := json.Unmarshal(raw, &args); err != nil || args.DeviceID == "" {
+			return nil, errors.New("deviceId is required")
+		}
+		for _, d := range am.ListDevices() {
+			if d.DeviceID == args.DeviceID {
+				am.RevokeAuth(d.DeviceID)
+				return control.RevokeResult{
+					DeviceName:   d.DeviceName,
+					Disconnected: server.DisconnectDevice(d.DeviceID, "authorization revoked"),
+				}, nil
+			}
+		}
+		return nil, fmt.Errorf("device %q not found in authorized devices", args.DeviceID)
+	})
+
+	ctl.Handle(control.CmdSessions, func(json.RawMessage) (interface{}, error) {
+		return server.Connections(), nil
+	})
+
+	ctl.Handle(control.CmdReload, func(json.RawMessage) (interface{}, error) {
+		return reloadServer(am, server)
+	})
+
+	ctl.Handle(control.CmdCertReload, func(json.RawMessage) (interface{}, error) {
+		status, err := server.ReloadCertificate()
+		if err != nil {
+			return nil, err
+		}
+		return control.CertReloadResult{Fingerprint: status.Fingerprint, NotAfter: status.NotAfter}, nil
+	})
+
+	if err := ctl.Start(ctx); err != nil {
+		slog.Warn("Admin socket unavailable, CLI changes need a restart", "err", err)
+	}
+}
+
+// reloadServer makes server and am adopt the config file, closes the
+// connections it no longer allows and reports what changed.
+func reloadServer(am *auth.AuthManager, server *ws.WSServer) (control.ReloadResult, error) {
+	fresh, err := config.LoadConfig()
+	if err != nil {
+		return control.ReloadResult{}, err
+	}
+	if err := fresh.ExecPolicy.Validate(); err != nil {
+		return control.ReloadResult{}, fmt.Errorf("exec_policy: %w", err)
+	}
+	if err := fresh.ValidateNetwork(); err != nil {
+		return control.ReloadResult{}, err
+	}
+	result := control.ReloadResult{
+		Devices:         len(fresh.AuthorizedDevs),
+		Tokens:          len(fresh.APITokens),
+		RestartRequired: server.Config().RestartRequired(fresh),
+	}
+	result.Revoked = am.Reload(fresh)
+	server.UseConfig(fresh)
+	for _, id := range result.Revoked {
+		server.DisconnectDevice(id, "authorization revoked")
+	}
+	result.Refused = server.EnforceNetworkPolicy()
+	slog.Info("Config reloaded", "devices", result.Devices, "tokens", result.Tokens, "revoked", len(result.Revoked))
+	return result, nil
+}
+
+// reloadRunningServer asks a running host to reload the config file a CLI
+// command has just written, so that the change applies at once and is not
+// overwritten by the server's next save. Without a running host it does
+// nothing: the file is read at the next start.
+func reloadRunningServer() {
+	var result control.ReloadResult
+	err := control.Call(control.CmdReload, nil, &result)
+	if errors.Is(err, control.ErrNotRunning) {
+		return
+	}
+	if err != nil {
+		fmt.Printf("Warning: the running host did not reload its config: %v\n", err)
+		return
+	}
+	printReloadResult(result)
+}
+
+// printReloadResult summarises what a config reload changed.
+func printReloadResult(result control.ReloadResult) {
+	fmt.Printf("Running host reloaded: %d device(s), %d API token(s).\n", result.Devices, result.Tokens)
+	if len(result.Revoked) > 0 {
+		fmt.Printf("Disconnected revoked devices: %s\n", strings.Join(result.Revoked, ", "))
+	}
+	if result.Refused > 0 {
+		fmt.Printf("Closed %d connection(s) from addresses no longer allowed.\n", result.Refused)
+	}
+	if len(result.RestartRequired) > 0 {
+		fmt.Printf("Restart the host to apply: %s\n", strings.Join(result.RestartRequired, ", "))
+	}
+}
+
+var configReloadCmd = &cobra.Command{
+	Use:   "reload",
+	Short: "Make the running host re-read its config file",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var result control.ReloadResult
+		if err := control.Call(control.CmdReload, nil, &result); err != nil {
+			if errors.Is(err, control.ErrNotRunning) {
+				fmt.Println("The host is not running; the config is read when it starts.")
+				return nil
+			}
+			return err
+		}
+		printReloadResult(result)
+		return nil
+	},
+}
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "List the connections of the running host",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var conns []ws.ConnectionInfo
+		if err := control.Call(control.CmdSessions, nil, &conns); err != nil {
+			return err
+		}
+		if len(conns) == 0 {
+			fmt.Println("No connected devices.")
+			return nil
+		}
+		fmt.Printf("%-6s  %-36s  %-20s  %-21s  %-8s  %-9s  %-11s  %s\n",
+			"ID", "Device ID", "Name", "IP", "Since", "Last Ping", "In/Out", "Rooms")
+		fmt.Println(strings.Repeat("-", 130))
+		for _, c := range conns {
+			deviceID, name := c.DeviceID, c.DeviceName
+			if !c.Authorized {
+				deviceID, name = "-", "(not authorized)"
+			}
+			fmt.Printf("%-6s  %-36s  %-20s  %-21s  %-8s  %-9s  %-11s  %s\n", c.ID, deviceID, name, c.IP,
+				c.ConnectedAt.Format("15:04:05"), time.Since(c.LastPing).Truncate(time.Second).String()+" ago",
+				fmt.Sprintf("%d/%d", c.MessagesReceived, c.MessagesSent), strings.Join(c.Rooms, ","))
+		}
+		return nil
+	},
+}
+
+var (
+	logsLines  int
+	logsFollow bool
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Show the log of the running host",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return control.TailLogs(logsLines, logsFollow, os.Stdout)
+	},
+}
+
+func init() {
+	logsCmd.Flags().IntVarP(&logsLines, "lines", "n", 50, "Number of recent lines to show (0 for all kept)")
+	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "Keep printing new lines")
+
+	configCmd.AddCommand(configReloadCmd)
+	rootCmd.AddCommand(sessionsCmd)
+	rootCmd.AddCommand(logsCmd)
+}
@@ -6,9 +6,13 @@ import (
 	"context"
 	"fmt"
 	"image/color"
+	"io"
 	"log/slog"
 	"os"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
@@ -20,10 +24,14 @@ import (
 	"fyne.io/fyne/v2/widget"
 	qrcode "github.com/skip2/go-qrcode"
 
+	"LinqoraHost/internal/auth"
 	"LinqoraHost/internal/config"
+	"LinqoraHost/internal/confirm"
 	"LinqoraHost/internal/deviceinfo"
+	"LinqoraHost/internal/events"
 	"LinqoraHost/internal/startup"
 	"LinqoraHost/internal/updater"
+	"LinqoraHost/internal/ws"
 )
 
 // ─────────────────────── log writer ───────────────────────
@@ -63,8 +71,28 @@ var (
 
 // ─────────────────────── auth watcher ───────────────────────
 
+// approvalExpiries are the lifetimes offered when approving a device; the
+// values are config.ParseExpiry forms.
+var approvalExpiries = []struct{ label, value string }{
+	{"Permanently", "never"},
+	{"1 hour", "1h"},
+	{"Until midnight", "midnight"},
+	{"1 day", "1d"},
+	{"7 days", "7d"},
+}
+
+func approvalExpiryLabels() []string {
+	labels := make([]string, len(approvalExpiries))
+	for i, e := range approvalExpiries {
+		labels[i] = e.label
+	}
+	return labels
+}
+
 // watchAuth listens on authChan for manual approval requests and shows a
-// confirm dialog for each. Exits when the server stops (stopCh is closed).
+// confirm dialog for each, where the operator picks the scopes to grant and
+// how long the approval lasts.
+// Exits when the server stops (stopCh is closed).
 func watchAuth(win fyne.Window) {
 	ch := authChan
 	stop := stopCh
@@ -76,12 +104,19 @@ func watchAuth(win fyne.Window) {
 			}
 			r := req
 			msg := fmt.Sprintf(
-				"New device wants to connect\n\nName:  %s\nID:      %s\nIP:       %s\n\nApprove?",
+				"New device wants to connect\n\nName:  %s\nID:      %s\nIP:       %s\n\nAllow:",
 				r.DeviceName, r.DeviceID, r.IP,
 			)
 			fyne.Do(func() {
-				dialog.ShowConfirm("Connection Request", msg, func(approved bool) {
-					authManager.RespondToAuthRequest(r.DeviceID, approved)
+				scopes := widget.NewCheckGroup(config.AllScopes, nil)
+				scopes.SetSelected(append([]string(nil), config.AllScopes...))
+				expiry := widget.NewSelect(approvalExpiryLabels(), nil)
+				expiry.SetSelectedIndex(0)
+				content := container.NewVBox(widget.NewLabel(msg), scopes,
+					widget.NewForm(widget.NewFormItem("Valid for", expiry)))
+				dialog.ShowCustomConfirm("Connection Request", "Approve", "Reject", content, func(approved bool) {
+					expiresAt, _ := config.ParseExpiry(approvalExpiries[expiry.SelectedIndex()].value, time.Now())
+					authManager.RespondToAuthRequest(r.DeviceID, approved, scopes.Selected, expiresAt)
 				}, win)
 			})
 		case <-stop:
@@ -90,17 +125,62 @@ func watchAuth(win fyne.Window) {
 	}
 }
 
+// watchConfirmations asks, in a dialog and a desktop notification, whether a
+// guarded remote action may run. The dialog closes itself when the request
+// expires unanswered.
+func watchConfirmations(win fyne.Window, broker *confirm.Broker) {
+	stop := stopCh
+	for {
+		select {
+		case req := <-broker.Requests():
+			r := req
+			msg := fmt.Sprintf(
+				"A device wants to run a guarded action\n\nAction:   %s\nRequest: %s\nDevice:   %s\nIP:          %s\n\nAllow it?",
+				r.Action, r.Summary, r.DeviceName, r.IP,
+			)
+			fyne.CurrentApp().SendNotification(fyne.NewNotification("Linqora: confirmation required",
+				fmt.Sprintf("%s wants to run %s", r.DeviceName, r.Action)))
+			fyne.Do(func() {
+				d := dialog.NewConfirm("Confirm Remote Action", msg, func(allowed bool) {
+					broker.Respond(r.ID, allowed)
+				}, win)
+				d.SetConfirmText("Allow")
+				d.SetDismissText("Deny")
+				d.Show()
+				time.AfterFunc(time.Until(r.Expires), func() {
+					fyne.Do(d.Hide)
+				})
+			})
+		case <-stop:
+			return
+		}
+	}
+}
+
+// notifyLockout raises a desktop notification when a lockout starts.
+func notifyLockout(l auth.Lockout) {
+	fyne.CurrentApp().SendNotification(fyne.NewNotification("Linqora: lockout",
+		fmt.Sprintf("%s locked out after %d failed attempts, until %s",
+			lockoutSubject(l), l.Failures, l.LockedUntil.Local().Format("15:04"))))
+}
+
 // ─────────────────────── QR code dialog ───────────────────────
 
 // showQRDialog generates a QR code for the server's pairing URL and shows it
-// in a dialog. The URL encodes linqora://ip:port so the app can scan to connect.
+// in a dialog. The URL encodes linqora://ip:port, and with TLS the
+// certificate fingerprint, so the app can scan to connect and pin it.
 func showQRDialog(win fyne.Window) {
 	ip := deviceinfo.GetDeviceInfo().IP
 	if ip == "" || ip == "Unknown IP" {
 		dialog.ShowInformation("Network unavailable", "No LAN IP found. Connect to a network first.", win)
 		return
 	}
-	url := fmt.Sprintf("linqora://%s:%d", ip, cfg.Port)
+	current := guiConfig()
+	fp := ws.CertFingerprint(current)
+	if server := runningServer(); server != nil {
+		fp = server.ServedFingerprint()
+	}
+	url := ws.ConnectURL(ip, current.Port, fp)
 
 	qr, err := qrcode.New(url, qrcode.High)
 	if err != nil {
@@ -190,7 +270,7 @@ func buildServerTab(win fyne.Window) fyne.CanvasObject {
 					ipLbl.SetText("IP:      " + ip)
 					portLbl.SetText(fmt.Sprintf("Port:    %d", port))
 					ts := "disabled"
-					if cfg.EnableTLS {
+					if guiConfig().EnableTLS {
 						ts = "enabled"
 					}
 					tlsLbl.SetText("TLS:    " + ts)
@@ -207,6 +287,10 @@ func buildServerTab(win fyne.Window) fyne.CanvasObject {
 			guiCancelMu.Unlock()
 
 			go watchAuth(win)
+			if server := runningServer(); server != nil {
+				go watchConfirmations(win, server.Confirmations())
+				events.Subscribe(server.Events(), auth.LockoutTopic, notifyLockout)
+			}
 		}
 	})
 	toggleBtn.Importance = widget.HighImportance
@@ -233,13 +317,51 @@ func buildServerTab(win fyne.Window) fyne.CanvasObject {
 
 // ─────────────────────── devices tab ───────────────────────
 
-func buildDevicesTab() fyne.CanvasObject {
-	var ids []string
+// guiConfig returns the config of the running server, which a reload
+// replaces, or the one the GUI loaded while no server runs.
+func guiConfig() *config.ServerConfig {
+	if server := runningServer(); server != nil {
+		return server.Config()
+	}
+	return cfg
+}
+
+// editDevice changes the authorised device id. With a server running, running
+// makes the change through its auth manager, so that it applies at once and
+// is not overwritten by the server's next save. Otherwise offline edits the
+// config file, as the CLI does.
+func editDevice(id string, running func(am *auth.AuthManager, server *ws.WSServer) error, offline func(c *config.ServerConfig)) error {
+	if server := runningServer(); server != nil {
+		return running(authManager, server)
+	}
+	loaded, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	if _, ok := loaded.AuthorizedDevs[id]; !ok {
+		return fmt.Errorf("device %q not found in authorized devices", id)
+	}
+	offline(loaded)
+	if err := loaded.SaveConfig(); err != nil {
+		return err
+	}
+	cfg = loaded
+	return nil
+}
+
+func buildDevicesTab(win fyne.Window) fyne.CanvasObject {
+	var devices []config.DeviceAuth
 	rebuild := func() {
-		ids = ids[:0]
-		for id := range cfg.AuthorizedDevs {
-			ids = append(ids, id)
+		if server := runningServer(); server != nil {
+			devices = authManager.ListDevices()
+		} else if loaded, err := config.LoadConfig(); err == nil {
+			cfg = loaded
+			devices = devices[:0]
+			for _, d := range loaded.AuthorizedDevs {
+				devices = append(devices, d)
+			}
 		}
+		sort.Slice(devices, func(i, j int) bool { return devices[i].DeviceID < devices[j].DeviceID })
 	}
 	rebuild()
 
@@ -247,44 +369,209 @@ func buildDevicesTab() fyne.CanvasObject {
 	sel := -1
 
 	list = widget.NewList(
-		func() int { return len(ids) },
+		func() int { return len(devices) },
 		func() fyne.CanvasObject { return widget.NewLabel("") },
 		func(i widget.ListItemID, obj fyne.CanvasObject) {
 			lbl := obj.(*widget.Label)
-			if i >= len(ids) {
+			if i >= len(devices) {
 				lbl.SetText("")
 				return
 			}
-			d := cfg.AuthorizedDevs[ids[i]]
-			lbl.SetText(fmt.Sprintf("%s  ·  last: %s", d.DeviceName, d.LastAuth))
+			d := devices[i]
+			text := fmt.Sprintf("%s  ·  last: %s  ·  scopes: %s", d.DeviceName, d.LastAuth, d.ScopesString())
+			if d.Admin {
+				text += "  ·  admin"
+			}
+			if d.CertSerial != "" {
+				text += "  ·  cert"
+			}
+			if d.StepUpKey != "" {
+				text += "  ·  pin"
+			}
+			if left := d.RemainingString(time.Now()); left != "" {
+				text += "  ·  " + left
+			}
+			lbl.SetText(text)
 		},
 	)
 	list.OnSelected = func(i widget.ListItemID) { sel = i }
 	list.OnUnselected = func(_ widget.ListItemID) { sel = -1 }
 
 	revokeBtn := widget.NewButtonWithIcon("Revoke Selected", theme.DeleteIcon(), func() {
-		if sel < 0 || sel >= len(ids) {
+		if sel < 0 || sel >= len(devices) {
 			return
 		}
-		delete(cfg.AuthorizedDevs, ids[sel])
-		cfg.SaveConfig()
+		id := devices[sel].DeviceID
+		err := editDevice(id, func(am *auth.AuthManager, server *ws.WSServer) error {
+			am.RevokeAuth(id)
+			server.DisconnectDevice(id, "authorization revoked")
+			return nil
+		}, func(c *config.ServerConfig) {
+			c.RevokeDevice(id)
+		})
+		if err != nil {
+			dialog.ShowError(err, win)
+		}
 		rebuild()
 		sel = -1
+		list.UnselectAll()
 		list.Refresh()
 	})
 	revokeBtn.Importance = widget.DangerImportance
 
-	refreshBtn := widget.NewButtonWithIcon("Refresh", theme.ViewRefreshIcon(), func() {
-		if loaded, err := config.LoadConfig(); err == nil {
-			cfg = loaded
+	adminBtn := widget.NewButtonWithIcon("Toggle Admin", theme.AccountIcon(), func() {
+		if sel < 0 || sel >= len(devices) {
+			return
+		}
+		id, admin := devices[sel].DeviceID, !devices[sel].Admin
+		err := editDevice(id, func(am *auth.AuthManager, _ *ws.WSServer) error {
+			return am.SetAdmin(id, admin)
+		}, func(c *config.ServerConfig) {
+			d := c.AuthorizedDevs[id]
+			d.Admin = admin
+			c.AuthorizedDevs[id] = d
+		})
+		if err != nil {
+			dialog.ShowError(err, win)
+		}
+		rebuild()
+		list.Refresh()
+	})
+
+	pairBtn := widget.NewButtonWithIcon("Pair Device", theme.ContentAddIcon(), func() {
+		code, expires, err := auth.StartPairing(config.AllScopes, "")
+		if err != nil {
+			dialog.ShowError(err, win)
+			return
 		}
+		codeLabel := widget.NewLabelWithStyle(code[:3]+" "+code[3:], fyne.TextAlignCenter, fyne.TextStyle{Bold: true, Monospace: true})
+		codeLabel.SizeName = theme.SizeNameHeadingText
+		dialog.ShowCustom("Pair Device", "Close", container.NewVBox(
+			widget.NewLabel("Enter this code in Linqora Remote:"),
+			codeLabel,
+			widget.NewLabel(fmt.Sprintf("Valid until %s, single use.", expires.Format("15:04:05"))),
+		), win)
+	})
+
+	refreshBtn := widget.NewButtonWithIcon("Refresh", theme.ViewRefreshIcon(), func() {
 		rebuild()
 		list.Refresh()
 	})
 
 	return container.NewBorder(
 		nil,
-		container.NewHBox(revokeBtn, refreshBtn),
+		container.NewHBox(pairBtn, adminBtn, revokeBtn, refreshBtn),
+		nil, nil,
+		list,
+	)
+}
+
+// ─────────────────────── sessions tab ───────────────────────
+
+// kickBlockChoices are the block durations offered when kicking a session.
+var kickBlockChoices = []struct {
+	label   string
+	seconds int
+}{
+	{"Don't block", 0},
+	{"5 minutes", 300},
+	{"1 hour", 3600},
+	{"1 day", 86400},
+}
+
+func buildSessionsTab(win fyne.Window) fyne.CanvasObject {
+	var conns []ws.ConnectionInfo
+	sel := -1
+
+	list := widget.NewList(
+		func() int { return len(conns) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(i widget.ListItemID, obj fyne.CanvasObject) {
+			lbl := obj.(*widget.Label)
+			if i >= len(conns) {
+				lbl.SetText("")
+				return
+			}
+			c := conns[i]
+			name := c.DeviceName
+			if !c.Authorized {
+				name = "(not authorized)"
+			}
+			text := fmt.Sprintf("%s  ·  %s  ·  since %s  ·  ping %s ago  ·  in/out %d/%d",
+				name, c.IP, c.ConnectedAt.Format("15:04:05"),
+				time.Since(c.LastPing).Truncate(time.Second), c.MessagesReceived, c.MessagesSent)
+			if len(c.Rooms) > 0 {
+				text += "  ·  rooms: " + strings.Join(c.Rooms, ", ")
+			}
+			lbl.SetText(text)
+		},
+	)
+	list.OnSelected = func(i widget.ListItemID) { sel = i }
+	list.OnUnselected = func(_ widget.ListItemID) { sel = -1 }
+
+	refresh := func() {
+		conns = nil
+		if server := runningServer(); server != nil {
+			conns = server.Connections()
+		}
+		if sel >= len(conns) {
+			sel = -1
+			list.UnselectAll()
+		}
+		list.Refresh()
+	}
+
+	kickBtn := widget.NewButtonWithIcon("Kick Selected", theme.CancelIcon(), func() {
+		server := runningServer()
+		if server == nil || sel < 0 || sel >= len(conns) {
+			return
+		}
+		target := conns[sel]
+
+		reason := widget.NewEntry()
+		reason.SetPlaceHolder("disconnected by administrator")
+		labels := make([]string, len(kickBlockChoices))
+		for i, choice := range kickBlockChoices {
+			labels[i] = choice.label
+		}
+		block := widget.NewSelect(labels, nil)
+		block.SetSelectedIndex(0)
+
+		form := widget.NewForm(
+			widget.NewFormItem("Reason", reason),
+			widget.NewFormItem("Block", block),
+		)
+		title := fmt.Sprintf("Kick %s (%s)?", target.DeviceName, target.IP)
+		dialog.ShowCustomConfirm(title, "Kick", "Cancel", form, func(ok bool) {
+			if !ok {
+				return
+			}
+			req := ws.KickRequest{ID: target.ID, Reason: reason.Text}
+			if i := block.SelectedIndex(); i > 0 {
+				req.BlockSeconds = kickBlockChoices[i].seconds
+			}
+			if _, err := server.Kick(req); err != nil {
+				dialog.ShowError(err, win)
+			}
+			refresh()
+		}, win)
+	})
+	kickBtn.Importance = widget.DangerImportance
+
+	refreshBtn := widget.NewButtonWithIcon("Refresh", theme.ViewRefreshIcon(), refresh)
+
+	// Keep connect state and ping ages current while the window is open.
+	go func() {
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			fyne.Do(refresh)
+		}
+	}()
+
+	return container.NewBorder(
+		nil,
+		container.NewHBox(kickBtn, refreshBtn),
 		nil, nil,
 		list,
 	)
@@ -341,14 +628,36 @@ func buildSettingsTab(win fyne.Window) fyne.CanvasObject {
 			statusLbl.SetText("⚠  Invalid port value")
 			return
 		}
-		cfg.Port = p
-		cfg.EnableTLS = tlsCheck.Checked
-		cfg.SharedSecret = secretEntry.Text
-		cfg.EnableE2EE = e2eeCheck.Checked
-		if err := cfg.SaveConfig(); err != nil {
+		// Edit the file as it is now, which the CLI may have changed, and
+		// let a running server reload it instead of saving a stale copy.
+		loaded, err := config.LoadConfig()
+		if err != nil {
+			statusLbl.SetText("⚠  Save failed: " + err.Error())
+			return
+		}
+		loaded.Port = p
+		loaded.EnableTLS = tlsCheck.Checked
+		loaded.SharedSecret = secretEntry.Text
+		loaded.EnableE2EE = e2eeCheck.Checked
+		if err := loaded.SaveConfig(); err != nil {
 			statusLbl.SetText("⚠  Save failed: " + err.Error())
 			return
 		}
+		server := runningServer()
+		if server == nil {
+			cfg = loaded
+			statusLbl.SetText("✓  Saved")
+			return
+		}
+		result, err := reloadServer(authManager, server)
+		if err != nil {
+			statusLbl.SetText("⚠  Saved, but the server did not reload: " + err.Error())
+			return
+		}
+		if len(result.RestartRequired) > 0 {
+			statusLbl.SetText("✓  Saved; restart the server to apply " + strings.Join(result.RestartRequired, ", "))
+			return
+		}
 		statusLbl.SetText("✓  Saved")
 	})
 	saveBtn.Importance = widget.HighImportance
@@ -381,6 +690,20 @@ func buildSettingsTab(win fyne.Window) fyne.CanvasObject {
 		}()
 	})
 
+	certBtn := widget.NewButtonWithIcon("Reload TLS Certificate", theme.ViewRefreshIcon(), func() {
+		server := runningServer()
+		if server == nil {
+			statusLbl.SetText("⚠  Server is not running")
+			return
+		}
+		status, err := server.ReloadCertificate()
+		if err != nil {
+			statusLbl.SetText("⚠  Certificate not reloaded: " + err.Error())
+			return
+		}
+		statusLbl.SetText("✓  Certificate reloaded, expires " + status.NotAfter.Local().Format("2006-01-02"))
+	})
+
 	versionLbl := widget.NewLabelWithStyle(
 		"LinqoraHost "+AppVersion,
 		fyne.TextAlignCenter,
@@ -391,6 +714,7 @@ func buildSettingsTab(win fyne.Window) fyne.CanvasObject {
 		form,
 		widget.NewSeparator(),
 		saveBtn,
+		certBtn,
 		updateBtn,
 		statusLbl,
 		widget.NewSeparator(),
@@ -444,13 +768,14 @@ func RunGUI() {
 
 	// GUI mode: log only to the in-app log pane, not stderr.
 	slog.SetDefault(slog.New(slog.NewTextHandler(
-		logWriter,
+		io.MultiWriter(logWriter, logBuffer),
 		&slog.HandlerOptions{Level: slog.LevelInfo},
 	)))
 
 	tabs := container.NewAppTabs(
 		container.NewTabItem("Server", buildServerTab(w)),
-		container.NewTabItem("Devices", buildDevicesTab()),
+		container.NewTabItem("Devices", buildDevicesTab(w)),
+		container.NewTabItem("Sessions", buildSessionsTab(w)),
 		container.NewTabItem("Settings", buildSettingsTab(w)),
 		container.NewTabItem("Log", logContent),
 	)
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"LinqoraHost/internal/auth"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	lockoutCmd = &cobra.Command{
+		Use:   "lockout",
+		Short: "List addresses and devices with failed authentication attempts",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			all := auth.Lockouts()
+			if len(all) == 0 {
+				fmt.Println("No failed authentication attempts recorded.")
+				return nil
+			}
+			const layout = "2006-01-02 15:04:05"
+			now := time.Now()
+			fmt.Printf("%-7s  %-36s  %-8s  %-19s  %-20s  %s\n", "Kind", "Address / Device", "Failures", "Last Failure", "Reason", "Locked Until")
+			fmt.Println(strings.Repeat("-", 120))
+			for _, l := range all {
+				until := "-"
+				if l.Locked(now) {
+					until = l.LockedUntil.Local().Format(layout)
+				}
+				key := l.Key
+				if l.Kind == auth.LockoutDevice && l.DeviceName != "" {
+					key += " (" + l.DeviceName + ")"
+				}
+				fmt.Printf("%-7s  %-36s  %-8d  %-19s  %-20s  %s\n", l.Kind, key, l.Failures, l.LastFailure.Local().Format(layout), l.Reason, until)
+			}
+			return nil
+		},
+	}
+
+	lockoutClearAll bool
+)
+
+var lockoutClearCmd = &cobra.Command{
+	Use:   "clear [address|device-id]",
+	Short: "Forget the failed attempts of an address or device, ending its lockout",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target := ""
+		switch {
+		case len(args) == 1 && !lockoutClearAll:
+			target = args[0]
+		case len(args) == 0 && lockoutClearAll:
+		default:
+			return fmt.Errorf("name an address or device ID, or pass --all")
+		}
+		n, err := auth.ClearLockouts(target)
+		if err != nil {
+			return err
+		}
+		if n == 0 && target != "" {
+			return fmt.Errorf("no failed attempts recorded for %s", target)
+		}
+		fmt.Printf("Cleared %d record(s).\n", n)
+		return nil
+	},
+}
+
+// announceLockout tells the console operator that a lockout started.
+func announceLockout(l auth.Lockout) {
+	auth.ConsoleMutex.Lock()
+	defer auth.ConsoleMutex.Unlock()
+	fmt.Printf("\n\n===> LOCKOUT <===\n")
+	fmt.Printf("%s locked out after %d failed attempts (%s)\n", lockoutSubject(l), l.Failures, l.Reason)
+	fmt.Printf("Until:   %s\n", l.LockedUntil.Local().Format("2006-01-02 15:04:05"))
+	fmt.Printf("Clear with: linqora lockout clear %s\n\n", l.Key)
+}
+
+// lockoutSubject names what a lockout applies to.
+func lockoutSubject(l auth.Lockout) string {
+	if l.Kind == auth.LockoutIP {
+		return "Address " + l.Key
+	}
+	if l.DeviceName != "" {
+		return fmt.Sprintf("Device %s (%s)", l.DeviceName, l.Key)
+	}
+	return "Device " + l.Key
+}
+
+func init() {
+	lockoutClearCmd.Flags().BoolVar(&lockoutClearAll, "all", false, "Clear every record")
+	lockoutCmd.AddCommand(lockoutClearCmd)
+	rootCmd.AddCommand(lockoutCmd)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"LinqoraHost/internal/config"
+	"LinqoraHost/internal/execpolicy"
+	"LinqoraHost/internal/scheduler"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	policyCmd = &cobra.Command{
+		Use:   "policy",
+		Short: "Inspect the exec policy for shell_exec and scripts",
+	}
+
+	policyDevice string
+	policyDir    string
+	policyScript string
+)
+
+var policyShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the exec policy and check it for mistakes",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			return err
+		}
+		data, _ := json.MarshalIndent(cfg.ExecPolicy, "", "  ")
+		fmt.Println(string(data))
+		return cfg.ExecPolicy.Validate()
+	},
+}
+
+var policyTestCmd = &cobra.Command{
+	Use:   "test [command line]",
+	Short: "Show whether a command line or script would be allowed",
+	Long:  "Show whether a shell_exec command line, or the script named by --script, would be allowed for --device (default: the global policy). Quote the command line so that the shell passes it as typed.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			return err
+		}
+		if err := cfg.ExecPolicy.Validate(); err != nil {
+			return fmt.Errorf("exec_policy: %w", err)
+		}
+
+		var d execpolicy.Decision
+		switch {
+		case policyScript != "":
+			script, ok := findScript(policyScript)
+			if !ok {
+				return fmt.Errorf("script %q not found", policyScript)
+			}
+			dir := script.WorkDir
+			if policyDir != "" {
+				dir = policyDir
+			}
+			d = execpolicy.CheckCommand(&cfg.ExecPolicy, policyDevice, script.Command, script.Args, dir)
+		case len(args) > 0:
+			d = execpolicy.CheckShell(&cfg.ExecPolicy, policyDevice, strings.Join(args, " "), policyDir)
+		default:
+			return fmt.Errorf("give a command line or --script")
+		}
+
+		if d.Allowed {
+			fmt.Printf("ALLOWED: %s\n", d.Reason)
+			if d.Dir != "" {
+				fmt.Printf("Runs in: %s\n", d.Dir)
+			}
+		} else {
+			fmt.Printf("DENIED:  %s\n", d.Reason)
+		}
+		return nil
+	},
+}
+
+// findScript looks a script up in the scripts file.
+func findScript(id string) (scheduler.Script, bool) {
+	for _, s := range scheduler.NewManager(scheduler.DefaultScriptsPath()).List() {
+		if s.ID == id {
+			return s, true
+		}
+	}
+	return scheduler.Script{}, false
+}
+
+func init() {
+	policyTestCmd.Flags().StringVar(&policyDevice, "device", "", "Device ID whose overrides apply")
+	policyTestCmd.Flags().StringVar(&policyDir, "dir", "", "Working directory the command asks for")
+	policyTestCmd.Flags().StringVar(&policyScript, "script", "", "Test the script with this ID instead of a command line")
+
+	policyCmd.AddCommand(policyShowCmd)
+	policyCmd.AddCommand(policyTestCmd)
+	rootCmd.AddCommand(policyCmd)
+}
@@ -0,0 +1,238 @@
+// Package audit keeps an append-only record of every remote command: who
+// sent it, from where, with which (redacted) parameters, and how it ended.
+//
+// Each entry carries the SHA-256 hash of the previous one, so editing or
+// removing an entry breaks the chain and Verify reports where. Files are
+// rotated by size; the chain continues across them.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Outcomes recorded in Entry.Outcome.
+const (
+	OutcomeOK    = "ok"
+	OutcomeError = "error"
+)
+
+const (
+	// DefaultMaxSize is the size at which the current file is rotated.
+	DefaultMaxSize = 10 << 20
+	// DefaultKeep is how many rotated files are kept.
+	DefaultKeep = 5
+)
+
+// Entry is one audited command.
+type Entry struct {
+	Seq        uint64          `json:"seq"`
+	Time       time.Time       `json:"time"`
+	DeviceID   string          `json:"deviceId,omitempty"`
+	DeviceName string          `json:"deviceName,omitempty"`
+	IP         string          `json:"ip,omitempty"`
+	Type       string          `json:"type"`
+	Params     json.RawMessage `json:"params,omitempty"`
+	Outcome    string          `json:"outcome"`
+	Code       int             `json:"code,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	DurationMs int64           `json:"durationMs"`
+	// Prev is the hash of the entry before, empty for the very first.
+	Prev string `json:"prev"`
+	// Hash covers every other field, Prev included.
+	Hash string `json:"hash"`
+}
+
+// computeHash returns the hash of e with its Hash field left out.
+func computeHash(e Entry) string {
+	e.Hash = ""
+	data, _ := json.Marshal(e)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Log appends entries to a file, rotating it by size.
+type Log struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	keep    int
+	file    *os.File
+	size    int64
+	seq     uint64
+	last    string
+}
+
+// Open opens the log at path for appending and resumes the chain from its
+// last entry. maxSize and keep of zero or less mean DefaultMaxSize and
+// DefaultKeep.
+func Open(path string, maxSize int64, keep int) (*Log, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	if keep <= 0 {
+		keep = DefaultKeep
+	}
+	l := &Log{path: path, maxSize: maxSize, keep: keep}
+
+	// The newest entry is in the current file or, right after a rotation,
+	// in the previous one.
+	for _, name := range []string{path, rotatedName(path, 1)} {
+		last, ok, err := lastEntry(name)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			l.seq, l.last = last.Seq, last.Hash
+			break
+		}
+	}
+
+	if err := l.openFile(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) openFile() error {
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.file, l.size = f, info.Size()
+	return nil
+}
+
+// Append chains e to the log and writes it. Seq, Prev and Hash are set
+// here; a zero Time is set to now.
+func (l *Log) Append(e Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return os.ErrClosed
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC()
+	if len(e.Params) > 0 {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, e.Params); err != nil {
+			return fmt.Errorf("invalid params: %w", err)
+		}
+		e.Params = buf.Bytes()
+	}
+	e.Seq = l.seq + 1
+	e.Prev = l.last
+	// Hash the entry as it will read back, so that Verify sees the same bytes
+	// even where encoding changes a value (invalid UTF-8, for one).
+	normal, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	e = Entry{}
+	json.Unmarshal(normal, &e)
+	e.Hash = computeHash(e)
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	l.seq, l.last = e.Seq, e.Hash
+	return nil
+}
+
+// rotate shifts path to path.1, path.1 to path.2 and so on, dropping the
+// oldest, and starts a new current file.
+func (l *Log) rotate() error {
+	l.file.Close()
+	l.file = nil
+	os.Remove(rotatedName(l.path, l.keep))
+	for i := l.keep - 1; i >= 1; i-- {
+		os.Rename(rotatedName(l.path, i), rotatedName(l.path, i+1))
+	}
+	if err := os.Rename(l.path, rotatedName(l.path, 1)); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+	return l.openFile()
+}
+
+// Close closes the log; later appends fail.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+func rotatedName(path string, n int) string {
+	return path + "." + strconv.Itoa(n)
+}
+
+// lastEntry returns the last entry of the file at name, if it has one.
+func lastEntry(name string) (Entry, bool, error) {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return Entry{}, false, nil
+	}
+	if err != nil {
+		return Entry{}, false, err
+	}
+	defer f.Close()
+
+	var last []byte
+	scanner := newScanner(f)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) > 0 {
+			last = append(last[:0], scanner.Bytes()...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Entry{}, false, err
+	}
+	if last == nil {
+		return Entry{}, false, nil
+	}
+	var e Entry
+	if err := json.Unmarshal(last, &e); err != nil {
+		return Entry{}, false, fmt.Errorf("%s: last entry is not valid: %w", name, err)
+	}
+	return e, true, nil
+}
+
+// newScanner reads lines of any length an entry can have.
+func newScanner(f *os.File) *bufio.Scanner {
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4<<20)
+	return scanner
+}
@@ -0,0 +1,85 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChainSurvivesRotationAndReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path, 600, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		if err := l.Append(Entry{DeviceID: "phone", Type: "power", Outcome: OutcomeOK}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close()
+
+	l, err = Open(path, 600, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Append(Entry{DeviceID: "laptop", Type: "shell_exec", Outcome: OutcomeError, Code: 403, Error: "bad \xff byte"})
+	l.Close()
+
+	if len(Files(path)) < 2 {
+		t.Fatalf("Expected the log to rotate, files: %v", Files(path))
+	}
+	result, err := Verify(path)
+	if err != nil {
+		t.Fatalf("Expected an intact chain, got %v", err)
+	}
+	if result.LastSeq != 7 {
+		t.Errorf("Expected the chain to continue after reopening, last seq %d", result.LastSeq)
+	}
+
+	entries, _ := Read(path, Filter{Device: "laptop"})
+	if len(entries) != 1 || entries[0].Type != "shell_exec" {
+		t.Errorf("Unexpected filtered entries %+v", entries)
+	}
+	if entries, _ := Read(path, Filter{Since: time.Now().Add(time.Hour)}); len(entries) != 0 {
+		t.Errorf("Expected no entries from the future, got %d", len(entries))
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, _ := Open(path, 0, 0)
+	for _, typ := range []string{"power", "process_kill", "file_write"} {
+		l.Append(Entry{DeviceID: "phone", Type: typ, Outcome: OutcomeOK})
+	}
+	l.Close()
+	original, _ := os.ReadFile(path)
+	lines := strings.SplitAfter(string(original), "\n")
+
+	os.WriteFile(path, []byte(strings.Replace(string(original), `"process_kill"`, `"ping"`, 1)), 0600)
+	if _, err := Verify(path); err == nil || !strings.Contains(err.Error(), "modified") {
+		t.Errorf("Expected an edited entry to be found, got %v", err)
+	}
+
+	os.WriteFile(path, []byte(lines[0]+lines[2]), 0600)
+	if _, err := Verify(path); err == nil || !strings.Contains(err.Error(), "removed") {
+		t.Errorf("Expected a removed entry to be found, got %v", err)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	got := string(Redact([]byte(`{"path":"/tmp/a","content":"c2VjcmV0","nested":{"shared_secret":"x"},"list":[{"text":"hi"}]}`)))
+	for _, leak := range []string{"c2VjcmV0", `"x"`, `"hi"`} {
+		if strings.Contains(got, leak) {
+			t.Errorf("Expected %s to be redacted: %s", leak, got)
+		}
+	}
+	if !strings.Contains(got, "/tmp/a") {
+		t.Errorf("Expected the path to be kept: %s", got)
+	}
+	if got := string(Redact([]byte("not json"))); !strings.Contains(got, "redacted") {
+		t.Errorf("Expected invalid JSON to be redacted, got %s", got)
+	}
+}
@@ -0,0 +1,134 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Filter selects entries. Zero fields match everything.
+type Filter struct {
+	// Device matches the device ID or name.
+	Device string
+	Type   string
+	Since  time.Time
+	Until  time.Time
+}
+
+func (f Filter) match(e Entry) bool {
+	if f.Device != "" && e.DeviceID != f.Device && !strings.EqualFold(e.DeviceName, f.Device) {
+		return false
+	}
+	if f.Type != "" && e.Type != f.Type {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.Time.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Files returns the log's files that exist, oldest first.
+func Files(path string) []string {
+	var files []string
+	for n := 1; ; n++ {
+		if _, err := os.Stat(rotatedName(path, n)); err != nil {
+			break
+		}
+		files = append([]string{rotatedName(path, n)}, files...)
+	}
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	}
+	return files
+}
+
+// Read returns the entries of the log at path that match f, oldest first.
+func Read(path string, f Filter) ([]Entry, error) {
+	var entries []Entry
+	err := walk(path, func(name string, line int, e Entry) error {
+		if f.match(e) {
+			entries = append(entries, e)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// VerifyResult summarises a successful Verify.
+type VerifyResult struct {
+	Entries int
+	// FirstSeq is the sequence number the kept files start at. Above 1,
+	// older entries were rotated away and the chain is anchored there.
+	FirstSeq uint64
+	LastSeq  uint64
+}
+
+// Verify checks every entry's hash and its link to the entry before. The
+// error names the first file and line that do not match.
+func Verify(path string) (VerifyResult, error) {
+	var (
+		result VerifyResult
+		prev   string
+	)
+	err := walk(path, func(name string, line int, e Entry) error {
+		if computeHash(e) != e.Hash {
+			return fmt.Errorf("%s:%d: entry %d was modified", name, line, e.Seq)
+		}
+		if result.Entries == 0 {
+			result.FirstSeq = e.Seq
+			if e.Seq == 1 && e.Prev != "" {
+				return fmt.Errorf("%s:%d: first entry links to a missing one", name, line)
+			}
+		} else {
+			if e.Prev != prev {
+				return fmt.Errorf("%s:%d: entry %d does not follow the entry before it; entries were removed or reordered", name, line, e.Seq)
+			}
+			if e.Seq != result.LastSeq+1 {
+				return fmt.Errorf("%s:%d: expected entry %d, found %d", name, line, result.LastSeq+1, e.Seq)
+			}
+		}
+		prev, result.LastSeq = e.Hash, e.Seq
+		result.Entries++
+		return nil
+	})
+	return result, err
+}
+
+// walk calls fn for every entry of the log at path, oldest first.
+func walk(path string, fn func(name string, line int, e Entry) error) error {
+	for _, name := range Files(path) {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		scanner := newScanner(f)
+		for n := 1; scanner.Scan(); n++ {
+			raw := bytes.TrimSpace(scanner.Bytes())
+			if len(raw) == 0 {
+				continue
+			}
+			var e Entry
+			if err := json.Unmarshal(raw, &e); err != nil {
+				f.Close()
+				return fmt.Errorf("%s:%d: not a valid entry: %w", name, n, err)
+			}
+			if err := fn(name, n, e); err != nil {
+				f.Close()
+				return err
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// maxParamString bounds a string kept in recorded parameters.
+const maxParamString = 256
+
+// secretKeys name parameters whose values are never recorded: credentials,
+// and content such as file data, typed text and clipboard text. Keys are
+// compared in lower case without underscores.
+var secretKeys = map[string]bool{
+	"secret": true, "sharedsecret": true, "password": true, "passphrase": true,
+	"token": true, "hmac": true, "signature": true, "code": true, "privatekey": true,
+	"key": true, "pin": true,
+	"content": true, "contents": true, "text": true, "data": true, "payload": true,
+	"clipboard": true,
+}
+
+// Redact returns params, a JSON value, with secrets and contents replaced by
+// a note of their size and long strings shortened. Input that is not JSON is
+// recorded only by its size.
+func Redact(params []byte) json.RawMessage {
+	if len(params) == 0 {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(params, &v); err != nil {
+		return mustMarshal(redactedNote(len(params)))
+	}
+	return mustMarshal(redactValue(v))
+}
+
+func redactValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if secretKeys[strings.ReplaceAll(strings.ToLower(k), "_", "")] {
+				t[k] = redactedNote(sizeOf(val))
+				continue
+			}
+			t[k] = redactValue(val)
+		}
+		return t
+	case []interface{}:
+		for i := range t {
+			t[i] = redactValue(t[i])
+		}
+		return t
+	case string:
+		if len(t) > maxParamString {
+			return t[:maxParamString] + "…"
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+func redactedNote(size int) string {
+	return fmt.Sprintf("[redacted, %d bytes]", size)
+}
+
+// sizeOf is the encoded size of a redacted value.
+func sizeOf(v interface{}) int {
+	if s, ok := v.(string); ok {
+		return len(s)
+	}
+	data, _ := json.Marshal(v)
+	return len(data)
+}
+
+func mustMarshal(v interface{}) json.RawMessage {
+	data, _ := json.Marshal(v)
+	return data
+}
@@ -1,11 +1,15 @@
 package auth
 
 import (
+	"errors"
 	"log/slog"
+	"sort"
 	"sync"
 	"time"
 
+	"LinqoraHost/internal/ca"
 	"LinqoraHost/internal/config"
+	"LinqoraHost/internal/events"
 	"LinqoraHost/internal/interfaces"
 )
 
@@ -14,6 +18,39 @@ const (
 	maxAuthAttemptsPerMinute = 5
 )
 
+// Reasons recorded in Decision.
+const (
+	DecisionOperator         = "operator"
+	DecisionChallengeInvalid = "challenge_invalid"
+	DecisionRevoked          = "revoked"
+	DecisionPaired           = "paired"
+	DecisionRemote           = "remote"
+	DecisionExpired          = "expired"
+)
+
+// pendingRequestTTL bounds how long an unanswered request stays listed.
+const pendingRequestTTL = 10 * time.Minute
+
+// Decision records an authorization outcome for a device.
+type Decision struct {
+	DeviceID   string   `json:"deviceId"`
+	DeviceName string   `json:"deviceName"`
+	IP         string   `json:"ip,omitempty"`
+	Approved   bool     `json:"approved"`
+	Reason     string   `json:"reason"`
+	Scopes     []string `json:"scopes,omitempty"`
+	// By names the admin device or API token behind a remote decision.
+	By string `json:"by,omitempty"`
+	// ExpiresAt ends a time-limited approval.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// DecisionTopic carries every approval, rejection and revocation.
+var DecisionTopic = events.NewTopic[Decision]("auth_decision")
+
+// PendingRequestTopic carries each new request that waits for approval.
+var PendingRequestTopic = events.NewTopic[interfaces.PendingAuthRequest]("auth_pending")
+
 // authAttemptRecord tracks the number of auth attempts from a single IP.
 type authAttemptRecord struct {
 	Count        int
@@ -28,6 +65,8 @@ type AuthManager struct {
 	pendingResult map[string]bool
 	challenges    *ChallengeStore
 	authAttempts  map[string]*authAttemptRecord
+	ca            *ca.Authority
+	bus           *events.Bus
 	mu            sync.Mutex
 }
 
@@ -43,6 +82,21 @@ func NewAuthManager(cfg *config.ServerConfig, authChan chan<- interfaces.Pending
 	}
 }
 
+// SetEventBus makes the manager publish its decisions to bus.
+func (am *AuthManager) SetEventBus(bus *events.Bus) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.bus = bus
+}
+
+// publish sends a decision to the event bus, if one is set.
+func (am *AuthManager) publish(d Decision) {
+	am.mu.Lock()
+	bus := am.bus
+	am.mu.Unlock()
+	events.Publish(bus, DecisionTopic, d)
+}
+
 // cleanupAttempts removes attempt records older than 2 minutes.
 // Must be called with am.mu held.
 func (am *AuthManager) cleanupAttempts() {
@@ -57,6 +111,15 @@ func (am *AuthManager) cleanupAttempts() {
 // RequestAuthorization initiates a new authorization flow for a device.
 // It returns true if the request was successfully queued or if the device is already trusted.
 func (am *AuthManager) RequestAuthorization(deviceName, deviceID, ip string) bool {
+	// Hitting the rate limit counts as a failure. It is recorded once am.mu
+	// is released, as recordFailure takes it too.
+	limited := false
+	defer func() {
+		if limited {
+			am.recordFailure(ip, deviceID, deviceName, FailureRateLimited)
+		}
+	}()
+
 	am.mu.Lock()
 	defer am.mu.Unlock()
 
@@ -64,16 +127,18 @@ func (am *AuthManager) RequestAuthorization(deviceName, deviceID, ip string) boo
 	am.cleanupAttempts()
 
 	// Check per-IP rate limit
-	record, exists := am.authAttempts[ip]
+	addr := hostOf(ip)
+	record, exists := am.authAttempts[addr]
 	if exists && time.Since(record.FirstAttempt) < time.Minute {
 		if record.Count >= maxAuthAttemptsPerMinute {
 			slog.Warn("Auth rate limit exceeded", "ip", ip, "device", deviceName, "attempts", record.Count)
+			limited = true
 			return false
 		}
 	}
 
 	// Check if device is already authorized
-	if _, exists := am.config.AuthorizedDevs[deviceID]; exists {
+	if _, exists := am.activeDevice(deviceID); exists {
 		slog.Info("Device already authorized", "device", deviceName)
 		return true
 	}
@@ -86,7 +151,7 @@ func (am *AuthManager) RequestAuthorization(deviceName, deviceID, ip string) boo
 
 	// Increment attempt counter
 	if record == nil || time.Since(record.FirstAttempt) >= time.Minute {
-		am.authAttempts[ip] = &authAttemptRecord{Count: 1, FirstAttempt: time.Now()}
+		am.authAttempts[addr] = &authAttemptRecord{Count: 1, FirstAttempt: time.Now()}
 	} else {
 		record.Count++
 	}
@@ -107,7 +172,10 @@ func (am *AuthManager) RequestAuthorization(deviceName, deviceID, ip string) boo
 	}
 
 	// Send request to channel asynchronously
+	bus := am.bus
 	go func() {
+		events.Publish(bus, PendingRequestTopic, *request)
+
 		select {
 		case am.pendingChan <- *request:
 			slog.Info("Auth request sent to console", "device", deviceName)
@@ -119,16 +187,100 @@ func (am *AuthManager) RequestAuthorization(deviceName, deviceID, ip string) boo
 	return true
 }
 
-// RespondToAuthRequest records the user's decision (approve/reject) for a pending request.
-func (am *AuthManager) RespondToAuthRequest(deviceID string, approved bool) {
-	am.mu.Lock()
-	defer am.mu.Unlock()
+// RespondToAuthRequest records the user's decision (approve/reject) for a
+// pending request. An approved device is granted exactly the given scopes
+// until expiresAt, or for good when it is nil; both are ignored on
+// rejection.
+func (am *AuthManager) RespondToAuthRequest(deviceID string, approved bool, scopes []string, expiresAt *time.Time) {
+	am.respond(deviceID, approved, scopes, expiresAt, DecisionOperator, "")
+}
 
+// ResolvePending records a decision made remotely, by an admin device or an
+// API token named by. It reports whether a request was pending.
+func (am *AuthManager) ResolvePending(deviceID string, approved bool, scopes []string, expiresAt *time.Time, by string) bool {
+	return am.respond(deviceID, approved, scopes, expiresAt, DecisionRemote, by)
+}
+
+func (am *AuthManager) respond(deviceID string, approved bool, scopes []string, expiresAt *time.Time, reason, by string) bool {
+	if !approved {
+		scopes, expiresAt = nil, nil
+	}
+
+	am.mu.Lock()
 	request, exists := am.pendingAuth[deviceID]
 	if !exists {
+		am.mu.Unlock()
 		slog.Warn("No pending auth request", "device_id", deviceID)
-		return
+		return false
 	}
+	am.recordDecision(request, approved, scopes, expiresAt)
+	am.mu.Unlock()
+
+	am.publish(Decision{
+		DeviceID:   deviceID,
+		DeviceName: request.DeviceName,
+		IP:         request.IP,
+		Approved:   approved,
+		Reason:     reason,
+		Scopes:     scopes,
+		By:         by,
+		ExpiresAt:  expiresAt,
+	})
+	return true
+}
+
+// ListPending returns the requests waiting for a decision, oldest first.
+// Requests left unanswered for longer than pendingRequestTTL are dropped.
+func (am *AuthManager) ListPending() []interfaces.PendingAuthRequest {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	cutoff := time.Now().Add(-pendingRequestTTL)
+	pending := make([]interfaces.PendingAuthRequest, 0, len(am.pendingAuth))
+	for id, request := range am.pendingAuth {
+		if request.RequestTime.Before(cutoff) {
+			delete(am.pendingAuth, id)
+			continue
+		}
+		pending = append(pending, *request)
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].RequestTime.Before(pending[j].RequestTime)
+	})
+	return pending
+}
+
+// IsAdmin reports whether an authorised device may manage other devices.
+func (am *AuthManager) IsAdmin(deviceID string) bool {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	device, exists := am.activeDevice(deviceID)
+	return exists && device.Admin
+}
+
+// SetAdmin allows or stops an authorised device from managing other devices.
+func (am *AuthManager) SetAdmin(deviceID string, admin bool) error {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	device, ok := am.config.AuthorizedDevs[deviceID]
+	if !ok {
+		return errors.New("device is not authorized")
+	}
+	device.Admin = admin
+	am.config.AuthorizedDevs[deviceID] = device
+	if err := am.config.SaveConfig(); err != nil {
+		return err
+	}
+	slog.Info("Admin rights changed", "device_id", deviceID, "admin", admin)
+	return nil
+}
+
+// recordDecision stores the outcome of a pending request and persists newly
+// approved devices. Must be called with am.mu held.
+func (am *AuthManager) recordDecision(request *interfaces.PendingAuthRequest, approved bool, scopes []string, expiresAt *time.Time) {
+	deviceID := request.DeviceID
 
 	delete(am.pendingAuth, deviceID)
 	am.pendingResult[deviceID] = approved
@@ -139,6 +291,8 @@ func (am *AuthManager) RespondToAuthRequest(deviceID string, approved bool) {
 			DeviceName: request.DeviceName,
 			DeviceID:   deviceID,
 			LastAuth:   time.Now().Format("2006-01-02 15:04:05"),
+			Scopes:     append([]string{}, scopes...),
+			ExpiresAt:  expiresAt,
 		}
 
 		if err := am.config.SaveConfig(); err != nil {
@@ -147,15 +301,25 @@ func (am *AuthManager) RespondToAuthRequest(deviceID string, approved bool) {
 	}
 }
 
-// IsAuthorized checks if the given device ID is in the trusted devices list.
+// IsAuthorized checks if the given device ID is in the trusted devices list
+// and its authorization has not expired.
 func (am *AuthManager) IsAuthorized(deviceID string) bool {
 	am.mu.Lock()
 	defer am.mu.Unlock()
 
-	_, exists := am.config.AuthorizedDevs[deviceID]
+	_, exists := am.activeDevice(deviceID)
 	return exists
 }
 
+// HasScope reports whether an authorised device has been granted scope.
+func (am *AuthManager) HasScope(deviceID, scope string) bool {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	device, exists := am.activeDevice(deviceID)
+	return exists && device.HasScope(scope)
+}
+
 // CheckPendingResult retrieves and clears the result of a recently completed authorization request.
 func (am *AuthManager) CheckPendingResult(deviceID string) (bool, bool) {
 	am.mu.Lock()
@@ -168,19 +332,62 @@ func (am *AuthManager) CheckPendingResult(deviceID string) (bool, bool) {
 	return result, exists
 }
 
-// RevokeAuth removes a device from the trusted devices list.
+// RevokeAuth removes a device from the trusted devices list and denies its
+// client certificate.
 func (am *AuthManager) RevokeAuth(deviceID string) {
 	am.mu.Lock()
-	defer am.mu.Unlock()
-
-	if _, exists := am.config.AuthorizedDevs[deviceID]; exists {
-		delete(am.config.AuthorizedDevs, deviceID)
-		slog.Info("Authorization revoked", "device_id", deviceID)
+	device, exists := am.config.RevokeDevice(deviceID)
+	if exists {
+		slog.Info("Authorization revoked", "device_id", deviceID, "cert_serial", device.CertSerial)
 
 		if err := am.config.SaveConfig(); err != nil {
 			slog.Error("Error saving config", "err", err)
 		}
 	}
+	am.mu.Unlock()
+
+	if exists {
+		am.publish(Decision{
+			DeviceID:   deviceID,
+			DeviceName: device.DeviceName,
+			Reason:     DecisionRevoked,
+		})
+	}
+}
+
+// Reload adopts the settings in fresh, typically the config file after a CLI
+// edit, so that the server's next save keeps them. Devices, API tokens, the
+// shared secret and the E2EE switches apply at once; see
+// config.ServerConfig.RestartRequired for the rest. Devices missing from
+// fresh are reported as revoked, and their IDs are returned so that their
+// connections can be closed.
+//
+// The manager switches to fresh rather than copying it over the config it
+// had, which other goroutines read without am.mu; the server adopts fresh
+// through WSServer.UseConfig.
+func (am *AuthManager) Reload(fresh *config.ServerConfig) []string {
+	am.mu.Lock()
+	var removed []config.DeviceAuth
+	for id, device := range am.config.AuthorizedDevs {
+		if _, ok := fresh.AuthorizedDevs[id]; !ok {
+			removed = append(removed, device)
+			fresh.DenyCert(device.CertSerial)
+		}
+	}
+	am.config = fresh
+	am.mu.Unlock()
+
+	ids := make([]string, 0, len(removed))
+	for _, device := range removed {
+		slog.Info("Authorization revoked by config reload", "device_id", device.DeviceID)
+		am.publish(Decision{
+			DeviceID:   device.DeviceID,
+			DeviceName: device.DeviceName,
+			Reason:     DecisionRevoked,
+		})
+		ids = append(ids, device.DeviceID)
+	}
+	return ids
 }
 
 // ListDevices returns a list of all currently authorized devices.
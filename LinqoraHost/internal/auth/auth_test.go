@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"testing"
+
+	"LinqoraHost/internal/config"
+	"LinqoraHost/internal/events"
+)
+
+func TestReloadReportsRemovedDevices(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.AuthorizedDevs["kept"] = config.DeviceAuth{DeviceID: "kept", Scopes: config.AllScopes}
+	cfg.AuthorizedDevs["gone"] = config.DeviceAuth{DeviceID: "gone", DeviceName: "Old Phone"}
+	am := NewAuthManager(cfg, nil)
+
+	bus := events.NewBus()
+	am.SetEventBus(bus)
+	var decisions []Decision
+	events.Subscribe(bus, DecisionTopic, func(d Decision) { decisions = append(decisions, d) })
+
+	fresh := config.DefaultConfig()
+	fresh.AuthorizedDevs["kept"] = config.DeviceAuth{DeviceID: "kept", Scopes: []string{config.ScopeMedia}}
+	fresh.AuthorizedDevs["new"] = config.DeviceAuth{DeviceID: "new"}
+	fresh.APITokens = []config.APIToken{{Name: "ha"}}
+	fresh.SharedSecret = "rotated"
+
+	removed := am.Reload(fresh)
+	if len(removed) != 1 || removed[0] != "gone" {
+		t.Fatalf("Expected gone to be reported, got %v", removed)
+	}
+	if am.IsAuthorized("gone") || !am.IsAuthorized("new") {
+		t.Error("Expected the reloaded device list to be in effect")
+	}
+	if am.HasScope("kept", config.ScopePower) {
+		t.Error("Expected reloaded scopes to replace the old ones")
+	}
+	if am.config != fresh || len(am.config.APITokens) != 1 || am.config.SharedSecret != "rotated" {
+		t.Error("Expected API tokens and settings to be reloaded")
+	}
+	if cfg.SharedSecret != "" || len(cfg.AuthorizedDevs) != 2 {
+		t.Error("Expected the previous config, which others may be reading, to be left alone")
+	}
+	if len(decisions) != 1 || decisions[0].Reason != DecisionRevoked || decisions[0].DeviceName != "Old Phone" {
+		t.Errorf("Expected one revocation decision, got %+v", decisions)
+	}
+}
+
+func TestSetAdmin(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.AuthorizedDevs["phone"] = config.DeviceAuth{DeviceID: "phone", StepUpKey: "kept"}
+	am := NewAuthManager(cfg, nil)
+
+	if err := am.SetAdmin("phone", true); err != nil || !am.IsAdmin("phone") {
+		t.Fatalf("Expected phone to become an admin, got %v", err)
+	}
+	if am.config.AuthorizedDevs["phone"].StepUpKey != "kept" {
+		t.Error("Expected the rest of the device to be kept")
+	}
+	if err := am.SetAdmin("phone", false); err != nil || am.IsAdmin("phone") {
+		t.Errorf("Expected phone to lose admin rights, got %v", err)
+	}
+	if err := am.SetAdmin("unknown", true); err == nil {
+		t.Error("Expected an unknown device to be refused")
+	}
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"log/slog"
+	"time"
+
+	"LinqoraHost/internal/ca"
+	"LinqoraHost/internal/interfaces"
+)
+
+// MessageTypeClientCertificate carries a certificate issued to the device.
+const MessageTypeClientCertificate = "client_certificate"
+
+// ClientCertificate is the data of a "client_certificate" message.
+type ClientCertificate struct {
+	// Certificate is the device's new certificate, CA the authority to
+	// present it with; both PEM.
+	Certificate string    `json:"certificate"`
+	CA          string    `json:"ca"`
+	Serial      string    `json:"serial"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+// SetCA lets the manager issue client certificates. Without one, requests
+// for a certificate are ignored.
+func (am *AuthManager) SetCA(authority *ca.Authority) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.ca = authority
+}
+
+// CertificateDevice reports whether serial is the current certificate of an
+// authorized device and not on the deny list.
+func (am *AuthManager) CertificateDevice(deviceID, serial string) bool {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	device, ok := am.activeDevice(deviceID)
+	return ok && serial != "" && device.CertSerial == serial && !am.config.CertRevoked(serial)
+}
+
+// issueCertificate signs csr for an authorized device, makes it the
+// device's only valid certificate and sends it. It does nothing when csr is
+// empty or no CA is set.
+func (am *AuthManager) issueCertificate(client interfaces.WSClient, deviceID, csr string) {
+	if csr == "" {
+		return
+	}
+	am.mu.Lock()
+	authority := am.ca
+	am.mu.Unlock()
+	if authority == nil {
+		slog.Warn("Client certificate requested, but no CA is available (TLS is off)", "device_id", deviceID)
+		return
+	}
+
+	issued, err := authority.Sign(csr, deviceID)
+	if err != nil {
+		slog.Warn("Client certificate not issued", "device_id", deviceID, "err", err)
+		client.SendError(MessageTypeClientCertificate, err.Error(), 400)
+		return
+	}
+
+	am.mu.Lock()
+	device, ok := am.config.AuthorizedDevs[deviceID]
+	if ok {
+		device.CertSerial = issued.Serial
+		am.config.AuthorizedDevs[deviceID] = device
+		if err := am.config.SaveConfig(); err != nil {
+			slog.Error("Error saving config", "err", err)
+		}
+	}
+	am.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	slog.Info("Client certificate issued", "device_id", deviceID, "serial", issued.Serial, "expires", issued.NotAfter.Format(time.RFC3339))
+	client.SendSuccess(MessageTypeClientCertificate, ClientCertificate{
+		Certificate: issued.CertPEM,
+		CA:          authority.CertPEM(),
+		Serial:      issued.Serial,
+		ExpiresAt:   issued.NotAfter,
+	})
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+
+	"LinqoraHost/internal/ca"
+	"LinqoraHost/internal/config"
+)
+
+func testCSR(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))
+}
+
+func TestClientCertificateSkipsChallengeUntilRevoked(t *testing.T) {
+	usePairingDir(t)
+	cfg := config.DefaultConfig()
+	cfg.SharedSecret = "secret"
+	cfg.AuthorizedDevs["phone-1"] = config.DeviceAuth{DeviceID: "phone-1", DeviceName: "Phone"}
+	am := NewAuthManager(cfg, nil)
+	authority, err := ca.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	am.SetCA(authority)
+
+	request, _ := json.Marshal(AuthRequestData{DeviceID: "phone-1", DeviceName: "Phone", VersionClient: MinVersionClient, CSR: testCSR(t)})
+
+	// Without a certificate the device gets the challenge.
+	plain := &fakeClient{}
+	am.HandleAuthRequest(plain, rawMessage(request))
+	if plain.authorized || len(plain.certificates) != 0 {
+		t.Fatal("Expected the challenge, not access or a certificate")
+	}
+
+	holder := &fakeClient{certDeviceID: "phone-1"}
+	am.HandleAuthRequest(holder, rawMessage(request))
+	if !holder.authorized || holder.last().Code != AuthStatusAuthorized {
+		t.Fatalf("Expected a certificate holder to skip the challenge, got %+v", holder.responses)
+	}
+	if len(holder.certificates) != 1 || holder.certificates[0].CA != authority.CertPEM() {
+		t.Fatalf("Expected a renewed certificate, got %+v", holder.certificates)
+	}
+	serial := holder.certificates[0].Serial
+	if !am.CertificateDevice("phone-1", serial) || am.CertificateDevice("phone-1", "other") {
+		t.Error("Expected only the newest certificate to identify the device")
+	}
+
+	am.RevokeAuth("phone-1")
+	if am.CertificateDevice("phone-1", serial) || !cfg.CertRevoked(serial) {
+		t.Error("Expected revocation to deny the certificate")
+	}
+	// Approving the device again does not bring the old certificate back.
+	cfg.AuthorizedDevs["phone-1"] = config.DeviceAuth{DeviceID: "phone-1", CertSerial: serial}
+	if am.CertificateDevice("phone-1", serial) {
+		t.Error("Expected a denied certificate to stay denied")
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"testing"
 	"time"
+
+	"LinqoraHost/internal/config"
 )
 
 func TestChallengeStoreGenerateAndVerify(t *testing.T) {
@@ -82,3 +85,36 @@ func TestChallengeStoreTTLExpiry(t *testing.T) {
 		t.Fatal("expired challenge should not verify")
 	}
 }
+
+func TestAuthCheckNeedsAnsweredChallenge(t *testing.T) {
+	useLockoutDir(t)
+	cfg := config.DefaultConfig()
+	cfg.SharedSecret = "secret"
+	cfg.AuthorizedDevs["phone-1"] = config.DeviceAuth{DeviceID: "phone-1", Scopes: config.AllScopes}
+	am := NewAuthManager(cfg, nil)
+	request, _ := json.Marshal(AuthRequestData{DeviceID: "phone-1", DeviceName: "Phone", VersionClient: MinVersionClient})
+
+	// Claiming an authorised device's ID and skipping the challenge.
+	impostor := &fakeClient{}
+	am.HandleAuthRequest(impostor, rawMessage(request))
+	if impostor.challenge == "" {
+		t.Fatal("Expected a challenge")
+	}
+	am.HandleAuthCheck(impostor)
+	if impostor.authorized || impostor.deviceID != "" || impostor.last().Code != AuthStatusNotAuthorized {
+		t.Fatalf("Expected no access before the challenge is answered, got %+v", impostor.last())
+	}
+
+	device := &fakeClient{}
+	am.HandleAuthRequest(device, rawMessage(request))
+	answer, _ := json.Marshal(map[string]string{"token": device.challenge, "hmac": computeHMAC(device.challenge, "secret")})
+	am.HandleChallengeResponse(device, rawMessage(answer))
+	if !device.authorized || device.deviceID != "phone-1" {
+		t.Fatalf("Expected the answered challenge to grant access, got %+v", device.last())
+	}
+	device.authorized = false
+	am.HandleAuthCheck(device)
+	if !device.authorized {
+		t.Error("Expected auth_check to confirm a proven device")
+	}
+}
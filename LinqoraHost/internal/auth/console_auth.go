@@ -2,9 +2,12 @@ package auth
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	"LinqoraHost/internal/config"
 	"LinqoraHost/internal/interfaces"
 )
 
@@ -39,7 +42,8 @@ func (h *ConsoleAuthHandler) ProcessAuthRequests(authChan <-chan interfaces.Pend
 			fmt.Printf("ID:      %s\n", req.DeviceID)
 			fmt.Printf("IP:      %s\n", req.IP)
 			fmt.Printf("Time:    %s\n\n", req.RequestTime.Format("15:04:05"))
-			fmt.Printf("Allow connection? (y/n): ")
+			fmt.Printf("Scopes:  %s\n", strings.Join(config.AllScopes, ", "))
+			fmt.Printf("Allow connection? (y = all scopes, y <scope,...> = selected, add \"for 1h|midnight|<n>d\" to limit it, n = reject): ")
 
 			// Save the request in the map
 			h.authRequests[req.DeviceID] = req
@@ -57,7 +61,7 @@ func (h *ConsoleAuthHandler) ProcessAuthRequests(authChan <-chan interfaces.Pend
 					ConsoleMutex.Unlock()
 
 					// Cancel the request
-					h.authManager.RespondToAuthRequest(req.DeviceID, false)
+					h.authManager.RespondToAuthRequest(req.DeviceID, false, nil, nil)
 
 					// Delete the request and timer
 					delete(h.authRequests, req.DeviceID)
@@ -93,11 +97,40 @@ func (h *ConsoleAuthHandler) ProcessAuthResponse(command string) bool {
 		return false
 	}
 
-	// Check if the command is empty
-	if command != "y" && command != "n" {
+	// Accept "y", "n" or "y <scopes>", each approval optionally followed by
+	// "for <expiry>"
+	fields := strings.Fields(command)
+	if len(fields) == 0 || (fields[0] != "y" && fields[0] != "n") {
 		return false
 	}
 
+	var expiresAt *time.Time
+	if i := slices.Index(fields, "for"); i > 0 {
+		if fields[0] != "y" {
+			return false
+		}
+		parsed, err := config.ParseExpiry(strings.Join(fields[i+1:], ""), time.Now())
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return true
+		}
+		expiresAt = parsed
+		fields = fields[:i]
+	}
+
+	scopes := config.AllScopes
+	if len(fields) > 1 {
+		if fields[0] != "y" {
+			return false
+		}
+		parsed, err := config.ParseScopes(strings.Join(fields[1:], ""))
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return true
+		}
+		scopes = parsed
+	}
+
 	// Selecting the latest request
 	var latestReq interfaces.PendingAuthRequest
 	var latestDeviceID string
@@ -111,7 +144,7 @@ func (h *ConsoleAuthHandler) ProcessAuthResponse(command string) bool {
 		}
 	}
 
-	approved := command == "y"
+	approved := fields[0] == "y"
 
 	// Stop the timer for the latest request
 	if timer, exists := h.authTimers[latestDeviceID]; exists && timer != nil {
@@ -120,10 +153,14 @@ func (h *ConsoleAuthHandler) ProcessAuthResponse(command string) bool {
 	}
 
 	// Respond to the authorization request
-	h.authManager.RespondToAuthRequest(latestDeviceID, approved)
-
-	if approved {
-		fmt.Printf("Authorization for device %s approved\n", latestReq.DeviceName)
+	h.authManager.RespondToAuthRequest(latestDeviceID, approved, scopes, expiresAt)
+
+	if approved && expiresAt != nil {
+		fmt.Printf("Authorization for device %s approved until %s (scopes: %s)\n",
+			latestReq.DeviceName, expiresAt.Format("2006-01-02 15:04"), strings.Join(scopes, ","))
+	} else if approved {
+		fmt.Printf("Authorization for device %s approved (scopes: %s)\n",
+			latestReq.DeviceName, strings.Join(scopes, ","))
 	} else {
 		fmt.Printf("Authorization for device %s rejected\n", latestReq.DeviceName)
 	}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"log/slog"
+	"time"
+
+	"LinqoraHost/internal/config"
+)
+
+// activeDevice returns the entry of an authorized device, treating one whose
+// time-limited authorization has ended as unknown even before the sweeper
+// removes it. Must be called with am.mu held.
+func (am *AuthManager) activeDevice(deviceID string) (config.DeviceAuth, bool) {
+	device, ok := am.config.AuthorizedDevs[deviceID]
+	if !ok || device.Expired(time.Now()) {
+		return config.DeviceAuth{}, false
+	}
+	return device, true
+}
+
+// SweepExpired revokes every device whose authorization has expired, like
+// RevokeAuth, and returns their IDs so that their sessions can be closed.
+func (am *AuthManager) SweepExpired() []string {
+	am.mu.Lock()
+	var expired []config.DeviceAuth
+	for _, id := range am.config.ExpiredDevices(time.Now()) {
+		if device, ok := am.config.RevokeDevice(id); ok {
+			expired = append(expired, device)
+		}
+	}
+	if len(expired) > 0 {
+		if err := am.config.SaveConfig(); err != nil {
+			slog.Error("Error saving config", "err", err)
+		}
+	}
+	am.mu.Unlock()
+
+	ids := make([]string, 0, len(expired))
+	for _, device := range expired {
+		slog.Info("Authorization expired", "device", device.DeviceName, "device_id", device.DeviceID, "expired_at", device.ExpiresAt)
+		am.publish(Decision{
+			DeviceID:   device.DeviceID,
+			DeviceName: device.DeviceName,
+			Reason:     DecisionExpired,
+		})
+		ids = append(ids, device.DeviceID)
+	}
+	return ids
+}
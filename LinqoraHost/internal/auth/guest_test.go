@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"LinqoraHost/internal/config"
+	"LinqoraHost/internal/events"
+	"LinqoraHost/internal/interfaces"
+)
+
+func TestTimeLimitedApproval(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	am := NewAuthManager(config.DefaultConfig(), make(chan interfaces.PendingAuthRequest, 1))
+	if !am.RequestAuthorization("Guest", "guest", "10.0.0.2:1234") {
+		t.Fatal("RequestAuthorization refused")
+	}
+
+	until := time.Now().Add(time.Hour)
+	am.RespondToAuthRequest("guest", true, config.AllScopes, &until)
+	if !am.IsAuthorized("guest") || !am.HasScope("guest", config.ScopeMedia) {
+		t.Fatal("Expected the guest to be authorized until it expires")
+	}
+	if got := am.config.AuthorizedDevs["guest"].ExpiresAt; got == nil || !got.Equal(until) {
+		t.Errorf("Expected ExpiresAt %v, got %v", until, got)
+	}
+
+	past := time.Now().Add(-time.Second)
+	device := am.config.AuthorizedDevs["guest"]
+	device.ExpiresAt = &past
+	am.config.AuthorizedDevs["guest"] = device
+	if am.IsAuthorized("guest") || am.HasScope("guest", config.ScopeMedia) {
+		t.Error("Expected an expired guest to be refused before the sweep")
+	}
+}
+
+func TestSweepExpiredRevokes(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	cfg.AuthorizedDevs["gone"] = config.DeviceAuth{DeviceID: "gone", DeviceName: "Gone", ExpiresAt: &past, CertSerial: "42"}
+	cfg.AuthorizedDevs["guest"] = config.DeviceAuth{DeviceID: "guest", ExpiresAt: &future}
+	cfg.AuthorizedDevs["owner"] = config.DeviceAuth{DeviceID: "owner"}
+
+	bus := events.NewBus()
+	var decisions []Decision
+	defer events.Subscribe(bus, DecisionTopic, func(d Decision) { decisions = append(decisions, d) })()
+	am := NewAuthManager(cfg, nil)
+	am.SetEventBus(bus)
+
+	if got := am.SweepExpired(); len(got) != 1 || got[0] != "gone" {
+		t.Fatalf("SweepExpired = %v", got)
+	}
+	if _, ok := cfg.AuthorizedDevs["gone"]; ok {
+		t.Error("Expected the expired device to be removed")
+	}
+	if !cfg.CertRevoked("42") {
+		t.Error("Expected the expired device's certificate to be denied")
+	}
+	if len(cfg.AuthorizedDevs) != 2 {
+		t.Errorf("Expected the other devices to stay, got %v", cfg.AuthorizedDevs)
+	}
+	if len(decisions) != 1 || decisions[0].DeviceID != "gone" || decisions[0].Reason != DecisionExpired {
+		t.Errorf("Expected one expiry decision, got %+v", decisions)
+	}
+	if got := am.SweepExpired(); len(got) != 0 {
+		t.Errorf("Expected nothing left to sweep, got %v", got)
+	}
+}
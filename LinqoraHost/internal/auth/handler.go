@@ -28,6 +28,9 @@ type AuthRequestData struct {
 	DeviceName    string `json:"deviceName"`
 	IP            string `json:"ip"`
 	VersionClient string `json:"versionClient"`
+	// CSR, a PEM certificate signing request, asks for a client certificate
+	// once the device is authorized.
+	CSR string `json:"csr,omitempty"`
 }
 
 // IsVersionClientSupported checks if the client version meets the minimum requirements.
@@ -48,13 +51,20 @@ func (am *AuthManager) HandleAuthRequest(client interfaces.WSClient, msg interfa
 	var authData AuthRequestData
 	if err := json.Unmarshal(msg.GetData(), &authData); err != nil {
 		slog.Error("Error unmarshaling auth data", "err", err)
-		sendResponse(client, AuthStatusInvalidFormat, false, MessageTypeAuthResponse)
+		if !am.refuseLockedOut(client, "", MessageTypeAuthResponse) {
+			am.recordFailure(client.GetIP(), "", "", FailureMalformed)
+			sendResponse(client, AuthStatusInvalidFormat, false, MessageTypeAuthResponse)
+		}
 		return
 	}
 
 	deviceID := authData.DeviceID
+	if am.refuseLockedOut(client, deviceID, MessageTypeAuthResponse) {
+		return
+	}
 	if deviceID == "" {
 		slog.Warn("Empty device ID in auth request")
+		am.recordFailure(client.GetIP(), "", authData.DeviceName, FailureMalformed)
 		sendResponse(client, AuthStatusMissingDeviceID, false, MessageTypeAuthResponse)
 		return
 	}
@@ -62,15 +72,30 @@ func (am *AuthManager) HandleAuthRequest(client interfaces.WSClient, msg interfa
 	// Verify client compatibility.
 	if !am.IsVersionClientSupported(authData.VersionClient) {
 		slog.Warn("Unsupported client version", "version", authData.VersionClient)
+		am.recordFailure(client.GetIP(), deviceID, authData.DeviceName, FailureVersion)
 		sendResponse(client, AuthStatusUnsupportedVersion, false, MessageTypeAuthResponse)
 		return
 	}
 
 	slog.Info("Auth request received", "device", authData.DeviceName, "device_id", deviceID, "ip", client.GetIP())
 
-	client.SetDeviceID(deviceID)
+	// The connection is bound to the device only once it proves to be it.
+	// Without a shared secret there is nothing to prove.
+	client.ClaimDeviceID(deviceID, am.config.SharedSecret == "")
 	client.SetDeviceName(authData.DeviceName)
 
+	// A valid client certificate for this device stands in for the challenge.
+	if certID := client.CertDeviceID(); certID != "" {
+		if certID == deviceID && am.IsAuthorized(deviceID) {
+			slog.Info("Authenticated by client certificate", "device", authData.DeviceName, "device_id", deviceID)
+			am.clearFailures(client.GetIP(), deviceID)
+			grantAccess(client, deviceID, AuthStatusAuthorized)
+			am.issueCertificate(client, deviceID, authData.CSR)
+			return
+		}
+		slog.Warn("Client certificate does not match the device", "cert_device_id", certID, "device_id", deviceID)
+	}
+
 	// If a shared secret is configured, issue a challenge instead of going
 	// straight to pending approval or auto-authorise.
 	if am.config.SharedSecret != "" {
@@ -88,20 +113,18 @@ func (am *AuthManager) HandleAuthRequest(client interfaces.WSClient, msg interfa
 	// Check if the device is already authorized (no shared secret path).
 	if am.IsAuthorized(deviceID) {
 		slog.Info("Device already authorized", "device", authData.DeviceName)
-		sendResponse(client, AuthStatusAuthorized, true, MessageTypeAuthResponse)
+		grantAccess(client, deviceID, AuthStatusAuthorized)
+		am.issueCertificate(client, deviceID, authData.CSR)
 		return
 	}
 
 	// Request manual authorization if not already authorized.
 	pending := am.RequestAuthorization(authData.DeviceName, deviceID, client.GetIP())
 	if pending {
-		client.SetDeviceID(deviceID)
-		client.SetDeviceName(authData.DeviceName)
-
 		sendResponse(client, AuthStatusPending, false, MessageTypeAuthPending)
 
 		// Start background monitoring for the user's decision.
-		go am.checkAuthResultPeriodically(client)
+		go am.checkAuthResultPeriodically(client, deviceID, authData.CSR)
 		slog.Info("Auth request pending", "device", authData.DeviceName)
 	} else {
 		sendResponse(client, AuthStatusRequestFailed, false, MessageTypeAuthResponse)
@@ -109,8 +132,10 @@ func (am *AuthManager) HandleAuthRequest(client interfaces.WSClient, msg interfa
 	}
 }
 
-// checkAuthResultPeriodically polls for authorization results until approval, rejection, or timeout.
-func (am *AuthManager) checkAuthResultPeriodically(client interfaces.WSClient) {
+// checkAuthResultPeriodically polls for the authorization result of deviceID
+// until approval, rejection, or timeout. On approval the client is bound to
+// the device and a client certificate is issued for csr, if given.
+func (am *AuthManager) checkAuthResultPeriodically(client interfaces.WSClient, deviceID, csr string) {
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
@@ -119,15 +144,15 @@ func (am *AuthManager) checkAuthResultPeriodically(client interfaces.WSClient) {
 	for {
 		select {
 		case <-ticker.C:
-			deviceID := client.GetDeviceID()
-			if deviceID == "" {
+			if client.IsClosed() {
 				return
 			}
 
 			result, exists := am.CheckPendingResult(deviceID)
 			if exists {
 				if result {
-					sendResponse(client, AuthStatusApproved, true, MessageTypeAuthResponse)
+					grantAccess(client, deviceID, AuthStatusApproved)
+					am.issueCertificate(client, deviceID, csr)
 				} else {
 					sendResponse(client, AuthStatusRejected, false, MessageTypeAuthResponse)
 				}
@@ -141,24 +166,30 @@ func (am *AuthManager) checkAuthResultPeriodically(client interfaces.WSClient) {
 	}
 }
 
-// HandleAuthCheck verifies the current authorization status of a connected client.
+// HandleAuthCheck verifies the current authorization status of a connected
+// client. Access is granted only for a device the client has proven to be:
+// a claim whose challenge is unanswered gets its status and nothing more.
 func (am *AuthManager) HandleAuthCheck(client interfaces.WSClient) {
-	deviceID := client.GetDeviceID()
+	if deviceID := client.GetDeviceID(); deviceID != "" && am.IsAuthorized(deviceID) {
+		grantAccess(client, deviceID, AuthStatusAuthorized)
+		return
+	}
 
-	if deviceID == "" {
+	deviceID, proven := client.ClaimedDeviceID()
+	if deviceID == "" || !proven {
 		sendResponse(client, AuthStatusNotAuthorized, false, MessageTypeAuthResponse)
 		return
 	}
 
 	if am.IsAuthorized(deviceID) {
-		sendResponse(client, AuthStatusAuthorized, true, MessageTypeAuthResponse)
+		grantAccess(client, deviceID, AuthStatusAuthorized)
 		return
 	}
 
 	result, exists := am.CheckPendingResult(deviceID)
 	if exists {
 		if result {
-			sendResponse(client, AuthStatusApproved, true, MessageTypeAuthResponse)
+			grantAccess(client, deviceID, AuthStatusApproved)
 		} else {
 			sendResponse(client, AuthStatusRejected, false, MessageTypeAuthResponse)
 		}
@@ -174,41 +205,66 @@ func (am *AuthManager) HandleChallengeResponse(client interfaces.WSClient, msg i
 	var data struct {
 		Token string `json:"token"`
 		HMAC  string `json:"hmac"`
+		CSR   string `json:"csr,omitempty"`
+	}
+	deviceID, _ := client.ClaimedDeviceID()
+	if am.refuseLockedOut(client, deviceID, MessageTypeAuthResponse) {
+		return
 	}
 	if err := json.Unmarshal(msg.GetData(), &data); err != nil {
 		slog.Error("Error parsing challenge response", "err", err)
+		am.recordFailure(client.GetIP(), deviceID, client.GetDeviceName(), FailureMalformed)
 		sendResponse(client, AuthStatusInvalidFormat, false, MessageTypeAuthResponse)
 		return
 	}
 
-	deviceID := client.GetDeviceID()
 	if deviceID == "" {
+		am.recordFailure(client.GetIP(), "", "", FailureMalformed)
 		sendResponse(client, AuthStatusMissingDeviceID, false, MessageTypeAuthResponse)
 		return
 	}
 
 	if !am.challenges.Verify(deviceID, data.Token, data.HMAC, am.config.SharedSecret) {
 		slog.Warn("Challenge HMAC mismatch", "device", client.GetDeviceName())
+		am.recordFailure(client.GetIP(), deviceID, client.GetDeviceName(), FailureChallenge)
+		am.publish(Decision{
+			DeviceID:   deviceID,
+			DeviceName: client.GetDeviceName(),
+			IP:         client.GetIP(),
+			Reason:     DecisionChallengeInvalid,
+		})
 		sendResponse(client, AuthStatusChallengeInvalid, false, MessageTypeAuthResponse)
 		return
 	}
 
 	slog.Info("Challenge verified", "device", client.GetDeviceName(), "device_id", deviceID)
+	am.clearFailures(client.GetIP(), deviceID)
+	client.ClaimDeviceID(deviceID, true)
 
 	if am.IsAuthorized(deviceID) {
-		sendResponse(client, AuthStatusAuthorized, true, MessageTypeAuthResponse)
+		grantAccess(client, deviceID, AuthStatusAuthorized)
+		am.issueCertificate(client, deviceID, data.CSR)
 		return
 	}
 
 	pending := am.RequestAuthorization(client.GetDeviceName(), deviceID, client.GetIP())
 	if pending {
 		sendResponse(client, AuthStatusPending, false, MessageTypeAuthPending)
-		go am.checkAuthResultPeriodically(client)
+		go am.checkAuthResultPeriodically(client, deviceID, data.CSR)
 	} else {
 		sendResponse(client, AuthStatusRequestFailed, false, MessageTypeAuthResponse)
 	}
 }
 
+// grantAccess binds the client to deviceID, tells it it is authorised and
+// lets the transport know, so that it can set up session state such as a
+// resume token.
+func grantAccess(client interfaces.WSClient, deviceID string, code int) {
+	client.SetDeviceID(deviceID)
+	sendResponse(client, code, true, MessageTypeAuthResponse)
+	client.MarkAuthorized()
+}
+
 // sendResponse helper function to transmit authorization status to the client.
 func sendResponse(client interfaces.WSClient, code int, success bool, typeResponse string) {
 	response := AuthResponse{
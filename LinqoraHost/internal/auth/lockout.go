@@ -0,0 +1,343 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"LinqoraHost/internal/config"
+	"LinqoraHost/internal/events"
+	"LinqoraHost/internal/interfaces"
+)
+
+const (
+	// lockoutThreshold is the number of failures that locks an address or
+	// device out. Each further failure doubles the lockout.
+	lockoutThreshold = 5
+	// lockoutBase is the first lockout, lockoutMax the longest.
+	lockoutBase = time.Minute
+	lockoutMax  = 24 * time.Hour
+	// lockoutForget is how long failures are remembered once any lockout
+	// has passed.
+	lockoutForget = 24 * time.Hour
+	// maxLockoutRecords bounds the file against made-up device IDs.
+	maxLockoutRecords = 1000
+	// lockoutFileName keeps failures next to the config file, so that they
+	// survive a restart and `linqora lockout` can see and clear them.
+	lockoutFileName = "linqora_lockouts.json"
+)
+
+// Kinds of Lockout.
+const (
+	LockoutIP     = "ip"
+	LockoutDevice = "device"
+)
+
+// Failure reasons recorded in Lockout.Reason.
+const (
+	FailureChallenge   = "challenge_invalid"
+	FailureVersion     = "unsupported_version"
+	FailureMalformed   = "malformed_request"
+	FailurePairing     = "pairing_invalid"
+	FailureRateLimited = "too_many_requests"
+)
+
+var (
+	// lockoutMu guards lockoutCache and the lockout file within the process.
+	lockoutMu sync.Mutex
+	// lockoutPath returns the location of the lockout file.
+	lockoutPath = func() string { return config.DataPath(lockoutFileName) }
+	// lockoutCache holds the records last read or written, so that checks
+	// do not read the file again.
+	lockoutCache lockoutFile
+)
+
+// lockoutFile is the content of the lockout file at path as of modTime and
+// size. The file is read again only when they change, which happens when
+// another process such as `linqora lockout clear` writes it.
+type lockoutFile struct {
+	path    string
+	exists  bool
+	modTime time.Time
+	size    int64
+	all     []Lockout
+}
+
+// current reports whether f still matches the file at path.
+func (f *lockoutFile) current(path string) bool {
+	if f.path != path {
+		return false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return !f.exists
+	}
+	return f.exists && info.ModTime().Equal(f.modTime) && info.Size() == f.size
+}
+
+// remember records all as the content of the file at path.
+func (f *lockoutFile) remember(path string, all []Lockout) {
+	*f = lockoutFile{path: path, all: append([]Lockout(nil), all...)}
+	if info, err := os.Stat(path); err == nil {
+		f.exists, f.modTime, f.size = true, info.ModTime(), info.Size()
+	}
+}
+
+// provesDevice reports whether a failure of kind reason was a wrong proof
+// on a connection already authenticated as its device. Only those count
+// against the device: the ID in an auth or pairing request is whatever the
+// caller claims, and the shared secret and pairing code are not tied to any
+// device, so counting them would let anyone lock a device out.
+func provesDevice(reason string) bool {
+	return reason == FailureStepUp
+}
+
+// Lockout is the failure record of one address or device.
+type Lockout struct {
+	Kind        string    `json:"kind"`
+	Key         string    `json:"key"`
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"lastFailure"`
+	// Reason is the kind of the last failure.
+	Reason string `json:"reason"`
+	// LockedUntil is set once Failures reaches the threshold.
+	LockedUntil time.Time `json:"lockedUntil,omitempty"`
+	// DeviceName is the name last claimed with the failure, if any.
+	DeviceName string `json:"deviceName,omitempty"`
+}
+
+// Locked reports whether l keeps its address or device out at now.
+func (l Lockout) Locked(now time.Time) bool {
+	return now.Before(l.LockedUntil)
+}
+
+// LockoutTopic carries each lockout as it starts.
+var LockoutTopic = events.NewTopic[Lockout]("auth_lockout")
+
+// lockoutDuration returns the lockout after failures failures: lockoutBase
+// at the threshold, doubling with each failure after it.
+func lockoutDuration(failures int) time.Duration {
+	if failures < lockoutThreshold {
+		return 0
+	}
+	d := lockoutBase
+	for i := lockoutThreshold; i < failures && d < lockoutMax; i++ {
+		d *= 2
+	}
+	if d > lockoutMax {
+		d = lockoutMax
+	}
+	return d
+}
+
+// stale reports whether l can be forgotten.
+func (l Lockout) stale(now time.Time) bool {
+	return !l.Locked(now) && now.Sub(l.LastFailure) > lockoutForget
+}
+
+// loadLockouts returns a copy of the recorded failures without stale
+// records, reading the lockout file only if it changed since it was last
+// read or written. Must be called with lockoutMu held.
+func loadLockouts(now time.Time) []Lockout {
+	path := lockoutPath()
+	if !lockoutCache.current(path) {
+		var all []Lockout
+		if data, err := os.ReadFile(path); err == nil {
+			if err := json.Unmarshal(data, &all); err != nil {
+				slog.Warn("Ignoring unreadable lockout file", "err", err)
+				all = nil
+			}
+		}
+		lockoutCache.remember(path, all)
+	}
+	kept := make([]Lockout, 0, len(lockoutCache.all))
+	for _, l := range lockoutCache.all {
+		if !l.stale(now) {
+			kept = append(kept, l)
+		}
+	}
+	return kept
+}
+
+// saveLockouts writes the lockout file, keeping the most recent records.
+// Must be called with lockoutMu held.
+func saveLockouts(all []Lockout) error {
+	if len(all) > maxLockoutRecords {
+		sort.Slice(all, func(i, j int) bool { return all[i].LastFailure.After(all[j].LastFailure) })
+		all = all[:maxLockoutRecords]
+	}
+	// The records are kept in memory even if the file cannot be written.
+	path := lockoutPath()
+	defer lockoutCache.remember(path, all)
+	if len(all) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// Lockouts returns the recorded failures, locked ones first.
+func Lockouts() []Lockout {
+	lockoutMu.Lock()
+	defer lockoutMu.Unlock()
+
+	now := time.Now()
+	all := loadLockouts(now)
+	sort.SliceStable(all, func(i, j int) bool {
+		if a, b := all[i].Locked(now), all[j].Locked(now); a != b {
+			return a
+		}
+		return all[i].LastFailure.After(all[j].LastFailure)
+	})
+	return all
+}
+
+// ClearLockouts forgets the failures of target, an address or device ID, or
+// of everyone when target is empty. It returns how many records it removed.
+func ClearLockouts(target string) (int, error) {
+	lockoutMu.Lock()
+	defer lockoutMu.Unlock()
+
+	all := loadLockouts(time.Now())
+	kept := all[:0]
+	for _, l := range all {
+		if target != "" && l.Key != target {
+			kept = append(kept, l)
+		}
+	}
+	removed := len(all) - len(kept)
+	if err := saveLockouts(kept); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// hostOf returns the address of a client without its port.
+func hostOf(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// lockedOut reports whether the address or the device is locked out, and
+// until when.
+func (am *AuthManager) lockedOut(ip, deviceID string) (time.Time, bool) {
+	lockoutMu.Lock()
+	defer lockoutMu.Unlock()
+
+	now := time.Now()
+	var until time.Time
+	for _, l := range loadLockouts(now) {
+		if l.Locked(now) && matchesLockout(l, hostOf(ip), deviceID) && l.LockedUntil.After(until) {
+			until = l.LockedUntil
+		}
+	}
+	return until, !until.IsZero()
+}
+
+func matchesLockout(l Lockout, ip, deviceID string) bool {
+	return (l.Kind == LockoutIP && l.Key == ip) || (l.Kind == LockoutDevice && deviceID != "" && l.Key == deviceID)
+}
+
+// recordFailure counts a failed attempt against the address and, for a
+// failed step-up, the device, and publishes any lockout it starts.
+func (am *AuthManager) recordFailure(ip, deviceID, deviceName, reason string) {
+	lockoutMu.Lock()
+	now := time.Now()
+	all := loadLockouts(now)
+	var started []Lockout
+
+	count := func(kind, key string) {
+		if key == "" {
+			return
+		}
+		i := -1
+		for j := range all {
+			if all[j].Kind == kind && all[j].Key == key {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			all = append(all, Lockout{Kind: kind, Key: key})
+			i = len(all) - 1
+		}
+		l := &all[i]
+		l.Failures++
+		l.LastFailure, l.Reason = now, reason
+		if deviceName != "" {
+			l.DeviceName = deviceName
+		}
+		if d := lockoutDuration(l.Failures); d > 0 {
+			l.LockedUntil = now.Add(d)
+			started = append(started, *l)
+		}
+	}
+	count(LockoutIP, hostOf(ip))
+	if provesDevice(reason) {
+		count(LockoutDevice, deviceID)
+	}
+
+	if err := saveLockouts(all); err != nil {
+		slog.Error("Failed to save lockouts", "err", err)
+	}
+	lockoutMu.Unlock()
+
+	slog.Warn("Authentication failure", "ip", ip, "device_id", deviceID, "reason", reason)
+	am.mu.Lock()
+	bus := am.bus
+	am.mu.Unlock()
+	for _, l := range started {
+		slog.Warn("Locked out after failed authentication",
+			"kind", l.Kind, "key", l.Key, "failures", l.Failures, "until", l.LockedUntil.Format(time.RFC3339))
+		events.Publish(bus, LockoutTopic, l)
+	}
+}
+
+// clearFailures forgets the failures of the address and device after a
+// successful authentication.
+func (am *AuthManager) clearFailures(ip, deviceID string) {
+	lockoutMu.Lock()
+	defer lockoutMu.Unlock()
+
+	now := time.Now()
+	all := loadLockouts(now)
+	kept := all[:0]
+	for _, l := range all {
+		if !matchesLockout(l, hostOf(ip), deviceID) {
+			kept = append(kept, l)
+		}
+	}
+	if len(kept) == len(all) {
+		return
+	}
+	if err := saveLockouts(kept); err != nil {
+		slog.Error("Failed to save lockouts", "err", err)
+	}
+}
+
+// refuseLockedOut answers a locked-out client and reports whether it did.
+func (am *AuthManager) refuseLockedOut(client interfaces.WSClient, deviceID, responseType string) bool {
+	until, locked := am.lockedOut(client.GetIP(), deviceID)
+	if !locked {
+		return false
+	}
+	slog.Warn("Refused locked-out client", "ip", client.GetIP(), "device_id", deviceID, "until", until.Format(time.RFC3339))
+	client.SendSuccess(responseType, AuthResponse{
+		Code:    AuthStatusLockedOut,
+		Message: fmt.Sprintf("%s, try again after %s", GetAuthMessage(AuthStatusLockedOut), until.Format(time.RFC3339)),
+	})
+	return true
+}
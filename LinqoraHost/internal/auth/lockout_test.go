@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"LinqoraHost/internal/config"
+	"LinqoraHost/internal/events"
+)
+
+func useLockoutDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	previous := lockoutPath
+	lockoutPath = func() string { return filepath.Join(dir, lockoutFileName) }
+	t.Cleanup(func() { lockoutPath = previous })
+}
+
+func TestLockoutDuration(t *testing.T) {
+	cases := map[int]time.Duration{
+		1:                       0,
+		lockoutThreshold - 1:    0,
+		lockoutThreshold:        lockoutBase,
+		lockoutThreshold + 1:    2 * lockoutBase,
+		lockoutThreshold + 3:    8 * lockoutBase,
+		lockoutThreshold + 1000: lockoutMax,
+	}
+	for failures, want := range cases {
+		if got := lockoutDuration(failures); got != want {
+			t.Errorf("lockoutDuration(%d) = %v, want %v", failures, got, want)
+		}
+	}
+}
+
+func TestChallengeFailuresLockOut(t *testing.T) {
+	useLockoutDir(t)
+	cfg := config.DefaultConfig()
+	cfg.SharedSecret = "secret"
+	am := NewAuthManager(cfg, nil)
+	bus := events.NewBus()
+	am.SetEventBus(bus)
+	started := make(chan Lockout, 4)
+	events.Subscribe(bus, LockoutTopic, func(l Lockout) { started <- l })
+
+	request, _ := json.Marshal(AuthRequestData{DeviceID: "phone-1", DeviceName: "Phone", VersionClient: MinVersionClient})
+	wrong, _ := json.Marshal(map[string]string{"token": "t", "hmac": "bad"})
+	client := &fakeClient{}
+	for i := 0; i < lockoutThreshold; i++ {
+		am.HandleAuthRequest(client, rawMessage(request))
+		am.HandleChallengeResponse(client, rawMessage(wrong))
+		if code := client.last().Code; code != AuthStatusChallengeInvalid {
+			t.Fatalf("Attempt %d: expected a challenge failure, got %d", i+1, code)
+		}
+	}
+
+	select {
+	case l := <-started:
+		if l.Failures != lockoutThreshold || l.LockedUntil.IsZero() {
+			t.Errorf("Unexpected lockout %+v", l)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the lockout to be published")
+	}
+
+	// The lockout survives a restart.
+	am = NewAuthManager(cfg, nil)
+	am.HandleAuthRequest(client, rawMessage(request))
+	if code := client.last().Code; code != AuthStatusLockedOut {
+		t.Fatalf("Expected a locked-out response, got %d", code)
+	}
+	locked := 0
+	for _, l := range Lockouts() {
+		if l.Locked(time.Now()) {
+			locked++
+		}
+	}
+	if locked != 1 {
+		t.Errorf("Expected only the address to be locked, got %+v", Lockouts())
+	}
+
+	if n, err := ClearLockouts("phone-1"); err != nil || n != 0 {
+		t.Fatalf("Expected no record for the device, got %d, %v", n, err)
+	}
+	if n, err := ClearLockouts("127.0.0.1"); err != nil || n != 1 {
+		t.Fatalf("Expected to clear the address, got %d, %v", n, err)
+	}
+	before := len(client.responses)
+	am.HandleAuthRequest(client, rawMessage(request))
+	if len(client.responses) != before {
+		t.Errorf("Expected a fresh challenge once the address is cleared, got %+v", client.last())
+	}
+}
+
+func TestClaimedDeviceIsNotLockedOut(t *testing.T) {
+	useLockoutDir(t)
+	usePairingDir(t)
+	cfg := config.DefaultConfig()
+	cfg.SharedSecret = "secret"
+	am := NewAuthManager(cfg, nil)
+	code, _, _ := StartPairing(config.AllScopes, "")
+	wrongCode := "000000"
+	if code == wrongCode {
+		wrongCode = "111111"
+	}
+
+	// An attacker sends wrong HMACs and pairing codes under phone-1's ID.
+	request, _ := json.Marshal(AuthRequestData{DeviceID: "phone-1", DeviceName: "Phone", VersionClient: MinVersionClient})
+	wrong, _ := json.Marshal(map[string]string{"token": "t", "hmac": "bad"})
+	attacker := &fakeClient{}
+	for i := 0; i < lockoutThreshold; i++ {
+		am.HandleAuthRequest(attacker, rawMessage(request))
+		am.HandleChallengeResponse(attacker, rawMessage(wrong))
+		am.HandlePairRequest(attacker, pairMessage(wrongCode))
+	}
+
+	if _, locked := am.lockedOut(attacker.GetIP(), ""); !locked {
+		t.Fatal("Expected the attacker's address to be locked out")
+	}
+	if _, locked := am.lockedOut("10.0.0.7:4000", "phone-1"); locked {
+		t.Error("Expected phone-1 to connect from its own address")
+	}
+	for _, l := range Lockouts() {
+		if l.Kind == LockoutDevice {
+			t.Errorf("Expected no device record, got %+v", l)
+		}
+	}
+}
+
+func TestUnprovenFailuresCountAgainstAddressOnly(t *testing.T) {
+	useLockoutDir(t)
+	am := NewAuthManager(config.DefaultConfig(), nil)
+
+	// Anyone can send a bad request under someone else's device ID.
+	for i := 0; i < lockoutThreshold; i++ {
+		am.recordFailure("10.0.0.9:4000", "phone-1", "Phone", FailureVersion)
+		am.recordFailure("10.0.0.9:4000", "phone-1", "Phone", FailureMalformed)
+	}
+	if _, locked := am.lockedOut("10.0.0.7:4000", "phone-1"); locked {
+		t.Error("Expected the device not to be locked out by unproven claims")
+	}
+	if _, locked := am.lockedOut("10.0.0.9:4000", ""); !locked {
+		t.Error("Expected the address to be locked out")
+	}
+	for _, l := range Lockouts() {
+		if l.Kind == LockoutDevice {
+			t.Errorf("Expected no device record, got %+v", l)
+		}
+	}
+}
+
+func TestLockoutsFollowTheFile(t *testing.T) {
+	useLockoutDir(t)
+	am := NewAuthManager(config.DefaultConfig(), nil)
+	for i := 0; i < lockoutThreshold; i++ {
+		am.recordFailure("10.0.0.9:4000", "phone-1", "Phone", FailureChallenge)
+	}
+	if _, locked := am.lockedOut("10.0.0.9:4000", "phone-1"); !locked {
+		t.Fatal("Expected a lockout")
+	}
+
+	// Another process, such as `linqora lockout clear`, removes the file.
+	if err := os.Remove(lockoutPath()); err != nil {
+		t.Fatal(err)
+	}
+	if _, locked := am.lockedOut("10.0.0.9:4000", "phone-1"); locked {
+		t.Error("Expected the lockout to be gone with the file")
+	}
+}
@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"LinqoraHost/internal/config"
+	"LinqoraHost/internal/interfaces"
+)
+
+const (
+	// PairingCodeTTL is how long a pairing code stays valid.
+	PairingCodeTTL = 5 * time.Minute
+	// maxPairingAttempts is the number of wrong codes after which the
+	// active code is discarded.
+	maxPairingAttempts = 5
+	// pairingFileName holds the active code next to the config file, so that
+	// `linqorahost auth pair` can start pairing for a host running in
+	// another process.
+	pairingFileName = "linqora_pairing.json"
+)
+
+var (
+	// ErrNoPairing means no pairing code is active or the code expired.
+	ErrNoPairing = errors.New("no active pairing code")
+	// ErrPairingCode means the code did not match.
+	ErrPairingCode = errors.New("incorrect pairing code")
+
+	// pairingMu serialises access to the pairing file within the process.
+	pairingMu sync.Mutex
+	// pairingPath returns the location of the pairing file.
+	pairingPath = func() string { return config.DataPath(pairingFileName) }
+)
+
+// pairingState is the persisted form of the active pairing code. Only a hash
+// of the code is stored.
+type pairingState struct {
+	CodeHash  string    `json:"code_hash"`
+	Scopes    []string  `json:"scopes"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Attempts  int       `json:"attempts"`
+	// DeviceExpires limits the authorization of the paired device, in a
+	// form config.ParseExpiry accepts. It is read when the code is redeemed,
+	// so that "1h" counts from pairing rather than from issuing the code.
+	DeviceExpires string `json:"device_expires,omitempty"`
+}
+
+// PairRequestData is the data of a "pair_request" message.
+type PairRequestData struct {
+	DeviceID      string `json:"deviceId"`
+	DeviceName    string `json:"deviceName"`
+	VersionClient string `json:"versionClient"`
+	Code          string `json:"code"`
+	CSR           string `json:"csr,omitempty"`
+}
+
+func hashPairingCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// StartPairing issues a new 6-digit pairing code, replacing any active one.
+// A device that presents the code before it expires is authorised with the
+// given scopes without further approval, for as long as deviceExpires allows
+// (see config.ParseExpiry; "" or "never" for no limit).
+func StartPairing(scopes []string, deviceExpires string) (code string, expires time.Time, err error) {
+	if _, err := config.ParseExpiry(deviceExpires, time.Now()); err != nil {
+		return "", time.Time{}, err
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	code = fmt.Sprintf("%06d", n.Int64())
+	expires = time.Now().Add(PairingCodeTTL)
+
+	pairingMu.Lock()
+	defer pairingMu.Unlock()
+
+	state := pairingState{
+		CodeHash:      hashPairingCode(code),
+		Scopes:        append([]string{}, scopes...),
+		ExpiresAt:     expires,
+		DeviceExpires: deviceExpires,
+	}
+	if err := savePairing(&state); err != nil {
+		return "", time.Time{}, err
+	}
+	return code, expires, nil
+}
+
+// CancelPairing discards the active pairing code, if any.
+func CancelPairing() error {
+	pairingMu.Lock()
+	defer pairingMu.Unlock()
+	return savePairing(nil)
+}
+
+// redeemPairing checks code against the active pairing code. A matching code
+// is consumed and its state returned; wrong codes count towards
+// maxPairingAttempts.
+func redeemPairing(code string) (*pairingState, error) {
+	pairingMu.Lock()
+	defer pairingMu.Unlock()
+
+	data, err := os.ReadFile(pairingPath())
+	if err != nil {
+		return nil, ErrNoPairing
+	}
+	var state pairingState
+	if err := json.Unmarshal(data, &state); err != nil || time.Now().After(state.ExpiresAt) {
+		savePairing(nil)
+		return nil, ErrNoPairing
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashPairingCode(code)), []byte(state.CodeHash)) != 1 {
+		state.Attempts++
+		if state.Attempts >= maxPairingAttempts {
+			slog.Warn("Pairing code discarded after too many attempts")
+			savePairing(nil)
+		} else {
+			savePairing(&state)
+		}
+		return nil, ErrPairingCode
+	}
+
+	savePairing(nil)
+	return &state, nil
+}
+
+// savePairing writes state to the pairing file, or removes the file when
+// state is nil. Must be called with pairingMu held.
+func savePairing(state *pairingState) error {
+	path := pairingPath()
+	if state == nil {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// HandlePairRequest authorises a device that presents the pairing code shown
+// on the host. It stands in for operator approval, so the device is trusted
+// without anyone at the console. A device ID that is already authorized is
+// refused rather than replaced, so that pairing cannot strip or take over
+// another device's admin flag, step-up key or certificate.
+func (am *AuthManager) HandlePairRequest(client interfaces.WSClient, msg interfaces.WSMessage) {
+	var data PairRequestData
+	if err := json.Unmarshal(msg.GetData(), &data); err != nil {
+		if !am.refuseLockedOut(client, "", MessageTypePairResponse) {
+			am.recordFailure(client.GetIP(), "", "", FailureMalformed)
+			sendResponse(client, AuthStatusInvalidFormat, false, MessageTypePairResponse)
+		}
+		return
+	}
+	if am.refuseLockedOut(client, data.DeviceID, MessageTypePairResponse) {
+		return
+	}
+	if data.DeviceID == "" {
+		am.recordFailure(client.GetIP(), "", data.DeviceName, FailureMalformed)
+		sendResponse(client, AuthStatusMissingDeviceID, false, MessageTypePairResponse)
+		return
+	}
+	if !am.IsVersionClientSupported(data.VersionClient) {
+		am.recordFailure(client.GetIP(), data.DeviceID, data.DeviceName, FailureVersion)
+		sendResponse(client, AuthStatusUnsupportedVersion, false, MessageTypePairResponse)
+		return
+	}
+
+	if am.IsAuthorized(data.DeviceID) {
+		slog.Warn("Pairing refused for an authorized device", "device_id", data.DeviceID, "ip", client.GetIP())
+		sendResponse(client, AuthStatusAlreadyPaired, false, MessageTypePairResponse)
+		return
+	}
+
+	state, err := redeemPairing(data.Code)
+	if err != nil {
+		slog.Warn("Pairing failed", "device", data.DeviceName, "ip", client.GetIP(), "err", err)
+		if errors.Is(err, ErrPairingCode) {
+			am.recordFailure(client.GetIP(), data.DeviceID, data.DeviceName, FailurePairing)
+			sendResponse(client, AuthStatusPairingInvalid, false, MessageTypePairResponse)
+		} else {
+			sendResponse(client, AuthStatusPairingInactive, false, MessageTypePairResponse)
+		}
+		return
+	}
+
+	// StartPairing checked the expiry, so it still parses.
+	expiresAt, _ := config.ParseExpiry(state.DeviceExpires, time.Now())
+
+	am.mu.Lock()
+	if _, exists := am.activeDevice(data.DeviceID); exists {
+		am.mu.Unlock()
+		sendResponse(client, AuthStatusAlreadyPaired, false, MessageTypePairResponse)
+		return
+	}
+	am.config.AuthorizedDevs[data.DeviceID] = config.DeviceAuth{
+		DeviceName: data.DeviceName,
+		DeviceID:   data.DeviceID,
+		LastAuth:   time.Now().Format("2006-01-02 15:04:05"),
+		Scopes:     append([]string{}, state.Scopes...),
+		ExpiresAt:  expiresAt,
+	}
+	if err := am.config.SaveConfig(); err != nil {
+		slog.Error("Error saving config", "err", err)
+	}
+	am.mu.Unlock()
+
+	client.SetDeviceID(data.DeviceID)
+	client.SetDeviceName(data.DeviceName)
+	am.clearFailures(client.GetIP(), data.DeviceID)
+
+	slog.Info("Device paired", "device", data.DeviceName, "device_id", data.DeviceID, "ip", client.GetIP())
+	am.publish(Decision{
+		DeviceID:   data.DeviceID,
+		DeviceName: data.DeviceName,
+		IP:         client.GetIP(),
+		Approved:   true,
+		Reason:     DecisionPaired,
+		Scopes:     state.Scopes,
+		ExpiresAt:  expiresAt,
+	})
+
+	sendResponse(client, AuthStatusPaired, true, MessageTypePairResponse)
+	client.MarkAuthorized()
+	am.issueCertificate(client, data.DeviceID, data.CSR)
+}
@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"LinqoraHost/internal/config"
+)
+
+// fakeClient records the responses sent by the auth layer.
+type fakeClient struct {
+	deviceID, deviceName string
+	claimedID            string
+	claimProven          bool
+	certDeviceID         string
+	responses            []AuthResponse
+	challenge            string
+	certificates         []ClientCertificate
+	authorized           bool
+}
+
+func (c *fakeClient) SendError(string, string, ...int) error { return nil }
+func (c *fakeClient) SendSuccess(_ string, data interface{}) error {
+	switch r := data.(type) {
+	case AuthResponse:
+		c.responses = append(c.responses, r)
+	case ClientCertificate:
+		c.certificates = append(c.certificates, r)
+	case map[string]interface{}:
+		c.challenge, _ = r["token"].(string)
+	}
+	return nil
+}
+func (c *fakeClient) GetIP() string             { return "127.0.0.1" }
+func (c *fakeClient) GetDeviceID() string       { return c.deviceID }
+func (c *fakeClient) GetDeviceName() string     { return c.deviceName }
+func (c *fakeClient) SetDeviceID(id string)     { c.deviceID = id }
+func (c *fakeClient) SetDeviceName(name string) { c.deviceName = name }
+func (c *fakeClient) ClaimDeviceID(id string, proven bool) {
+	c.claimedID, c.claimProven = id, proven
+}
+func (c *fakeClient) ClaimedDeviceID() (string, bool) { return c.claimedID, c.claimProven }
+func (c *fakeClient) IsClosed() bool                  { return false }
+func (c *fakeClient) MarkAuthorized()                 { c.authorized = true }
+func (c *fakeClient) CertDeviceID() string            { return c.certDeviceID }
+func (c *fakeClient) last() AuthResponse              { return c.responses[len(c.responses)-1] }
+
+type rawMessage []byte
+
+func (m rawMessage) GetType() string { return "pair_request" }
+func (m rawMessage) GetData() []byte { return m }
+
+func pairMessage(code string) rawMessage {
+	data, _ := json.Marshal(PairRequestData{DeviceID: "phone-1", DeviceName: "Phone", VersionClient: MinVersionClient, Code: code})
+	return data
+}
+
+func usePairingDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	previous := pairingPath
+	pairingPath = func() string { return filepath.Join(dir, pairingFileName) }
+	t.Cleanup(func() { pairingPath = previous })
+}
+
+func TestPairingCodeAuthorizesDevice(t *testing.T) {
+	usePairingDir(t)
+	cfg := config.DefaultConfig()
+	am := NewAuthManager(cfg, nil)
+
+	client := &fakeClient{}
+	am.HandlePairRequest(client, pairMessage("123456"))
+	if client.last().Code != AuthStatusPairingInactive {
+		t.Fatalf("Expected no active pairing, got %d", client.last().Code)
+	}
+
+	code, _, err := StartPairing([]string{config.ScopeMedia}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code) != 6 {
+		t.Fatalf("Expected a 6-digit code, got %q", code)
+	}
+
+	am.HandlePairRequest(client, pairMessage(code))
+	if r := client.last(); !r.Success || r.Code != AuthStatusPaired || !client.authorized {
+		t.Fatalf("Expected the device to be paired, got %+v", r)
+	}
+	if !am.HasScope("phone-1", config.ScopeMedia) || am.HasScope("phone-1", config.ScopeShell) {
+		t.Error("Expected the device to hold exactly the pairing scopes")
+	}
+
+	am.HandlePairRequest(&fakeClient{}, pairMessage(code))
+	if _, err := redeemPairing(code); err != ErrNoPairing {
+		t.Errorf("Expected the code to be single-use, got %v", err)
+	}
+}
+
+func TestPairingCodeLimitsDeviceAuthorization(t *testing.T) {
+	usePairingDir(t)
+	am := NewAuthManager(config.DefaultConfig(), nil)
+
+	if _, _, err := StartPairing(config.AllScopes, "soon"); err == nil {
+		t.Fatal("Expected an invalid expiry to be refused")
+	}
+	code, _, _ := StartPairing(config.AllScopes, "1h")
+	am.HandlePairRequest(&fakeClient{}, pairMessage(code))
+
+	expiresAt := am.config.AuthorizedDevs["phone-1"].ExpiresAt
+	if expiresAt == nil || time.Until(*expiresAt) > time.Hour || time.Until(*expiresAt) < 59*time.Minute {
+		t.Errorf("Expected the paired device to expire in an hour, got %v", expiresAt)
+	}
+}
+
+func TestPairingKeepsAuthorizedDevice(t *testing.T) {
+	usePairingDir(t)
+	cfg := config.DefaultConfig()
+	cfg.AuthorizedDevs["phone-1"] = config.DeviceAuth{DeviceID: "phone-1", DeviceName: "Owner", Admin: true, StepUpKey: "key", CertSerial: "7"}
+	am := NewAuthManager(cfg, nil)
+
+	code, _, _ := StartPairing([]string{config.ScopeMedia}, "")
+	client := &fakeClient{}
+	am.HandlePairRequest(client, pairMessage(code))
+	if r := client.last(); r.Success || r.Code != AuthStatusAlreadyPaired || client.authorized {
+		t.Fatalf("Expected pairing an authorized ID to be refused, got %+v", r)
+	}
+	if device := am.config.AuthorizedDevs["phone-1"]; !device.Admin || device.StepUpKey != "key" || device.CertSerial != "7" || device.DeviceName != "Owner" {
+		t.Errorf("Expected the existing entry to stay intact, got %+v", device)
+	}
+	if _, err := redeemPairing(code); err != nil {
+		t.Errorf("Expected the refused request to leave the code usable, got %v", err)
+	}
+}
+
+func TestPairingCodeDiscardedAfterWrongAttempts(t *testing.T) {
+	usePairingDir(t)
+	code, _, _ := StartPairing(config.AllScopes, "")
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	for i := 0; i < maxPairingAttempts; i++ {
+		if _, err := redeemPairing(wrong); err != ErrPairingCode {
+			t.Fatalf("Attempt %d: expected ErrPairingCode, got %v", i, err)
+		}
+	}
+	if _, err := redeemPairing(code); err != ErrNoPairing {
+		t.Errorf("Expected the code to be discarded, got %v", err)
+	}
+}
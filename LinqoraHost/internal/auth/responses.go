@@ -6,6 +6,7 @@ const (
 	MessageTypeAuthPending       = "auth_pending"
 	MessageTypeAuthChallenge     = "auth_challenge"
 	MessageTypeAuthChallengeResp = "auth_challenge_response"
+	MessageTypePairResponse      = "pair_response"
 )
 
 // Authorization status codes used in server responses.
@@ -18,6 +19,7 @@ const (
 	// Success codes (1xx)
 	AuthStatusAuthorized = 100 // Device is already recognized
 	AuthStatusApproved   = 101 // Manual authorization was granted
+	AuthStatusPaired     = 102 // Device paired with a host-issued code
 
 	// Informational codes (2xx)
 	AuthStatusPending = 200 // Waiting for user interaction on host
@@ -26,6 +28,10 @@ const (
 	AuthStatusRejected        = 400 // Manual authorization was denied
 	AuthStatusInvalidFormat   = 401 // Request data is malformed
 	AuthStatusMissingDeviceID = 402 // Device ID field is empty
+	AuthStatusPairingInvalid  = 403 // Pairing code is wrong
+	AuthStatusPairingInactive = 404 // No pairing code is active, or it expired
+	AuthStatusLockedOut       = 405 // Too many failed attempts from this address or device
+	AuthStatusAlreadyPaired   = 406 // Device ID is already authorized
 
 	// Server-side error codes (5xx)
 	AuthStatusTimeout            = 500 // Authorization expired before approval
@@ -39,6 +45,11 @@ var authMessages = map[int]string{
 	AuthStatusNotAuthorized:      "Device not authorized",
 	AuthStatusAuthorized:         "Device authorized",
 	AuthStatusApproved:           "Authorization approved",
+	AuthStatusPaired:             "Device paired",
+	AuthStatusPairingInvalid:     "Pairing code is incorrect",
+	AuthStatusPairingInactive:    "No active pairing code, start pairing on the host",
+	AuthStatusLockedOut:          "Too many failed attempts",
+	AuthStatusAlreadyPaired:      "Device is already authorized, revoke it on the host to pair again",
 	AuthStatusRejected:           "Authorization rejected",
 	AuthStatusPending:            "Waiting for authorization",
 	AuthStatusTimeout:            "Authorization timeout",
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"errors"
+	"log/slog"
+
+	"LinqoraHost/internal/config"
+	"LinqoraHost/internal/interfaces"
+)
+
+// Secrets that answer a step-up challenge.
+const (
+	StepUpPIN          = "pin"
+	StepUpSharedSecret = "shared_secret"
+)
+
+// StepUpChallengeTTL is how long a step-up challenge can be answered.
+const StepUpChallengeTTL = challengeTTL
+
+// FailureStepUp is recorded for a wrong answer to a step-up challenge.
+const FailureStepUp = "step_up_invalid"
+
+// ErrStepUpUnavailable means the device has neither a PIN key nor a shared
+// secret to answer a step-up challenge with.
+var ErrStepUpUnavailable = errors.New("no PIN or shared secret is set for step-up")
+
+// stepUpChallengeKey keeps step-up challenges apart from the challenge of a
+// login from the same device.
+func stepUpChallengeKey(deviceID string) string {
+	return "step_up:" + deviceID
+}
+
+// stepUpSecret returns the secret deviceID answers step-up challenges with:
+// its PIN key if it set one, otherwise the shared secret.
+// Must be called with am.mu held.
+func (am *AuthManager) stepUpSecret(deviceID string) (secret, method string) {
+	if device, ok := am.config.AuthorizedDevs[deviceID]; ok && device.StepUpKey != "" {
+		return device.StepUpKey, StepUpPIN
+	}
+	if am.config.SharedSecret != "" {
+		return am.config.SharedSecret, StepUpSharedSecret
+	}
+	return "", ""
+}
+
+// StepUpChallenge issues a fresh challenge for deviceID and reports which
+// secret must answer it. It fails with ErrStepUpUnavailable when there is
+// none.
+func (am *AuthManager) StepUpChallenge(deviceID string) (token, method string, err error) {
+	am.mu.Lock()
+	_, method = am.stepUpSecret(deviceID)
+	am.mu.Unlock()
+	if method == "" {
+		return "", "", ErrStepUpUnavailable
+	}
+	token, err = am.challenges.Generate(stepUpChallengeKey(deviceID))
+	if err != nil {
+		return "", "", err
+	}
+	return token, method, nil
+}
+
+// VerifyStepUp checks the answer of client to its step-up challenge. Wrong
+// answers count towards a lockout, and a locked-out device cannot step up.
+func (am *AuthManager) VerifyStepUp(client interfaces.WSClient, token, response string) bool {
+	deviceID := client.GetDeviceID()
+	if _, locked := am.lockedOut(client.GetIP(), deviceID); locked {
+		slog.Warn("Step-up refused, device is locked out", "device_id", deviceID, "ip", client.GetIP())
+		return false
+	}
+
+	am.mu.Lock()
+	secret, _ := am.stepUpSecret(deviceID)
+	am.mu.Unlock()
+
+	if secret == "" || !am.challenges.Verify(stepUpChallengeKey(deviceID), token, response, secret) {
+		slog.Warn("Step-up verification failed", "device", client.GetDeviceName(), "device_id", deviceID)
+		am.recordFailure(client.GetIP(), deviceID, client.GetDeviceName(), FailureStepUp)
+		return false
+	}
+	am.clearFailures(client.GetIP(), deviceID)
+	slog.Info("Step-up verified", "device", client.GetDeviceName(), "device_id", deviceID)
+	return true
+}
+
+// SetStepUpKey stores the PIN key deviceID answers step-up challenges with.
+func (am *AuthManager) SetStepUpKey(deviceID, key string) error {
+	if err := config.ValidateStepUpKey(key); err != nil {
+		return err
+	}
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	device, ok := am.config.AuthorizedDevs[deviceID]
+	if !ok {
+		return errors.New("device is not authorized")
+	}
+	device.StepUpKey = key
+	am.config.AuthorizedDevs[deviceID] = device
+	if err := am.config.SaveConfig(); err != nil {
+		return err
+	}
+	slog.Info("Step-up PIN set", "device_id", deviceID)
+	return nil
+}
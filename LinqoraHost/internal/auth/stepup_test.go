@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"LinqoraHost/internal/config"
+)
+
+func stepUpManager(t *testing.T) *AuthManager {
+	useLockoutDir(t)
+	cfg := config.DefaultConfig()
+	cfg.AuthorizedDevs["phone-1"] = config.DeviceAuth{DeviceID: "phone-1", DeviceName: "Phone"}
+	return NewAuthManager(cfg, nil)
+}
+
+func TestStepUpNeedsASecret(t *testing.T) {
+	am := stepUpManager(t)
+	if _, _, err := am.StepUpChallenge("phone-1"); !errors.Is(err, ErrStepUpUnavailable) {
+		t.Fatalf("Expected ErrStepUpUnavailable without secrets, got %v", err)
+	}
+}
+
+func TestStepUpWithSharedSecret(t *testing.T) {
+	am := stepUpManager(t)
+	am.config.SharedSecret = "secret"
+	client := &fakeClient{deviceID: "phone-1", deviceName: "Phone"}
+
+	token, method, err := am.StepUpChallenge("phone-1")
+	if err != nil || method != StepUpSharedSecret {
+		t.Fatalf("StepUpChallenge = %q, %v", method, err)
+	}
+	if !am.VerifyStepUp(client, token, computeHMAC(token, "secret")) {
+		t.Fatal("A correct answer was refused")
+	}
+	if am.VerifyStepUp(client, token, computeHMAC(token, "secret")) {
+		t.Error("A challenge was accepted twice")
+	}
+
+	// A step-up challenge does not replace a pending login challenge.
+	login, _ := am.challenges.Generate("phone-1")
+	token, _, _ = am.StepUpChallenge("phone-1")
+	if !am.challenges.Verify("phone-1", login, computeHMAC(login, "secret"), "secret") {
+		t.Error("The step-up challenge clobbered the login challenge")
+	}
+	if am.VerifyStepUp(client, token, "wrong") {
+		t.Error("A wrong answer was accepted")
+	}
+	if got := Lockouts(); len(got) == 0 || got[0].Reason != FailureStepUp {
+		t.Errorf("Expected the wrong answer to be recorded, got %+v", got)
+	}
+}
+
+func TestStepUpPrefersPIN(t *testing.T) {
+	am := stepUpManager(t)
+	am.config.SharedSecret = "secret"
+	client := &fakeClient{deviceID: "phone-1", deviceName: "Phone"}
+
+	if err := am.SetStepUpKey("phone-1", "short"); !errors.Is(err, config.ErrInvalidStepUpKey) {
+		t.Fatalf("Expected a malformed key to be refused, got %v", err)
+	}
+	key := strings.Repeat("ab", 32)
+	if err := am.SetStepUpKey("phone-1", key); err != nil {
+		t.Fatal(err)
+	}
+	if err := am.SetStepUpKey("stranger", key); err == nil {
+		t.Error("Expected a key for an unknown device to be refused")
+	}
+
+	token, method, _ := am.StepUpChallenge("phone-1")
+	if method != StepUpPIN {
+		t.Fatalf("Expected the PIN to be asked for, got %q", method)
+	}
+	if am.VerifyStepUp(client, token, computeHMAC(token, "secret")) {
+		t.Error("The shared secret answered for a device with a PIN")
+	}
+	token, _, _ = am.StepUpChallenge("phone-1")
+	if !am.VerifyStepUp(client, token, computeHMAC(token, key)) {
+		t.Error("The PIN key was refused")
+	}
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"time"
+
+	"LinqoraHost/internal/config"
+)
+
+// tokenUsageSaveInterval limits how often a token's last-used time is written
+// to disk, so that a busy integration does not rewrite the config on every call.
+const tokenUsageSaveInterval = time.Minute
+
+// AuthenticateToken checks a REST API token against the stored hashes and
+// returns the token's name and scopes. Hashes are compared in constant time
+// and every stored token is checked, so timing does not reveal which one
+// matched. Expired tokens are refused.
+func (am *AuthManager) AuthenticateToken(token string) (name string, scopes []string, ok bool) {
+	if token == "" {
+		return "", nil, false
+	}
+	hash := []byte(config.HashToken(token))
+
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	match := -1
+	for i, t := range am.config.APITokens {
+		if subtle.ConstantTimeCompare(hash, []byte(t.Hash)) == 1 {
+			match = i
+		}
+	}
+	if match < 0 {
+		return "", nil, false
+	}
+
+	t := &am.config.APITokens[match]
+	now := time.Now()
+	if t.Expired(now) {
+		slog.Warn("Expired API token used", "token", t.Name)
+		return "", nil, false
+	}
+
+	if t.LastUsed == nil || now.Sub(*t.LastUsed) >= tokenUsageSaveInterval {
+		t.LastUsed = &now
+		if err := am.config.SaveConfig(); err != nil {
+			slog.Error("Error saving config", "err", err)
+		}
+	}
+	return t.Name, append([]string(nil), t.Scopes...), true
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"LinqoraHost/internal/config"
+)
+
+func TestAuthenticateToken(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	token, hash, err := config.GenerateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+	expiredToken, expiredHash, _ := config.GenerateToken()
+
+	cfg := config.DefaultConfig()
+	cfg.APITokens = []config.APIToken{
+		{Name: "home-assistant", Hash: hash, Scopes: []string{config.ScopeMetrics}},
+		{Name: "old", Hash: expiredHash, Scopes: []string{config.ScopePower}, ExpiresAt: &past},
+	}
+	am := NewAuthManager(cfg, nil)
+
+	name, scopes, ok := am.AuthenticateToken(token)
+	if !ok || name != "home-assistant" || len(scopes) != 1 || scopes[0] != config.ScopeMetrics {
+		t.Fatalf("Expected home-assistant with metrics, got %q %v %v", name, scopes, ok)
+	}
+	if cfg.APITokens[0].LastUsed == nil {
+		t.Error("Expected last-used time to be recorded")
+	}
+
+	if _, _, ok := am.AuthenticateToken(expiredToken); ok {
+		t.Error("Expired token should be refused")
+	}
+	if _, _, ok := am.AuthenticateToken(hash); ok {
+		t.Error("The stored hash must not work as a token")
+	}
+	if _, _, ok := am.AuthenticateToken(""); ok {
+		t.Error("Empty token should be refused")
+	}
+}
@@ -0,0 +1,193 @@
+// Package ca is the host's own small certificate authority. It signs a
+// client certificate for each approved device that asks for one, so that the
+// device can authenticate by mutual TLS instead of the HMAC challenge.
+//
+// The authority's key never leaves the host. Devices send a certificate
+// signing request and keep their own private key.
+package ca
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// ClientCertValidity is how long a device certificate is valid. Devices
+	// renew it by sending a new request when they authenticate.
+	ClientCertValidity = 365 * 24 * time.Hour
+	// caValidity is how long the authority itself is valid.
+	caValidity = 10 * 365 * 24 * time.Hour
+
+	certFileName = "ca_cert.pem"
+	keyFileName  = "ca_key.pem"
+)
+
+// ErrInvalidRequest means a certificate signing request could not be used.
+var ErrInvalidRequest = errors.New("invalid certificate signing request")
+
+// Authority signs device certificates.
+type Authority struct {
+	cert    *x509.Certificate
+	certPEM []byte
+	key     *ecdsa.PrivateKey
+}
+
+// Issued is a certificate signed for a device.
+type Issued struct {
+	CertPEM  string
+	Serial   string
+	NotAfter time.Time
+}
+
+// Open loads the authority kept in dir, creating it on first use.
+func Open(dir string) (*Authority, error) {
+	certPath, keyPath := filepath.Join(dir, certFileName), filepath.Join(dir, keyFileName)
+	certPEM, certErr := os.ReadFile(certPath)
+	keyPEM, keyErr := os.ReadFile(keyPath)
+	if os.IsNotExist(certErr) && os.IsNotExist(keyErr) {
+		return create(dir, certPath, keyPath)
+	}
+	if certErr != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %w", certErr)
+	}
+	if keyErr != nil {
+		return nil, fmt.Errorf("failed to read CA key: %w", keyErr)
+	}
+	return parse(certPEM, keyPEM)
+}
+
+func create(dir, certPath, keyPath string) (*Authority, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "Linqora Host CA " + host},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(certPath, certPEM, 0644); err != nil {
+		return nil, err
+	}
+	return parse(certPEM, keyPEM)
+}
+
+func parse(certPEM, keyPEM []byte) (*Authority, error) {
+	certBlock, _ := pem.Decode(certPEM)
+	keyBlock, _ := pem.Decode(keyPEM)
+	if certBlock == nil || keyBlock == nil {
+		return nil, errors.New("CA certificate or key is not PEM")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+	key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA key: %w", err)
+	}
+	if !key.PublicKey.Equal(cert.PublicKey) {
+		return nil, errors.New("CA key does not match its certificate")
+	}
+	return &Authority{cert: cert, certPEM: certPEM, key: key}, nil
+}
+
+// CertPEM returns the authority's certificate, for devices to trust.
+func (a *Authority) CertPEM() string {
+	return string(a.certPEM)
+}
+
+// Pool returns a pool holding only the authority, for verifying clients.
+func (a *Authority) Pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(a.cert)
+	return pool
+}
+
+// Sign issues a client certificate for deviceID from csrPEM. The subject of
+// the request is ignored: the certificate always names deviceID.
+func (a *Authority) Sign(csrPEM, deviceID string) (Issued, error) {
+	block, _ := pem.Decode([]byte(csrPEM))
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return Issued{}, ErrInvalidRequest
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return Issued{}, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return Issued{}, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+
+	serial, err := newSerial()
+	if err != nil {
+		return Issued{}, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: deviceID},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(ClientCertValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if template.NotAfter.After(a.cert.NotAfter) {
+		template.NotAfter = a.cert.NotAfter
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, csr.PublicKey, a.key)
+	if err != nil {
+		return Issued{}, err
+	}
+	return Issued{
+		CertPEM:  string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		Serial:   SerialString(serial),
+		NotAfter: template.NotAfter,
+	}, nil
+}
+
+// SerialString formats a certificate serial as the config stores it.
+func SerialString(serial *big.Int) string {
+	return fmt.Sprintf("%x", serial)
+}
+
+func newSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
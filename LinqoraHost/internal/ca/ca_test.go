@@ -0,0 +1,64 @@
+package ca
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"testing"
+)
+
+// newTestCSR returns a PEM certificate signing request and its key.
+func newTestCSR(t *testing.T) (string, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "someone-else"},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})), key
+}
+
+func TestSignIssuesClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	authority, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := Open(dir)
+	if err != nil || reopened.CertPEM() != authority.CertPEM() {
+		t.Fatalf("Expected the CA to be loaded again, got %v", err)
+	}
+
+	csr, _ := newTestCSR(t)
+	issued, err := authority.Sign(csr, "phone-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode([]byte(issued.CertPEM))
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Subject.CommonName != "phone-1" || SerialString(cert.SerialNumber) != issued.Serial {
+		t.Errorf("Unexpected certificate %v, serial %s", cert.Subject, issued.Serial)
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:     reopened.Pool(),
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		t.Errorf("Expected the certificate to verify as a client certificate: %v", err)
+	}
+
+	if _, err := authority.Sign("not a request", "phone-1"); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("Expected ErrInvalidRequest, got %v", err)
+	}
+}
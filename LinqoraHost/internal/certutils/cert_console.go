@@ -22,6 +22,19 @@ func IsValidCertificate(certFile string) bool {
 		return false
 	}
 
+	if cert, err := LoadCertificate(certFile); err == nil && IsHostCert(cert) {
+		fp, _ := Fingerprint(certFile)
+		fmt.Println("┌──────────────────────────────────────────────────────┐")
+		fmt.Println("│                   SECURITY ENABLED                   │")
+		fmt.Println("├──────────────────────────────────────────────────────┤")
+		fmt.Println("│ TLS enabled with this host's own certificate         │")
+		fmt.Println("│ The app pins the SHA-256 fingerprint below           │")
+		fmt.Printf("│ Expires: %-43s │\n", certInfo.ExpirationDate.Format("2006-01-02"))
+		fmt.Println("└──────────────────────────────────────────────────────┘")
+		fmt.Printf("Fingerprint: %s\n", FormatFingerprint(fp))
+		return true
+	}
+
 	if isDev {
 		fmt.Println("┌──────────────────────────────────────────────────────┐")
 		fmt.Println("│                   SECURITY WARNING                   │")
@@ -0,0 +1,176 @@
+package certutils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"LinqoraHost/internal/config"
+)
+
+const (
+	// hostCertValidity is how long a generated certificate is valid.
+	hostCertValidity = 825 * 24 * time.Hour
+	// hostCertOrganization marks certificates this host generated.
+	hostCertOrganization = "Linqora Host"
+)
+
+// HostCertPaths returns where this host's generated certificate and key are
+// kept, next to the config file.
+func HostCertPaths() (certFile, keyFile string) {
+	return config.DataPath("host_cert.pem"), config.DataPath("host_key.pem")
+}
+
+// EnsureHostCert generates a certificate and key at certFile and keyFile
+// unless both exist. It reports whether it generated them.
+func EnsureHostCert(certFile, keyFile string) (bool, error) {
+	if UserCertExists(certFile, keyFile) {
+		return false, nil
+	}
+	if err := GenerateHostCert(certFile, keyFile); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GenerateHostCert writes a new ECDSA key and a self-signed certificate for
+// this host, replacing any at certFile and keyFile. The certificate names
+// the host's LAN addresses, its hostname and its mDNS name, so that it
+// matches however the app reaches the host; the app pins its fingerprint.
+func GenerateHostCert(certFile, keyFile string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	hostname, _ := os.Hostname()
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   hostname,
+			Organization: []string{hostCertOrganization},
+		},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(hostCertValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              hostNames(hostname),
+		IPAddresses:           hostIPs(),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(certFile), 0755); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(keyFile), 0700); err != nil {
+		return err
+	}
+	// The key is written first, so that a certificate never sits next to a
+	// key it does not match.
+	if err := writeFileAtomic(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return err
+	}
+	return writeFileAtomic(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+}
+
+// hostNames returns the DNS names of this host: its hostname, its mDNS name
+// and localhost.
+func hostNames(hostname string) []string {
+	names := []string{"localhost"}
+	if hostname == "" {
+		return names
+	}
+	short := strings.ToLower(strings.TrimSuffix(hostname, ".local"))
+	return append(names, short, short+".local")
+}
+
+// hostIPs returns the addresses of this host's interfaces, loopback included.
+func hostIPs() []net.IP {
+	ips := []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return ips
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		ips = append(ips, ipNet.IP)
+	}
+	return ips
+}
+
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the certificate in
+// certFile, as lower-case hex. Apps pin this value.
+func Fingerprint(certFile string) (string, error) {
+	cert, err := LoadCertificate(certFile)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// FormatFingerprint groups a hex fingerprint in colon-separated pairs, as
+// browsers and openssl show it.
+func FormatFingerprint(fp string) string {
+	pairs := make([]string, 0, len(fp)/2)
+	for i := 0; i+1 < len(fp); i += 2 {
+		pairs = append(pairs, strings.ToUpper(fp[i:i+2]))
+	}
+	return strings.Join(pairs, ":")
+}
+
+// LoadCertificate parses the first certificate in certFile.
+func LoadCertificate(certFile string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate file: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("failed to decode PEM block containing certificate")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// IsHostCert reports whether cert was generated by GenerateHostCert.
+func IsHostCert(cert *x509.Certificate) bool {
+	return len(cert.Subject.Organization) == 1 && cert.Subject.Organization[0] == hostCertOrganization &&
+		cert.Issuer.String() == cert.Subject.String()
+}
@@ -0,0 +1,96 @@
+package certutils
+
+import (
+	"crypto/tls"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateHostCertIsUniqueAndLoadable(t *testing.T) {
+	dir := t.TempDir()
+	certA, keyA := filepath.Join(dir, "a_cert.pem"), filepath.Join(dir, "a_key.pem")
+	certB, keyB := filepath.Join(dir, "b_cert.pem"), filepath.Join(dir, "b_key.pem")
+	if err := GenerateHostCert(certA, keyA); err != nil {
+		t.Fatal(err)
+	}
+	if err := GenerateHostCert(certB, keyB); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tls.LoadX509KeyPair(certA, keyA); err != nil {
+		t.Fatalf("generated pair does not load: %v", err)
+	}
+	info, err := os.Stat(keyA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 && os.PathSeparator == '/' {
+		t.Errorf("key mode = %v, want no group or other access", perm)
+	}
+
+	fpA, err := Fingerprint(certA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fpB, _ := Fingerprint(certB)
+	if len(fpA) != 64 || fpA == fpB {
+		t.Fatalf("fingerprints %q and %q should be distinct SHA-256 hex", fpA, fpB)
+	}
+
+	cert, err := LoadCertificate(certA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsHostCert(cert) {
+		t.Error("IsHostCert = false for a generated certificate")
+	}
+	if err := cert.VerifyHostname("localhost"); err != nil {
+		t.Errorf("localhost not covered: %v", err)
+	}
+	if !containsIP(cert.IPAddresses, net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("IP SANs %v lack 127.0.0.1", cert.IPAddresses)
+	}
+	if hostname, _ := os.Hostname(); hostname != "" {
+		mdns := strings.ToLower(strings.TrimSuffix(hostname, ".local")) + ".local"
+		if err := cert.VerifyHostname(mdns); err != nil {
+			t.Errorf("%s not covered: %v", mdns, err)
+		}
+	}
+}
+
+func TestEnsureHostCertKeepsExisting(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	generated, err := EnsureHostCert(certFile, keyFile)
+	if err != nil || !generated {
+		t.Fatalf("first EnsureHostCert = %v, %v; want generated", generated, err)
+	}
+	before, _ := Fingerprint(certFile)
+
+	generated, err = EnsureHostCert(certFile, keyFile)
+	if err != nil || generated {
+		t.Fatalf("second EnsureHostCert = %v, %v; want kept", generated, err)
+	}
+	if after, _ := Fingerprint(certFile); after != before {
+		t.Error("EnsureHostCert replaced an existing certificate")
+	}
+}
+
+func TestFormatFingerprint(t *testing.T) {
+	if got := FormatFingerprint("0a1bff"); got != "0A:1B:FF" {
+		t.Errorf("FormatFingerprint = %q", got)
+	}
+}
+
+func containsIP(ips []net.IP, want net.IP) bool {
+	for _, ip := range ips {
+		if ip.Equal(want) {
+			return true
+		}
+	}
+	return false
+}
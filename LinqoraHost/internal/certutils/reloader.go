@@ -0,0 +1,189 @@
+package certutils
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// ReloadPollInterval is how often the watcher looks at the files.
+	ReloadPollInterval = 5 * time.Second
+	// ExpiryWarningPeriod is how long before NotAfter the reloader starts
+	// warning that the certificate is about to expire.
+	ExpiryWarningPeriod = 14 * 24 * time.Hour
+	// expiryWarningRepeat spaces out repeated expiry warnings.
+	expiryWarningRepeat = 24 * time.Hour
+)
+
+// CertStatus describes the certificate being served.
+type CertStatus struct {
+	CertFile    string    `json:"certFile"`
+	Fingerprint string    `json:"fingerprint"`
+	NotAfter    time.Time `json:"notAfter"`
+}
+
+// CertReloader serves a certificate and key pair to the TLS stack through
+// GetCertificate and swaps in a new pair when the files change, so that
+// replacing the certificate does not drop connected clients. A pair that
+// fails validation is refused and the previous one stays in service.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	current  atomic.Pointer[loadedCert]
+
+	mu         sync.Mutex // serialises reloads
+	certStamp  fileStamp
+	keyStamp   fileStamp
+	lastWarned time.Time
+}
+
+type loadedCert struct {
+	cert   *tls.Certificate
+	status CertStatus
+}
+
+// fileStamp identifies a version of a file well enough to notice it was
+// replaced.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func stampOf(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
+
+// NewCertReloader loads the pair at certFile and keyFile.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.current.Load().cert, nil
+}
+
+// Status describes the certificate currently served.
+func (r *CertReloader) Status() CertStatus {
+	return r.current.Load().status
+}
+
+// Reload reads the files again and serves them from the next handshake on,
+// provided ValidateCertKeyPair accepts them and the certificate has not
+// expired. Connections already established keep their session.
+func (r *CertReloader) Reload() (CertStatus, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reloadLocked()
+}
+
+func (r *CertReloader) reloadLocked() (CertStatus, error) {
+	// Stamps are taken first, so that a write racing with the load is
+	// picked up by the next poll.
+	r.certStamp, r.keyStamp = stampOf(r.certFile), stampOf(r.keyFile)
+
+	if err := ValidateCertKeyPair(r.certFile, r.keyFile); err != nil {
+		return CertStatus{}, err
+	}
+	pair, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return CertStatus{}, fmt.Errorf("cert/key pair validation failed: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return CertStatus{}, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	if time.Now().After(leaf.NotAfter) {
+		return CertStatus{}, fmt.Errorf("certificate expired on %s", leaf.NotAfter.Format(time.DateOnly))
+	}
+	pair.Leaf = leaf
+
+	sum := sha256.Sum256(leaf.Raw)
+	loaded := &loadedCert{
+		cert: &pair,
+		status: CertStatus{
+			CertFile:    r.certFile,
+			Fingerprint: hex.EncodeToString(sum[:]),
+			NotAfter:    leaf.NotAfter,
+		},
+	}
+	r.current.Store(loaded)
+	r.lastWarned = time.Time{}
+	r.warnExpiryLocked(time.Now())
+	return loaded.status, nil
+}
+
+// Watch polls the files every interval until ctx is done and reloads them
+// when either changes. onReload, if not nil, is called after each reload
+// that was accepted.
+func (r *CertReloader) Watch(ctx context.Context, interval time.Duration, onReload func(CertStatus)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		status, changed, err := r.reloadIfChanged()
+		if err != nil {
+			slog.Error("TLS certificate not reloaded, still serving the previous one", "cert", r.certFile, "err", err)
+			continue
+		}
+		if changed {
+			slog.Info("TLS certificate reloaded", "cert", r.certFile, "fingerprint", status.Fingerprint, "not_after", status.NotAfter)
+			if onReload != nil {
+				onReload(status)
+			}
+		}
+	}
+}
+
+// reloadIfChanged reloads the pair if either file changed since the last
+// attempt, and otherwise repeats the expiry warning when it is due.
+func (r *CertReloader) reloadIfChanged() (CertStatus, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if stampOf(r.certFile) == r.certStamp && stampOf(r.keyFile) == r.keyStamp {
+		r.warnExpiryLocked(time.Now())
+		return CertStatus{}, false, nil
+	}
+	status, err := r.reloadLocked()
+	if err != nil {
+		return CertStatus{}, false, err
+	}
+	return status, true, nil
+}
+
+// warnExpiryLocked logs a warning once a day while the certificate is within
+// ExpiryWarningPeriod of expiring.
+func (r *CertReloader) warnExpiryLocked(now time.Time) {
+	notAfter := r.current.Load().status.NotAfter
+	if notAfter.Sub(now) > ExpiryWarningPeriod || now.Sub(r.lastWarned) < expiryWarningRepeat {
+		return
+	}
+	r.lastWarned = now
+	slog.Warn("TLS certificate expires soon; replace it or run `cert regenerate`",
+		"cert", r.certFile, "not_after", notAfter, "days_left", int(notAfter.Sub(now).Hours()/24))
+}
+
+// ErrNoCertificate is returned when TLS is off and there is nothing to reload.
+var ErrNoCertificate = errors.New("TLS is disabled; no certificate to reload")
@@ -0,0 +1,91 @@
+package certutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func generatePair(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+	certFile, keyFile := filepath.Join(dir, name+"_cert.pem"), filepath.Join(dir, name+"_key.pem")
+	if err := GenerateHostCert(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func copyFile(t *testing.T, from, to string) {
+	t.Helper()
+	data, err := os.ReadFile(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(to, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCertReloaderSwapsValidPairs(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := generatePair(t, dir, "served")
+	nextCert, nextKey := generatePair(t, dir, "next")
+
+	r, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := r.GetCertificate(nil)
+	before := r.Status().Fingerprint
+
+	copyFile(t, nextCert, certFile)
+	copyFile(t, nextKey, keyFile)
+	status, err := r.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := Fingerprint(nextCert)
+	if status.Fingerprint != want || status.Fingerprint == before {
+		t.Fatalf("fingerprint after reload = %q, want %q", status.Fingerprint, want)
+	}
+	if second, _ := r.GetCertificate(nil); second == first {
+		t.Error("GetCertificate still returns the old pair")
+	}
+}
+
+func TestCertReloaderKeepsPairOnMismatch(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := generatePair(t, dir, "served")
+	otherCert, _ := generatePair(t, dir, "other")
+
+	r, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := r.Status().Fingerprint
+
+	// Only the certificate is replaced: it no longer matches the key.
+	copyFile(t, otherCert, certFile)
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(certFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, changed, err := r.reloadIfChanged(); err == nil || changed {
+		t.Fatalf("reloadIfChanged = %v, %v; want the mismatched pair refused", changed, err)
+	}
+	if got := r.Status().Fingerprint; got != before {
+		t.Errorf("serving %q after a refused reload, want %q", got, before)
+	}
+	// The refused version is not retried until a file changes again.
+	if _, changed, err := r.reloadIfChanged(); err != nil || changed {
+		t.Errorf("second poll = %v, %v; want nothing to do", changed, err)
+	}
+}
+
+func TestNewCertReloaderRejectsMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewCertReloader(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")); err == nil {
+		t.Fatal("NewCertReloader accepted missing files")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"LinqoraHost/internal/events"
 	"LinqoraHost/internal/metrics"
 )
 
@@ -16,11 +17,19 @@ const (
 	defaultBatteryThreshold = 20
 )
 
-// BatteryAlertCollector monitors battery level and broadcasts an alert when
+// BatteryAlert reports that the battery dropped to or below the threshold.
+type BatteryAlert struct {
+	Percent   int `json:"percent"`
+	Threshold int `json:"threshold"`
+}
+
+// BatteryAlertTopic carries a BatteryAlert once per drain cycle.
+var BatteryAlertTopic = events.NewTopic[BatteryAlert]("battery_alert")
+
+// BatteryAlertCollector monitors battery level and publishes an alert when
 // the battery falls below the configured threshold while discharging.
 type BatteryAlertCollector struct {
-	// broadcaster is called with (msgType, data) to reach all connected clients.
-	broadcaster func(string, interface{})
+	bus         *events.Bus
 	threshold   int
 	lastPercent int
 	// alerted tracks whether we have already sent an alert in the current
@@ -32,12 +41,12 @@ type BatteryAlertCollector struct {
 	mu        sync.Mutex
 }
 
-// NewBatteryAlertCollector creates a new collector that will call broadcaster
-// whenever it needs to push a battery_alert message to all clients.
-func NewBatteryAlertCollector(broadcaster func(string, interface{})) *BatteryAlertCollector {
+// NewBatteryAlertCollector creates a new collector that publishes to bus
+// whenever the battery runs low.
+func NewBatteryAlertCollector(bus *events.Bus) *BatteryAlertCollector {
 	return &BatteryAlertCollector{
-		broadcaster: broadcaster,
-		threshold:   defaultBatteryThreshold,
+		bus:       bus,
+		threshold: defaultBatteryThreshold,
 	}
 }
 
@@ -135,9 +144,9 @@ func (c *BatteryAlertCollector) checkBattery() {
 		c.mu.Unlock()
 
 		slog.Info("Battery low alert", "percent", info.Level, "threshold", threshold)
-		c.broadcaster("battery_alert", map[string]interface{}{
-			"percent":   info.Level,
-			"threshold": threshold,
+		events.Publish(c.bus, BatteryAlertTopic, BatteryAlert{
+			Percent:   info.Level,
+			Threshold: threshold,
 		})
 	}
 }
@@ -2,6 +2,7 @@ package collectors
 
 import (
 	"LinqoraHost/internal/clipboard"
+	"LinqoraHost/internal/events"
 	"context"
 	"log/slog"
 	"sync"
@@ -10,18 +11,22 @@ import (
 
 const clipboardPollInterval = 500 * time.Millisecond
 
-// ClipboardCollector watches the host clipboard and broadcasts changes.
+// ClipboardTopic carries the host clipboard text each time it changes while
+// the clipboard collector runs.
+var ClipboardTopic = events.NewTopic[string]("clipboard")
+
+// ClipboardCollector watches the host clipboard and publishes changes.
 type ClipboardCollector struct {
-	broadcaster func([]byte)
-	ctx         context.Context
-	cancel      context.CancelFunc
-	isRunning   bool
-	mu          sync.Mutex
+	bus       *events.Bus
+	ctx       context.Context
+	cancel    context.CancelFunc
+	isRunning bool
+	mu        sync.Mutex
 }
 
-// NewClipboardCollector creates a collector that invokes broadcaster on each clipboard change.
-func NewClipboardCollector(broadcaster func([]byte)) *ClipboardCollector {
-	return &ClipboardCollector{broadcaster: broadcaster}
+// NewClipboardCollector creates a collector that publishes each clipboard change to bus.
+func NewClipboardCollector(bus *events.Bus) *ClipboardCollector {
+	return &ClipboardCollector{bus: bus}
 }
 
 // Start begins clipboard watching in a background goroutine.
@@ -66,7 +71,7 @@ func (cc *ClipboardCollector) watchLoop(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case text := <-ch:
-			cc.broadcaster([]byte(text))
+			events.Publish(cc.bus, ClipboardTopic, text)
 		}
 	}
 }
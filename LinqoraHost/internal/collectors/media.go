@@ -1,9 +1,9 @@
 package collectors
 
 import (
+	"LinqoraHost/internal/events"
 	"LinqoraHost/internal/media"
 	"context"
-	"encoding/json"
 	"fmt"
 	"log/slog"
 	"sync"
@@ -16,9 +16,12 @@ type MediaResponse struct {
 	MediaCapabilities *media.MediaCapabilities `json:"mediaCapabilities"`
 }
 
-// MediaCollector monitors and broadcasts changes in system media playback and audio state.
+// MediaTopic carries playback and audio state while the media collector runs.
+var MediaTopic = events.NewTopic[MediaResponse]("media")
+
+// MediaCollector monitors and publishes changes in system media playback and audio state.
 type MediaCollector struct {
-	broadcaster   func([]byte)
+	bus           *events.Bus
 	ctx           context.Context
 	cancel        context.CancelFunc
 	lastMediaInfo media.NowPlaying
@@ -27,10 +30,10 @@ type MediaCollector struct {
 }
 
 // NewMediaCollector creates a new collector instance for media information.
-func NewMediaCollector(broadcaster func([]byte)) *MediaCollector {
+func NewMediaCollector(bus *events.Bus) *MediaCollector {
 	return &MediaCollector{
-		broadcaster: broadcaster,
-		isRunning:   false,
+		bus:       bus,
+		isRunning: false,
 	}
 }
 
@@ -92,7 +95,7 @@ func (mc *MediaCollector) collectLoop(ctx context.Context) {
 	}
 }
 
-// collectAndSend gathers current media info and audio capabilities, then broadcasts if changed.
+// collectAndSend gathers current media info and audio capabilities, then publishes if changed.
 func (mc *MediaCollector) collectAndSend() {
 	nowPlaying, err := mc.collectMediaInfo()
 	if err != nil {
@@ -108,11 +111,6 @@ func (mc *MediaCollector) collectAndSend() {
 		return
 	}
 
-	if mc.broadcaster == nil {
-		slog.Warn("Broadcaster not initialized")
-		return
-	}
-
 	response := MediaResponse{}
 
 	if nowPlaying != nil {
@@ -123,13 +121,7 @@ func (mc *MediaCollector) collectAndSend() {
 		response.MediaCapabilities = mediaCapabilities
 	}
 
-	metricsJSON, err := json.Marshal(response)
-	if err != nil {
-		slog.Error("Error marshaling media info", "err", err)
-		return
-	}
-
-	mc.broadcaster(metricsJSON)
+	events.Publish(mc.bus, MediaTopic, response)
 }
 
 // collectMediaInfo retrieves currently playing track information.
@@ -1,9 +1,9 @@
 package collectors
 
 import (
+	"LinqoraHost/internal/events"
 	"LinqoraHost/internal/metrics"
 	"context"
-	"encoding/json"
 	"log/slog"
 	"sync"
 	"time"
@@ -25,13 +25,17 @@ type SystemMetrics struct {
 	Timestamp      int64              `json:"timestamp"`
 }
 
-// MetricsCollector periodically gathers and broadcasts system performance data.
+// MetricsTopic carries a SystemMetrics sample every CollectorInterval while
+// the metrics collector runs.
+var MetricsTopic = events.NewTopic[SystemMetrics]("metrics")
+
+// MetricsCollector periodically gathers and publishes system performance data.
 type MetricsCollector struct {
-	broadcaster  func([]byte)
-	ctx          context.Context
-	cancel       context.CancelFunc
-	isRunning    bool
-	mu           sync.Mutex
+	bus           *events.Bus
+	ctx           context.Context
+	cancel        context.CancelFunc
+	isRunning     bool
+	mu            sync.Mutex
 	prevDiskRead  uint64
 	prevDiskWrite uint64
 	prevNetSent   uint64
@@ -39,11 +43,11 @@ type MetricsCollector struct {
 	prevTime      time.Time
 }
 
-// NewMetricsCollector creates a new collector instance with the specified broadcast function.
-func NewMetricsCollector(broadcaster func([]byte)) *MetricsCollector {
+// NewMetricsCollector creates a new collector instance that publishes to bus.
+func NewMetricsCollector(bus *events.Bus) *MetricsCollector {
 	return &MetricsCollector{
-		broadcaster: broadcaster,
-		isRunning:   false,
+		bus:       bus,
+		isRunning: false,
 	}
 }
 
@@ -110,7 +114,7 @@ func (mc *MetricsCollector) collectLoop(ctx context.Context) {
 	}
 }
 
-// collectAndSend gathers current metrics and broadcasts them to subscribers.
+// collectAndSend gathers current metrics and publishes them to subscribers.
 func (mc *MetricsCollector) collectAndSend() {
 	metrics, err := mc.collectMetrics()
 	if err != nil {
@@ -118,13 +122,7 @@ func (mc *MetricsCollector) collectAndSend() {
 		return
 	}
 
-	metricsJSON, err := json.Marshal(metrics)
-	if err != nil {
-		slog.Error("Error marshaling metrics", "err", err)
-		return
-	}
-
-	mc.broadcaster(metricsJSON)
+	events.Publish(mc.bus, MetricsTopic, *metrics)
 }
 
 // collectMetrics retrieves CPU, RAM, GPU, disk I/O, and network performance data.
@@ -0,0 +1,31 @@
+package config
+
+// RevokeDevice removes a device and puts its client certificate, if any, on
+// the deny list. It returns the removed device and whether it existed.
+func (c *ServerConfig) RevokeDevice(deviceID string) (DeviceAuth, bool) {
+	device, ok := c.AuthorizedDevs[deviceID]
+	if !ok {
+		return DeviceAuth{}, false
+	}
+	delete(c.AuthorizedDevs, deviceID)
+	c.DenyCert(device.CertSerial)
+	return device, true
+}
+
+// DenyCert puts serial on the deny list.
+func (c *ServerConfig) DenyCert(serial string) {
+	if serial == "" || c.CertRevoked(serial) {
+		return
+	}
+	c.RevokedCertSerials = append(c.RevokedCertSerials, serial)
+}
+
+// CertRevoked reports whether serial is on the deny list.
+func (c *ServerConfig) CertRevoked(serial string) bool {
+	for _, s := range c.RevokedCertSerials {
+		if s == serial {
+			return true
+		}
+	}
+	return false
+}
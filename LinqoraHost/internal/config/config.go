@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 const (
@@ -26,6 +27,42 @@ type ServerConfig struct {
 	SharedSecret string `json:"shared_secret,omitempty"`
 	// EnableE2EE enables application-layer encryption for WebSocket payloads.
 	EnableE2EE bool `json:"enable_e2ee"`
+	// RequireKeyExchange refuses authorised traffic from clients that still
+	// encrypt with the key derived from SharedSecret instead of a per-connection
+	// key from key_exchange. Off while older clients are being updated.
+	RequireKeyExchange bool `json:"require_key_exchange"`
+	// APITokens are named REST credentials with their own scopes, so that
+	// integrations do not need SharedSecret.
+	APITokens []APIToken `json:"api_tokens,omitempty"`
+	// ConfirmActions lists the action classes (see ConfirmActions) that
+	// must be allowed by someone at the host before they run.
+	ConfirmActions []string `json:"confirm_actions,omitempty"`
+	// ConfirmTimeout is how many seconds a confirmation prompt waits before
+	// the action is refused. Zero means DefaultConfirmTimeout.
+	ConfirmTimeout int `json:"confirm_timeout,omitempty"`
+	// ExecPolicy limits what shell_exec and scripts may run.
+	ExecPolicy ExecPolicy `json:"exec_policy"`
+	// AuditMaxSizeMB and AuditKeep control rotation of the audit log: the
+	// size at which it is rotated and how many old files are kept. Zero
+	// means the audit package defaults.
+	AuditMaxSizeMB int `json:"audit_max_size_mb,omitempty"`
+	AuditKeep      int `json:"audit_keep,omitempty"`
+	// AllowCIDRs, when set, are the only networks that may connect, over
+	// WebSocket and REST. DenyCIDRs are refused even if allowed.
+	AllowCIDRs []string `json:"allow_cidrs,omitempty"`
+	DenyCIDRs  []string `json:"deny_cidrs,omitempty"`
+	// MaxConnsPerIP and MaxConns cap concurrent WebSocket connections and
+	// event streams from one address and in total. Zero means the defaults.
+	MaxConnsPerIP int `json:"max_conns_per_ip,omitempty"`
+	MaxConns      int `json:"max_conns,omitempty"`
+	// RevokedCertSerials are client certificates refused even though the
+	// host's CA signed them, added when their device is revoked.
+	RevokedCertSerials []string `json:"revoked_cert_serials,omitempty"`
+	// StepUpTypes lists the message types (see StepUpTypes) that need a
+	// fresh step-up before they run. StepUpGrace is how many seconds a
+	// step-up lasts; zero means DefaultStepUpGrace.
+	StepUpTypes []string `json:"step_up_types,omitempty"`
+	StepUpGrace int      `json:"step_up_grace,omitempty"`
 }
 
 // DeviceAuth stores information about an authorised device.
@@ -33,6 +70,21 @@ type DeviceAuth struct {
 	DeviceName string `json:"device_name"`
 	DeviceID   string `json:"device_id"`
 	LastAuth   string `json:"last_auth"`
+	// Scopes lists what the device may do (see AllScopes). Devices saved
+	// before scopes existed have none recorded and are given all of them on
+	// load, which keeps their previous full access.
+	Scopes []string `json:"scopes"`
+	// Admin devices may approve or reject other devices' requests.
+	Admin bool `json:"admin,omitempty"`
+	// CertSerial is the device's current client certificate, if the host's
+	// CA issued one. Only that certificate authenticates the device.
+	CertSerial string `json:"cert_serial,omitempty"`
+	// StepUpKey is a key the device derives from a PIN its user enters. It
+	// answers step-up challenges in place of the shared secret.
+	StepUpKey string `json:"step_up_key,omitempty"`
+	// ExpiresAt, when set, ends a guest's authorization: the device is
+	// revoked and disconnected once it passes.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
 }
 
 // DefaultConfig returns default configuration for the server.
@@ -46,6 +98,13 @@ func DefaultConfig() *ServerConfig {
 // getConfigPath returns the full path to the config file and ensures the
 // parent directory exists.
 func getConfigPath() string {
+	return DataPath("linqora_config.json")
+}
+
+// DataPath returns the path of a file kept next to the config file, such as
+// state shared between the running host and CLI commands. It ensures the
+// directory exists.
+func DataPath(name string) string {
 	configDir, err := os.UserConfigDir()
 	if err != nil {
 		configDir = "."
@@ -54,10 +113,10 @@ func getConfigPath() string {
 	linqoraDir := filepath.Join(configDir, "linqora")
 	if err := os.MkdirAll(linqoraDir, 0755); err != nil {
 		slog.Error("Failed to create config dir", "err", err)
-		return filepath.Join(".", "linqora_config.json")
+		return filepath.Join(".", name)
 	}
 
-	return filepath.Join(linqoraDir, "linqora_config.json")
+	return filepath.Join(linqoraDir, name)
 }
 
 // SaveConfig saves the current configuration to a file.
@@ -89,6 +148,7 @@ func LoadConfig() (*ServerConfig, error) {
 		if err := json.Unmarshal(data, config); err != nil {
 			return config, fmt.Errorf("failed to parse config file: %w", err)
 		}
+		config.migrateScopes()
 	} else {
 		if err := config.SaveConfig(); err != nil {
 			slog.Error("Failed to create initial config", "err", err)
@@ -97,3 +157,27 @@ func LoadConfig() (*ServerConfig, error) {
 
 	return config, nil
 }
+
+// RestartRequired returns the keys of settings that differ between c and
+// fresh and only take effect when the server starts again.
+func (c *ServerConfig) RestartRequired(fresh *ServerConfig) []string {
+	var keys []string
+	if c.Port != fresh.Port {
+		keys = append(keys, "port")
+	}
+	if c.EnableTLS != fresh.EnableTLS || c.CertFile != fresh.CertFile || c.KeyFile != fresh.KeyFile {
+		keys = append(keys, "tls")
+	}
+	return keys
+}
+
+// migrateScopes grants every scope to devices authorised before scopes were
+// introduced. An explicitly empty list is kept as is.
+func (c *ServerConfig) migrateScopes() {
+	for id, dev := range c.AuthorizedDevs {
+		if dev.Scopes == nil {
+			dev.Scopes = append([]string(nil), AllScopes...)
+			c.AuthorizedDevs[id] = dev
+		}
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"encoding/json"
 	"testing"
+	"time"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -44,3 +45,130 @@ func TestConfigJSON(t *testing.T) {
 		t.Errorf("Expected shared secret 'test-secret', got %s", loadedCfg.SharedSecret)
 	}
 }
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := ParseScopes(" media, FILES:READ ,media")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(scopes) != 2 || scopes[0] != ScopeMedia || scopes[1] != ScopeFilesRead {
+		t.Errorf("Expected [media files:read], got %v", scopes)
+	}
+
+	if all, _ := ParseScopes("all"); len(all) != len(AllScopes) {
+		t.Errorf("Expected all scopes, got %v", all)
+	}
+	if none, _ := ParseScopes(""); none == nil || len(none) != 0 {
+		t.Errorf("Expected an empty non-nil list, got %#v", none)
+	}
+	if _, err := ParseScopes("media,root"); err == nil {
+		t.Error("Expected an error for an unknown scope")
+	}
+}
+
+func TestMigrateScopesGrantsAllToLegacyDevices(t *testing.T) {
+	var cfg ServerConfig
+	data := `{"authorized_devs":{"old":{"device_id":"old"},"limited":{"device_id":"limited","scopes":[]}}}`
+	if err := json.Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	cfg.migrateScopes()
+
+	if !cfg.AuthorizedDevs["old"].HasScope(ScopeShell) {
+		t.Error("Expected a legacy device to keep full access")
+	}
+	if s := cfg.AuthorizedDevs["limited"].ScopesString(); s != "none" {
+		t.Errorf("Expected an explicit empty list to stay empty, got %s", s)
+	}
+}
+
+func TestScopesStringComparesSets(t *testing.T) {
+	if s := (DeviceAuth{Scopes: AllScopes}).ScopesString(); s != "all" {
+		t.Errorf("Expected every scope to show as all, got %s", s)
+	}
+	padded := append(append([]string{}, AllScopes[1:]...), ScopeInput)
+	if s := (DeviceAuth{Scopes: padded}).ScopesString(); s == "all" {
+		t.Error("Expected a list with a duplicate to be listed, not shown as all")
+	}
+	unknown := append(append([]string{}, TokenScopes[1:]...), "root")
+	if s := (APIToken{Scopes: unknown}).ScopesString(); s == "all" {
+		t.Error("Expected a list with an unknown name to be listed, not shown as all")
+	}
+	if s := (APIToken{Scopes: TokenScopes}).ScopesString(); s != "all" {
+		t.Errorf("Expected every token scope to show as all, got %s", s)
+	}
+}
+
+func TestExecPolicyValidate(t *testing.T) {
+	good := ExecPolicy{Rules: []ExecRule{{Action: ExecDeny, Exec: "rm", Args: `-r`}}}
+	if err := good.Validate(); err != nil {
+		t.Fatalf("Expected a valid policy, got %v", err)
+	}
+	for _, bad := range []ExecRule{
+		{Action: "block", Exec: "rm"},
+		{Action: ExecDeny},
+		{Action: ExecDeny, Exec: "[rm"},
+		{Action: ExecDeny, Exec: "rm", Args: "("},
+	} {
+		p := ExecPolicy{Devices: map[string]ExecOverride{"phone": {Rules: []ExecRule{bad}}}}
+		if err := p.Validate(); err == nil {
+			t.Errorf("Expected %+v to be refused", bad)
+		}
+	}
+}
+
+func TestParseExpiry(t *testing.T) {
+	now := time.Date(2026, 3, 10, 15, 30, 0, 0, time.Local)
+	tests := []struct {
+		raw  string
+		want time.Time
+	}{
+		{"1h", now.Add(time.Hour)},
+		{"90m", now.Add(90 * time.Minute)},
+		{"midnight", time.Date(2026, 3, 11, 0, 0, 0, 0, time.Local)},
+		{"3d", time.Date(2026, 3, 13, 15, 30, 0, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		got, err := ParseExpiry(tt.raw, now)
+		if err != nil || got == nil || !got.Equal(tt.want) {
+			t.Errorf("ParseExpiry(%q) = %v, %v; want %v", tt.raw, got, err, tt.want)
+		}
+	}
+	for _, raw := range []string{"", "never"} {
+		if got, err := ParseExpiry(raw, now); got != nil || err != nil {
+			t.Errorf("ParseExpiry(%q) = %v, %v; want no expiry", raw, got, err)
+		}
+	}
+	for _, raw := range []string{"0d", "-1h", "soon", "d"} {
+		if _, err := ParseExpiry(raw, now); err == nil {
+			t.Errorf("ParseExpiry(%q) should fail", raw)
+		}
+	}
+}
+
+func TestDeviceExpiry(t *testing.T) {
+	now := time.Now()
+	until := now.Add(26*time.Hour + 5*time.Minute)
+	guest := DeviceAuth{DeviceID: "guest", ExpiresAt: &until}
+	owner := DeviceAuth{DeviceID: "owner"}
+
+	if guest.Expired(now) || owner.Expired(now) {
+		t.Error("Expected neither device to have expired yet")
+	}
+	if !guest.Expired(until) {
+		t.Error("Expected the guest to expire at ExpiresAt")
+	}
+	if got := guest.RemainingString(now); got != "1d 2h left" {
+		t.Errorf("RemainingString = %q", got)
+	}
+	if got := owner.RemainingString(now); got != "" {
+		t.Errorf("Expected no remaining time for a permanent device, got %q", got)
+	}
+
+	cfg := DefaultConfig()
+	cfg.AuthorizedDevs["guest"] = guest
+	cfg.AuthorizedDevs["owner"] = owner
+	if got := cfg.ExpiredDevices(until.Add(time.Second)); len(got) != 1 || got[0] != "guest" {
+		t.Errorf("ExpiredDevices = %v", got)
+	}
+}
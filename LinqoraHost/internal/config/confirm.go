@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Remote actions that can be made to wait for approval at the host.
+const (
+	ConfirmPower       = "power"        // shutdown, restart, sleep, lock
+	ConfirmProcessKill = "process_kill" // ending a process
+	ConfirmShell       = "shell"        // shell commands
+	ConfirmFileWrite   = "file_write"   // uploading files
+)
+
+// ConfirmStepUpPIN is asked of the host when a device with no PIN and no
+// shared secret sets its first step-up PIN. It cannot be switched off, as
+// nothing else vouches for the device at that point.
+const ConfirmStepUpPIN = "step_up_pin"
+
+// ConfirmActions lists every action class that can require confirmation.
+var ConfirmActions = []string{
+	ConfirmPower,
+	ConfirmProcessKill,
+	ConfirmShell,
+	ConfirmFileWrite,
+}
+
+// DefaultConfirmTimeout is how long the host is given to answer when
+// ConfirmTimeout is not set.
+const DefaultConfirmTimeout = 30 * time.Second
+
+// ParseConfirmActions parses a comma-separated list of action classes.
+// "all" selects every class and "none" or an empty string none of them.
+func ParseConfirmActions(raw string) ([]string, error) {
+	return parseChoices(raw, ConfirmActions, "action")
+}
+
+// parseChoices parses a comma-separated selection from valid. "all" selects
+// every entry and "none" or an empty string none of them.
+func parseChoices(raw string, valid []string, what string) ([]string, error) {
+	raw = strings.TrimSpace(raw)
+	switch raw {
+	case "", "none":
+		return []string{}, nil
+	case "all":
+		return append([]string(nil), valid...), nil
+	}
+
+	chosen := make([]string, 0)
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || containsScope(chosen, name) {
+			continue
+		}
+		if !containsScope(valid, name) {
+			return nil, fmt.Errorf("unknown %s %q (valid: %s)", what, name, strings.Join(valid, ", "))
+		}
+		chosen = append(chosen, name)
+	}
+	return chosen, nil
+}
+
+// RequiresConfirmation reports whether action has to be allowed at the host
+// before it runs.
+func (c *ServerConfig) RequiresConfirmation(action string) bool {
+	return containsScope(c.ConfirmActions, action)
+}
+
+// ConfirmWait returns how long a confirmation prompt waits for an answer.
+func (c *ServerConfig) ConfirmWait() time.Duration {
+	if c.ConfirmTimeout <= 0 {
+		return DefaultConfirmTimeout
+	}
+	return time.Duration(c.ConfirmTimeout) * time.Second
+}
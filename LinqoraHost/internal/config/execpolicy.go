@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+)
+
+// Rule actions.
+const (
+	ExecAllow = "allow"
+	ExecDeny  = "deny"
+)
+
+// ExecPolicy limits the commands remote devices can run through shell_exec
+// and scripts. The zero value allows everything, as before policies existed.
+type ExecPolicy struct {
+	// DisableShell turns shell_exec off entirely. Scripts still run.
+	DisableShell bool `json:"disable_shell,omitempty"`
+	// DefaultDeny refuses commands no rule allows, which makes Rules an
+	// allowlist. Otherwise Rules act as a denylist.
+	DefaultDeny bool `json:"default_deny,omitempty"`
+	// Rules are tried in order; the first match decides.
+	Rules []ExecRule `json:"rules,omitempty"`
+	// WorkDirs, when set, are the directories commands may run in. Commands
+	// that do not ask for one run in the first.
+	WorkDirs []string `json:"work_dirs,omitempty"`
+	// ScrubEnv starts commands with only a few safe environment variables
+	// and those named in KeepEnv, instead of the host's whole environment.
+	ScrubEnv bool     `json:"scrub_env,omitempty"`
+	KeepEnv  []string `json:"keep_env,omitempty"`
+	// Devices overrides the policy for single devices, keyed by device ID.
+	Devices map[string]ExecOverride `json:"devices,omitempty"`
+}
+
+// ExecRule matches a command by its executable and, optionally, its
+// arguments.
+type ExecRule struct {
+	// Action is ExecAllow or ExecDeny.
+	Action string `json:"action"`
+	// Exec is a glob matched against the executable's name, such as "rm" or
+	// "git*". A pattern containing a slash is matched against the full path.
+	Exec string `json:"exec"`
+	// Args is a regular expression matched against the arguments joined by
+	// spaces. Empty matches any arguments.
+	Args string `json:"args,omitempty"`
+}
+
+// ExecOverride changes the policy for one device. Its rules are tried
+// before the global ones; unset fields keep the global setting.
+type ExecOverride struct {
+	DisableShell *bool      `json:"disable_shell,omitempty"`
+	DefaultDeny  *bool      `json:"default_deny,omitempty"`
+	Rules        []ExecRule `json:"rules,omitempty"`
+	WorkDirs     []string   `json:"work_dirs,omitempty"`
+}
+
+// Validate reports the first malformed rule, so that a typo is found when
+// the config is edited rather than when a command is refused.
+func (p *ExecPolicy) Validate() error {
+	if err := validateRules("rules", p.Rules); err != nil {
+		return err
+	}
+	for id, o := range p.Devices {
+		if err := validateRules("devices."+id+".rules", o.Rules); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateRules(where string, rules []ExecRule) error {
+	for i, r := range rules {
+		if r.Action != ExecAllow && r.Action != ExecDeny {
+			return fmt.Errorf("%s[%d]: action must be %q or %q", where, i, ExecAllow, ExecDeny)
+		}
+		if r.Exec == "" {
+			return fmt.Errorf("%s[%d]: exec is required", where, i)
+		}
+		if _, err := path.Match(r.Exec, ""); err != nil {
+			return fmt.Errorf("%s[%d]: bad exec pattern %q", where, i, r.Exec)
+		}
+		if _, err := regexp.Compile(r.Args); err != nil {
+			return fmt.Errorf("%s[%d]: bad args pattern: %v", where, i, err)
+		}
+	}
+	return nil
+}
+
+// ForDevice returns the policy that applies to deviceID, with its override
+// merged in. Devices without an override, and callers that are not devices,
+// get the global policy.
+func (p *ExecPolicy) ForDevice(deviceID string) ExecPolicy {
+	eff := *p
+	eff.Devices = nil
+	o, ok := p.Devices[deviceID]
+	if !ok || deviceID == "" {
+		return eff
+	}
+	if o.DisableShell != nil {
+		eff.DisableShell = *o.DisableShell
+	}
+	if o.DefaultDeny != nil {
+		eff.DefaultDeny = *o.DefaultDeny
+	}
+	if len(o.Rules) > 0 {
+		eff.Rules = append(append([]ExecRule(nil), o.Rules...), p.Rules...)
+	}
+	if len(o.WorkDirs) > 0 {
+		eff.WorkDirs = o.WorkDirs
+	}
+	return eff
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExpiryChoices describes the forms ParseExpiry accepts, for help texts.
+const ExpiryChoices = "never, midnight, a number of days such as 3d, or a duration such as 1h"
+
+// ParseExpiry reads when an authorization given at now should end: "never"
+// or "" for no expiry, "midnight" for the coming local midnight, "<n>d" for n
+// days, or a Go duration such as "1h" or "90m".
+func ParseExpiry(raw string, now time.Time) (*time.Time, error) {
+	raw = strings.ToLower(strings.TrimSpace(raw))
+	var until time.Time
+	switch {
+	case raw == "" || raw == "never":
+		return nil, nil
+	case raw == "midnight":
+		y, m, d := now.In(time.Local).Date()
+		until = time.Date(y, m, d+1, 0, 0, 0, 0, time.Local)
+	case strings.HasSuffix(raw, "d"):
+		days, err := strconv.Atoi(strings.TrimSuffix(raw, "d"))
+		if err != nil || days < 1 {
+			return nil, fmt.Errorf("invalid expiry %q: use %s", raw, ExpiryChoices)
+		}
+		until = now.AddDate(0, 0, days)
+	default:
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid expiry %q: use %s", raw, ExpiryChoices)
+		}
+		until = now.Add(d)
+	}
+	return &until, nil
+}
+
+// Expired reports whether the device was authorized until a time that has
+// passed.
+func (d DeviceAuth) Expired(now time.Time) bool {
+	return d.ExpiresAt != nil && !now.Before(*d.ExpiresAt)
+}
+
+// RemainingString describes how long a time-limited authorization has left,
+// such as "2d 3h left", or "" for a permanent one.
+func (d DeviceAuth) RemainingString(now time.Time) string {
+	if d.ExpiresAt == nil {
+		return ""
+	}
+	left := d.ExpiresAt.Sub(now)
+	switch {
+	case left <= 0:
+		return "expired"
+	case left < time.Minute:
+		return "<1m left"
+	case left < time.Hour:
+		return fmt.Sprintf("%dm left", int(left.Minutes()))
+	case left < 24*time.Hour:
+		return fmt.Sprintf("%dh %dm left", int(left.Hours()), int(left.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dd %dh left", int(left.Hours())/24, int(left.Hours())%24)
+	}
+}
+
+// ExpiredDevices returns the IDs of devices whose authorization has ended.
+func (c *ServerConfig) ExpiredDevices(now time.Time) []string {
+	var ids []string
+	for id, device := range c.AuthorizedDevs {
+		if device.Expired(now) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
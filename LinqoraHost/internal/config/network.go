@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Connection limits used when MaxConnsPerIP or MaxConns is not set.
+const (
+	DefaultMaxConnsPerIP = 10
+	DefaultMaxConns      = 100
+)
+
+// ParseCIDRList parses a comma-separated list of networks such as
+// "192.168.1.0/24,100.64.0.0/10". A bare address stands for itself. "none"
+// or an empty string is the empty list.
+func ParseCIDRList(raw string) ([]string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "none" {
+		return []string{}, nil
+	}
+	list := make([]string, 0)
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if _, err := parseNetwork(item); err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+// parseNetwork parses a CIDR or a bare address.
+func parseNetwork(item string) (*net.IPNet, error) {
+	if _, network, err := net.ParseCIDR(item); err == nil {
+		return network, nil
+	}
+	ip := net.ParseIP(item)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid network %q (expected a CIDR such as 192.168.1.0/24, or an address)", item)
+	}
+	bits := 128
+	if ip.To4() != nil {
+		ip, bits = ip.To4(), 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// ValidateNetwork reports the first malformed entry of AllowCIDRs or
+// DenyCIDRs.
+func (c *ServerConfig) ValidateNetwork() error {
+	for _, item := range append(append([]string(nil), c.AllowCIDRs...), c.DenyCIDRs...) {
+		if _, err := parseNetwork(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckIP reports why ip may not connect, or nil if it may. DenyCIDRs win
+// over AllowCIDRs; an empty AllowCIDRs allows every address not denied.
+// Malformed entries never match, so a broken allowlist admits nobody.
+func (c *ServerConfig) CheckIP(ip net.IP) error {
+	if ip == nil {
+		return fmt.Errorf("unknown address")
+	}
+	for _, item := range c.DenyCIDRs {
+		if network, err := parseNetwork(item); err == nil && network.Contains(ip) {
+			return fmt.Errorf("%s is in the denied network %s", ip, item)
+		}
+	}
+	if len(c.AllowCIDRs) == 0 {
+		return nil
+	}
+	for _, item := range c.AllowCIDRs {
+		if network, err := parseNetwork(item); err == nil && network.Contains(ip) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not in an allowed network", ip)
+}
+
+// ConnLimits returns the maximum number of concurrent connections from one
+// address and in total.
+func (c *ServerConfig) ConnLimits() (perIP, total int) {
+	perIP, total = c.MaxConnsPerIP, c.MaxConns
+	if perIP <= 0 {
+		perIP = DefaultMaxConnsPerIP
+	}
+	if total <= 0 {
+		total = DefaultMaxConns
+	}
+	return perIP, total
+}
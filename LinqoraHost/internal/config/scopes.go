@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Permission scopes that can be granted to an authorised device.
+const (
+	ScopeMedia      = "media"       // playback and volume
+	ScopeInput      = "input"       // mouse and keyboard
+	ScopeClipboard  = "clipboard"   // reading and setting the clipboard
+	ScopeDisplay    = "display"     // monitors and display power
+	ScopeFilesRead  = "files:read"  // listing and downloading files
+	ScopeFilesWrite = "files:write" // uploading files
+	ScopePower      = "power"       // shutdown, restart, lock
+	ScopeScripts    = "scripts"     // managing and running scripts
+	ScopeShell      = "shell"       // arbitrary shell commands
+	ScopeProcesses  = "processes"   // process and startup entry management
+)
+
+// AllScopes lists every scope in display order.
+var AllScopes = []string{
+	ScopeMedia,
+	ScopeInput,
+	ScopeClipboard,
+	ScopeDisplay,
+	ScopeFilesRead,
+	ScopeFilesWrite,
+	ScopePower,
+	ScopeScripts,
+	ScopeShell,
+	ScopeProcesses,
+}
+
+// IsValidScope reports whether scope is a known scope name.
+func IsValidScope(scope string) bool {
+	return containsScope(AllScopes, scope)
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseScopes parses a comma-separated scope list such as "media,input".
+// "all" selects every scope. Unknown names are an error.
+func ParseScopes(raw string) ([]string, error) {
+	return parseScopeList(raw, AllScopes, AllScopes)
+}
+
+// parseScopeList parses raw against the scope names in valid. "all" expands
+// to all, which may leave out scopes that have to be named explicitly.
+func parseScopeList(raw string, all, valid []string) ([]string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return []string{}, nil
+	}
+	if raw == "all" || raw == "*" {
+		return append([]string(nil), all...), nil
+	}
+
+	seen := make(map[string]bool)
+	scopes := make([]string, 0)
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		if !containsScope(valid, name) {
+			return nil, fmt.Errorf("unknown scope %q (valid: %s)", name, strings.Join(valid, ", "))
+		}
+		seen[name] = true
+		scopes = append(scopes, name)
+	}
+	return scopes, nil
+}
+
+// HasScope reports whether the device has been granted scope.
+func (d DeviceAuth) HasScope(scope string) bool {
+	return containsScope(d.Scopes, scope)
+}
+
+// ScopesString formats the device's scopes for display.
+func (d DeviceAuth) ScopesString() string {
+	return formatScopes(d.Scopes, AllScopes)
+}
+
+// formatScopes joins scopes for display, or returns "all" when they name
+// exactly the scopes in all. Comparing sets rather than lengths keeps a list
+// with duplicates or unknown names from passing for "all".
+func formatScopes(scopes, all []string) string {
+	if len(scopes) == 0 {
+		return "none"
+	}
+	for _, scope := range scopes {
+		if !containsScope(all, scope) {
+			return strings.Join(scopes, ",")
+		}
+	}
+	for _, scope := range all {
+		if !containsScope(scopes, scope) {
+			return strings.Join(scopes, ",")
+		}
+	}
+	return "all"
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// StepUpTypes lists the message types that can be made to require step-up
+// authentication: a fresh challenge answered with the shared secret or the
+// device's PIN key before the message runs.
+var StepUpTypes = []string{
+	"power",
+	"shell_exec",
+	"process_kill",
+	"file_write",
+	"startup_set",
+	"script_execute",
+}
+
+// DefaultStepUpGrace is how long a step-up lasts when StepUpGrace is not set.
+const DefaultStepUpGrace = 5 * time.Minute
+
+// stepUpKeyLen is the length of a PIN key: 32 bytes, hex-encoded.
+const stepUpKeyLen = 64
+
+// ErrInvalidStepUpKey is returned for a PIN key that is not 32 bytes of hex.
+var ErrInvalidStepUpKey = errors.New("step-up key must be 32 bytes, hex-encoded")
+
+// ParseStepUpTypes parses a comma-separated list of message types from
+// StepUpTypes. "all" selects every type and "none" or an empty string none.
+func ParseStepUpTypes(raw string) ([]string, error) {
+	return parseChoices(raw, StepUpTypes, "message type")
+}
+
+// RequiresStepUp reports whether msgType needs a fresh step-up before it
+// runs.
+func (c *ServerConfig) RequiresStepUp(msgType string) bool {
+	return containsScope(c.StepUpTypes, msgType)
+}
+
+// StepUpWindow returns how long a successful step-up covers further
+// sensitive messages on the same connection.
+func (c *ServerConfig) StepUpWindow() time.Duration {
+	if c.StepUpGrace <= 0 {
+		return DefaultStepUpGrace
+	}
+	return time.Duration(c.StepUpGrace) * time.Second
+}
+
+// ValidateStepUpKey checks the form of a PIN key sent by a device.
+func ValidateStepUpKey(key string) error {
+	if len(key) != stepUpKeyLen {
+		return ErrInvalidStepUpKey
+	}
+	if _, err := hex.DecodeString(key); err != nil {
+		return ErrInvalidStepUpKey
+	}
+	return nil
+}
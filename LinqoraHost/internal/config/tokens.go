@@ -0,0 +1,86 @@
+package config
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// API token scopes beyond the device scopes.
+const (
+	// ScopeMetrics covers read-only host information: info, stats, metrics
+	// and the event stream.
+	ScopeMetrics = "metrics"
+	// ScopeAdmin covers managing devices, such as answering pending
+	// requests. "all" does not include it; it must be named.
+	ScopeAdmin = "admin"
+)
+
+// TokenScopes lists the scopes "all" grants to an API token: every device
+// scope plus ScopeMetrics.
+var TokenScopes = append([]string{ScopeMetrics}, AllScopes...)
+
+// validTokenScopes lists every scope an API token can be created with.
+var validTokenScopes = append(append([]string{}, TokenScopes...), ScopeAdmin)
+
+// tokenPrefix marks Linqora API tokens so they are easy to recognise in
+// configuration files and secret scanners.
+const tokenPrefix = "lqt_"
+
+// APIToken is a named credential for the REST API. Only the SHA-256 hash of
+// the token is stored; the token itself is shown once, when it is created.
+type APIToken struct {
+	Name      string     `json:"name"`
+	Hash      string     `json:"hash"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	LastUsed  *time.Time `json:"last_used,omitempty"`
+}
+
+// ParseTokenScopes parses a comma-separated API token scope list.
+func ParseTokenScopes(raw string) ([]string, error) {
+	return parseScopeList(raw, TokenScopes, validTokenScopes)
+}
+
+// GenerateToken returns a new random API token and its stored hash.
+func GenerateToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = tokenPrefix + hex.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hex SHA-256 hash under which a token is stored.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Expired reports whether the token has an expiry that has passed.
+func (t APIToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// HasScope reports whether the token grants scope.
+func (t APIToken) HasScope(scope string) bool {
+	return containsScope(t.Scopes, scope)
+}
+
+// ScopesString formats the token's scopes for display.
+func (t APIToken) ScopesString() string {
+	return formatScopes(t.Scopes, TokenScopes)
+}
+
+// FindToken returns the index of the token called name, or -1.
+func (c *ServerConfig) FindToken(name string) int {
+	for i, t := range c.APITokens {
+		if t.Name == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,148 @@
+// Package confirm asks the person at the host to allow or deny a remote
+// action before it runs, for the action classes the operator has chosen to
+// guard (see config.ConfirmActions). The console and the GUI read prompts
+// from a Broker and answer them with Respond.
+package confirm
+
+import (
+	"log/slog"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Outcome is the answer to a confirmation request.
+type Outcome int
+
+const (
+	// Allowed means someone at the host let the action run.
+	Allowed Outcome = iota
+	// Denied means someone at the host refused it.
+	Denied
+	// Expired means nobody answered in time; the action is refused.
+	Expired
+)
+
+// String returns the outcome as logged.
+func (o Outcome) String() string {
+	switch o {
+	case Allowed:
+		return "allowed"
+	case Denied:
+		return "denied"
+	default:
+		return "expired"
+	}
+}
+
+// Request describes an action waiting for confirmation.
+type Request struct {
+	ID         string    `json:"id"`
+	Action     string    `json:"action"`
+	Summary    string    `json:"summary"`
+	DeviceID   string    `json:"deviceId"`
+	DeviceName string    `json:"deviceName"`
+	IP         string    `json:"ip"`
+	Expires    time.Time `json:"expires"`
+}
+
+type pending struct {
+	req    Request
+	answer chan bool
+}
+
+// Broker hands confirmation requests to the host and waits for the answers.
+type Broker struct {
+	mu       sync.Mutex
+	pending  map[string]*pending
+	requests chan Request
+	nextID   uint64
+}
+
+// NewBroker creates a broker with no pending requests.
+func NewBroker() *Broker {
+	return &Broker{
+		pending:  make(map[string]*pending),
+		requests: make(chan Request, 10),
+	}
+}
+
+// Requests delivers each new request to the host's prompt.
+func (b *Broker) Requests() <-chan Request {
+	return b.requests
+}
+
+// Ask shows req at the host and blocks until it is answered or timeout
+// passes. req.ID and req.Expires are filled in.
+func (b *Broker) Ask(req Request, timeout time.Duration) Outcome {
+	p := &pending{answer: make(chan bool, 1)}
+
+	b.mu.Lock()
+	b.nextID++
+	req.ID = strconv.FormatUint(b.nextID, 10)
+	req.Expires = time.Now().Add(timeout)
+	p.req = req
+	b.pending[req.ID] = p
+	b.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		delete(b.pending, req.ID)
+		b.mu.Unlock()
+	}()
+
+	select {
+	case b.requests <- req:
+	default:
+		// The prompt is busy; the request can still be answered by ID.
+		slog.Warn("Confirmation prompt queue full", "id", req.ID, "action", req.Action)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	outcome := Expired
+	select {
+	case allowed := <-p.answer:
+		outcome = Denied
+		if allowed {
+			outcome = Allowed
+		}
+	case <-timer.C:
+	}
+
+	slog.Info("Remote action confirmation", "id", req.ID, "action", req.Action, "summary", req.Summary,
+		"device", req.DeviceName, "ip", req.IP, "outcome", outcome.String())
+	return outcome
+}
+
+// Respond answers the request with the given ID. It reports whether the
+// request was still waiting.
+func (b *Broker) Respond(id string, allow bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p, ok := b.pending[id]
+	if !ok {
+		return false
+	}
+	delete(b.pending, id)
+	p.answer <- allow
+	return true
+}
+
+// Pending returns the requests still waiting, oldest first.
+func (b *Broker) Pending() []Request {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	reqs := make([]Request, 0, len(b.pending))
+	for _, p := range b.pending {
+		reqs = append(reqs, p.req)
+	}
+	sort.Slice(reqs, func(i, j int) bool {
+		return reqs[i].Expires.Before(reqs[j].Expires)
+	})
+	return reqs
+}
@@ -0,0 +1,40 @@
+package confirm
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAskWaitsForAnswer(t *testing.T) {
+	b := NewBroker()
+
+	done := make(chan Outcome)
+	go func() { done <- b.Ask(Request{Action: "power", Summary: "shutdown"}, time.Minute) }()
+
+	req := <-b.Requests()
+	if req.ID == "" || req.Action != "power" {
+		t.Fatalf("Unexpected request %+v", req)
+	}
+	if pending := b.Pending(); len(pending) != 1 || pending[0].ID != req.ID {
+		t.Errorf("Expected the request to be listed as pending, got %+v", pending)
+	}
+	if !b.Respond(req.ID, false) {
+		t.Fatal("Expected the request to accept an answer")
+	}
+	if outcome := <-done; outcome != Denied {
+		t.Errorf("Expected Denied, got %v", outcome)
+	}
+	if b.Respond(req.ID, true) {
+		t.Error("An answered request must not accept another answer")
+	}
+	if len(b.Pending()) != 0 {
+		t.Error("Expected no pending requests")
+	}
+}
+
+func TestAskExpires(t *testing.T) {
+	b := NewBroker()
+	if outcome := b.Ask(Request{Action: "shell"}, 20*time.Millisecond); outcome != Expired {
+		t.Errorf("Expected Expired, got %v", outcome)
+	}
+}
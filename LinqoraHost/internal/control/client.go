@@ -0,0 +1,96 @@
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// Call sends command with args to the running host and decodes its result
+// into result, which may be nil. It returns ErrNotRunning when no host
+// answers, so that callers can fall back to editing the config file.
+func Call(command string, args, result interface{}) error {
+	conn, reader, err := send(command, args)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	data, err := readResponse(reader)
+	if err != nil {
+		return err
+	}
+	if result != nil && len(data) > 0 {
+		return json.Unmarshal(data, result)
+	}
+	return nil
+}
+
+// TailLogs writes the last lines of the host's log to w. With follow set it
+// keeps writing new lines until the connection ends.
+func TailLogs(lines int, follow bool, w io.Writer) error {
+	conn, reader, err := send(CmdLogs, LogsArgs{Lines: lines, Follow: follow})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	data, err := readResponse(reader)
+	if err != nil {
+		return err
+	}
+	var backlog []string
+	if err := json.Unmarshal(data, &backlog); err != nil {
+		return err
+	}
+	for _, line := range backlog {
+		io.WriteString(w, line)
+	}
+	if !follow {
+		return nil
+	}
+
+	_, err = io.Copy(w, reader)
+	return err
+}
+
+// send dials the admin socket and writes one request.
+func send(command string, args interface{}) (net.Conn, *bufio.Reader, error) {
+	conn, err := net.DialTimeout("unix", socketPath(), dialTimeout)
+	if err != nil {
+		return nil, nil, ErrNotRunning
+	}
+
+	req := Request{Command: command}
+	if args != nil {
+		if req.Args, err = json.Marshal(args); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+	}
+	data, _ := json.Marshal(req)
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	return conn, bufio.NewReader(conn), nil
+}
+
+// readResponse reads one response line and turns a failure into an error.
+func readResponse(reader *bufio.Reader) (json.RawMessage, error) {
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	var resp Response
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	if !resp.OK {
+		return nil, errors.New(resp.Error)
+	}
+	return resp.Data, nil
+}
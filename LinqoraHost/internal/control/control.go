@@ -0,0 +1,264 @@
+// Package control serves the local admin socket of a running host. CLI
+// commands use it to act on the live server (revoke a device and drop its
+// connections, list sessions, reload the config or the TLS certificate,
+// tail logs) instead of editing the config file behind the server's back.
+//
+// The socket is a Unix domain socket next to the config file, readable and
+// writable by its owner only. Windows 10 and later support Unix sockets as
+// well; there the socket relies on the permissions of the user's profile.
+package control
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"LinqoraHost/internal/config"
+)
+
+// Commands understood by the admin socket.
+const (
+	CmdRevoke     = "revoke"
+	CmdSessions   = "sessions"
+	CmdReload     = "reload"
+	CmdLogs       = "logs"
+	CmdCertReload = "cert_reload"
+)
+
+const (
+	socketFileName = "linqora.sock"
+	// requestTimeout bounds how long a peer may take to send its request.
+	requestTimeout = 5 * time.Second
+	// dialTimeout bounds how long the CLI waits for the server to answer.
+	dialTimeout = 2 * time.Second
+)
+
+// ErrNotRunning means no host is listening on the admin socket.
+var ErrNotRunning = errors.New("linqora host is not running")
+
+// socketPath returns the location of the admin socket.
+var socketPath = func() string { return config.DataPath(socketFileName) }
+
+// Request is sent by the CLI, one per connection.
+type Request struct {
+	Command string          `json:"command"`
+	Args    json.RawMessage `json:"args,omitempty"`
+}
+
+// Response answers a Request. Log tailing follows it with raw log lines.
+type Response struct {
+	OK    bool            `json:"ok"`
+	Error string          `json:"error,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// RevokeArgs are the arguments of CmdRevoke.
+type RevokeArgs struct {
+	DeviceID string `json:"deviceId"`
+}
+
+// RevokeResult answers CmdRevoke.
+type RevokeResult struct {
+	DeviceName   string `json:"deviceName"`
+	Disconnected int    `json:"disconnected"`
+}
+
+// ReloadResult answers CmdReload.
+type ReloadResult struct {
+	Devices int `json:"devices"`
+	Tokens  int `json:"tokens"`
+	// Revoked lists devices dropped from the config, now disconnected.
+	Revoked []string `json:"revoked,omitempty"`
+	// Refused counts connections closed because their address is no longer
+	// allowed.
+	Refused int `json:"refused,omitempty"`
+	// RestartRequired lists changed settings that apply on the next start.
+	RestartRequired []string `json:"restartRequired,omitempty"`
+}
+
+// CertReloadResult answers CmdCertReload.
+type CertReloadResult struct {
+	Fingerprint string    `json:"fingerprint"`
+	NotAfter    time.Time `json:"notAfter"`
+}
+
+// LogsArgs are the arguments of CmdLogs.
+type LogsArgs struct {
+	Lines  int  `json:"lines"`
+	Follow bool `json:"follow"`
+}
+
+// HandlerFunc serves one command. The result is sent back as JSON.
+type HandlerFunc func(args json.RawMessage) (interface{}, error)
+
+// Server accepts admin connections on the local socket.
+type Server struct {
+	logs     *LogBuffer
+	handlers map[string]HandlerFunc
+	mu       sync.Mutex
+}
+
+// NewServer creates a server that tails logs from logs. Commands other than
+// CmdLogs are added with Handle.
+func NewServer(logs *LogBuffer) *Server {
+	return &Server{
+		logs:     logs,
+		handlers: make(map[string]HandlerFunc),
+	}
+}
+
+// Handle registers fn for command, replacing any earlier handler.
+func (s *Server) Handle(command string, fn HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[command] = fn
+}
+
+// Start listens on the admin socket and serves it until ctx is done, when
+// the socket file is removed. A socket left behind by a crashed host is
+// replaced; one that still answers belongs to another host and is an error.
+func (s *Server) Start(ctx context.Context) error {
+	path := socketPath()
+	if conn, err := net.DialTimeout("unix", path, dialTimeout); err == nil {
+		conn.Close()
+		return fmt.Errorf("another host is already listening on %s", path)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on admin socket: %w", err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to restrict admin socket: %w", err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+		os.Remove(path)
+	}()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Error("Admin socket accept failed", "err", err)
+				}
+				return
+			}
+			go s.serveConn(ctx, conn)
+		}
+	}()
+
+	slog.Info("Admin socket listening", "path", path)
+	return nil
+}
+
+// serveConn reads one request from conn and answers it.
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(requestTimeout))
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	var req Request
+	if err := json.Unmarshal(line, &req); err != nil {
+		writeResponse(conn, nil, errors.New("invalid request"))
+		return
+	}
+
+	if req.Command == CmdLogs {
+		s.serveLogs(ctx, conn, reader, req.Args)
+		return
+	}
+
+	s.mu.Lock()
+	fn, ok := s.handlers[req.Command]
+	s.mu.Unlock()
+	if !ok {
+		writeResponse(conn, nil, fmt.Errorf("unknown command %q", req.Command))
+		return
+	}
+
+	result, err := fn(req.Args)
+	if err == nil {
+		slog.Info("Admin command served", "command", req.Command)
+	}
+	writeResponse(conn, result, err)
+}
+
+// serveLogs sends the last lines of the log and, when asked to follow, every
+// new line until the peer hangs up or the server stops.
+func (s *Server) serveLogs(ctx context.Context, conn net.Conn, reader io.Reader, raw json.RawMessage) {
+	var args LogsArgs
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &args); err != nil {
+			writeResponse(conn, nil, errors.New("invalid arguments"))
+			return
+		}
+	}
+
+	lines, unsubscribe := s.logs.subscribe()
+	defer unsubscribe()
+
+	if err := writeResponse(conn, s.logs.Tail(args.Lines), nil); err != nil || !args.Follow {
+		return
+	}
+
+	// The peer sends nothing more; a read returning means it hung up.
+	gone := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, reader)
+		close(gone)
+	}()
+
+	for {
+		select {
+		case line := <-lines:
+			if _, err := io.WriteString(conn, line); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// writeResponse sends result, or err if it is set, as one JSON line.
+func writeResponse(w io.Writer, result interface{}, err error) error {
+	resp := Response{OK: err == nil}
+	if err != nil {
+		resp.Error = err.Error()
+	} else if result != nil {
+		data, merr := json.Marshal(result)
+		if merr != nil {
+			resp = Response{Error: "failed to encode result"}
+		} else {
+			resp.Data = data
+		}
+	}
+
+	data, _ := json.Marshal(resp)
+	_, werr := w.Write(append(data, '\n'))
+	return werr
+}
@@ -0,0 +1,105 @@
+package control
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useTempSocket points the admin socket at a fresh directory for one test.
+func useTempSocket(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	orig := socketPath
+	socketPath = func() string { return filepath.Join(dir, "c.sock") }
+	t.Cleanup(func() { socketPath = orig })
+}
+
+func startServer(t *testing.T, logs *LogBuffer) *Server {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	srv := NewServer(logs)
+	srv.Handle(CmdRevoke, func(raw json.RawMessage) (interface{}, error) {
+		var args RevokeArgs
+		json.Unmarshal(raw, &args)
+		if args.DeviceID != "dev-1" {
+			return nil, errors.New("device not found")
+		}
+		return RevokeResult{DeviceName: "Phone", Disconnected: 2}, nil
+	})
+	if err := srv.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+func TestCallReachesHandler(t *testing.T) {
+	useTempSocket(t)
+
+	if err := Call(CmdRevoke, RevokeArgs{DeviceID: "dev-1"}, nil); !errors.Is(err, ErrNotRunning) {
+		t.Fatalf("Expected ErrNotRunning without a server, got %v", err)
+	}
+
+	startServer(t, NewLogBuffer(10))
+
+	var result RevokeResult
+	if err := Call(CmdRevoke, RevokeArgs{DeviceID: "dev-1"}, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.DeviceName != "Phone" || result.Disconnected != 2 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+
+	if err := Call(CmdRevoke, RevokeArgs{DeviceID: "other"}, nil); err == nil || err.Error() != "device not found" {
+		t.Errorf("Expected the handler error, got %v", err)
+	}
+	if err := Call("format_disk", nil, nil); err == nil {
+		t.Error("Expected an unknown command to fail")
+	}
+}
+
+func TestStartRefusesSocketInUse(t *testing.T) {
+	useTempSocket(t)
+	startServer(t, NewLogBuffer(10))
+
+	if err := NewServer(NewLogBuffer(10)).Start(context.Background()); err == nil {
+		t.Error("Expected a second server on the same socket to be refused")
+	}
+}
+
+func TestTailLogsFollowsNewLines(t *testing.T) {
+	useTempSocket(t)
+	logs := NewLogBuffer(2)
+	io.WriteString(logs, "one\ntwo\n")
+	io.WriteString(logs, "three\n")
+	startServer(t, logs)
+
+	var out strings.Builder
+	if err := TailLogs(0, false, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "two\nthree\n" {
+		t.Errorf("Expected the buffered tail, got %q", out.String())
+	}
+
+	pr, pw := io.Pipe()
+	go TailLogs(1, true, pw)
+	reader := bufio.NewReader(pr)
+	if line, _ := reader.ReadString('\n'); line != "three\n" {
+		t.Fatalf("Expected the last line first, got %q", line)
+	}
+
+	// The follower subscribed before the tail was sent.
+	io.WriteString(logs, "four\n")
+	if line, _ := reader.ReadString('\n'); line != "four\n" {
+		t.Errorf("Expected a followed line, got %q", line)
+	}
+	pr.Close()
+}
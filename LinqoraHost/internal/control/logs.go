@@ -0,0 +1,80 @@
+package control
+
+import (
+	"strings"
+	"sync"
+)
+
+// LogBuffer keeps the most recent log lines for CmdLogs. Install it as (part
+// of) the slog output; every Write is expected to hold whole lines.
+type LogBuffer struct {
+	mu    sync.Mutex
+	lines []string
+	max   int
+	subs  map[chan string]struct{}
+}
+
+// NewLogBuffer creates a buffer holding up to max lines.
+func NewLogBuffer(max int) *LogBuffer {
+	return &LogBuffer{
+		max:  max,
+		subs: make(map[chan string]struct{}),
+	}
+}
+
+// Write stores each line of p and hands it to followers. Followers that fall
+// behind miss lines rather than slowing down logging.
+func (b *LogBuffer) Write(p []byte) (int, error) {
+	text := string(p)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for text != "" {
+		line := text
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			line, text = text[:i+1], text[i+1:]
+		} else {
+			line, text = text+"\n", ""
+		}
+
+		b.lines = append(b.lines, line)
+		if len(b.lines) > b.max {
+			b.lines = b.lines[len(b.lines)-b.max:]
+		}
+		for ch := range b.subs {
+			select {
+			case ch <- line:
+			default:
+			}
+		}
+	}
+	return len(p), nil
+}
+
+// Tail returns the last n lines, or every stored line when n <= 0.
+func (b *LogBuffer) Tail(n int) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if n <= 0 || n > len(b.lines) {
+		n = len(b.lines)
+	}
+	return append([]string(nil), b.lines[len(b.lines)-n:]...)
+}
+
+// subscribe returns a channel receiving new lines and a function that stops
+// the delivery.
+func (b *LogBuffer) subscribe() (<-chan string, func()) {
+	ch := make(chan string, 256)
+
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subs, ch)
+		b.mu.Unlock()
+	}
+}
@@ -0,0 +1,130 @@
+// Package events provides the in-process publish/subscribe bus that connects
+// producers (collectors, monitors, the scheduler, auth) with consumers such as
+// WebSocket rooms and event streams.
+package events
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Topic names a stream of events whose payloads all have type T. Producers
+// declare their topics next to the payload type, for example
+// collectors.MetricsTopic, so that subscribers get the concrete type back.
+type Topic[T any] struct {
+	name string
+}
+
+// NewTopic declares a topic. Names must be unique across the program.
+func NewTopic[T any](name string) Topic[T] {
+	return Topic[T]{name: name}
+}
+
+// Name returns the topic name.
+func (t Topic[T]) Name() string {
+	return t.name
+}
+
+// Event is the untyped form of a published event, as seen by subscribers to
+// every topic.
+type Event struct {
+	Topic   string
+	Payload interface{}
+	Time    time.Time
+}
+
+type subscriber struct {
+	id    uint64
+	topic string // empty for subscribers to every topic
+	fn    func(Event)
+}
+
+// Bus delivers events synchronously, in the publisher's goroutine and in
+// subscription order. Handlers must therefore return quickly and must not
+// block; queueing work elsewhere is fine. A nil *Bus discards everything,
+// which keeps producers usable on their own (for example in tests).
+type Bus struct {
+	mu     sync.RWMutex
+	subs   []*subscriber
+	nextID uint64
+}
+
+// NewBus creates a bus with no subscribers.
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Publish sends payload to every subscriber of topic.
+func Publish[T any](b *Bus, topic Topic[T], payload T) {
+	if b == nil {
+		return
+	}
+	b.publish(Event{Topic: topic.name, Payload: payload, Time: time.Now()})
+}
+
+// Subscribe calls fn for every event published on topic. It returns a
+// function that removes the subscription.
+func Subscribe[T any](b *Bus, topic Topic[T], fn func(T)) (unsubscribe func()) {
+	return b.subscribe(topic.name, func(e Event) {
+		fn(e.Payload.(T))
+	})
+}
+
+// SubscribeAll calls fn for events on every topic.
+func (b *Bus) SubscribeAll(fn func(Event)) (unsubscribe func()) {
+	return b.subscribe("", fn)
+}
+
+func (b *Bus) subscribe(topic string, fn func(Event)) func() {
+	if b == nil {
+		return func() {}
+	}
+
+	b.mu.Lock()
+	b.nextID++
+	id := b.nextID
+	b.subs = append(b.subs, &subscriber{id: id, topic: topic, fn: fn})
+	b.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { b.unsubscribe(id) })
+	}
+}
+
+func (b *Bus) unsubscribe(id uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, sub := range b.subs {
+		if sub.id == id {
+			b.subs = append(b.subs[:i:i], b.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+func (b *Bus) publish(e Event) {
+	b.mu.RLock()
+	targets := make([]*subscriber, 0, len(b.subs))
+	for _, sub := range b.subs {
+		if sub.topic == "" || sub.topic == e.Topic {
+			targets = append(targets, sub)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, sub := range targets {
+		deliver(sub, e)
+	}
+}
+
+// deliver runs one handler, isolating the publisher from its panics.
+func deliver(sub *subscriber, e Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("Panic recovered in event subscriber", "topic", e.Topic, "err", r)
+		}
+	}()
+	sub.fn(e)
+}
@@ -0,0 +1,64 @@
+package events
+
+import (
+	"testing"
+)
+
+type sample struct {
+	Value int
+}
+
+func TestSubscribeReceivesTypedPayload(t *testing.T) {
+	bus := NewBus()
+	topic := NewTopic[sample]("sample")
+	other := NewTopic[string]("other")
+
+	var got []int
+	Subscribe(bus, topic, func(s sample) { got = append(got, s.Value) })
+
+	Publish(bus, topic, sample{Value: 1})
+	Publish(bus, other, "ignored")
+	Publish(bus, topic, sample{Value: 2})
+
+	if len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("Expected [1 2], got %v", got)
+	}
+}
+
+func TestSubscribeAllAndUnsubscribe(t *testing.T) {
+	bus := NewBus()
+	topic := NewTopic[int]("n")
+
+	var topics []string
+	unsubscribe := bus.SubscribeAll(func(e Event) { topics = append(topics, e.Topic) })
+
+	Publish(bus, topic, 1)
+	unsubscribe()
+	unsubscribe()
+	Publish(bus, topic, 2)
+
+	if len(topics) != 1 || topics[0] != "n" {
+		t.Errorf("Expected one event on n, got %v", topics)
+	}
+}
+
+func TestPanickingSubscriberDoesNotStopDelivery(t *testing.T) {
+	bus := NewBus()
+	topic := NewTopic[int]("n")
+
+	delivered := false
+	Subscribe(bus, topic, func(int) { panic("boom") })
+	Subscribe(bus, topic, func(int) { delivered = true })
+
+	Publish(bus, topic, 1)
+
+	if !delivered {
+		t.Error("Expected the second subscriber to receive the event")
+	}
+}
+
+func TestNilBusIsNoOp(t *testing.T) {
+	var bus *Bus
+	Publish(bus, NewTopic[int]("n"), 1)
+	Subscribe(bus, NewTopic[int]("n"), func(int) {})()
+}
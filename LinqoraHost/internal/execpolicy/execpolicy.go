@@ -0,0 +1,235 @@
+// Package execpolicy decides whether a command sent by a remote device may
+// run on the host, following config.ExecPolicy. It covers shell_exec command
+// lines and scheduler scripts alike; callers log every Decision.
+package execpolicy
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"LinqoraHost/internal/config"
+)
+
+// safeEnv are the variables a scrubbed environment keeps: enough to find
+// programs and temporary files, nothing that carries credentials.
+var safeEnv = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "LANG", "LC_ALL", "TERM", "TMPDIR",
+	"TEMP", "TMP", "SYSTEMROOT", "WINDIR", "COMSPEC", "PATHEXT", "USERPROFILE",
+}
+
+// Decision is the outcome of a policy check.
+type Decision struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason"`
+	// Dir is the working directory the command runs in. Empty keeps the
+	// host's own.
+	Dir string `json:"dir,omitempty"`
+}
+
+// wrappers run a command given in their arguments or input, which the rules
+// would only see as arguments. Under rules, shell_exec refuses them rather
+// than guess at what they run.
+var wrappers = map[string]bool{
+	"command": true, "exec": true, "eval": true, "builtin": true, "env": true,
+	"nohup": true, "xargs": true, "source": true, ".": true,
+	"sh": true, "bash": true, "dash": true, "zsh": true, "ksh": true, "ash": true,
+	"busybox": true, "cmd": true, "powershell": true, "pwsh": true, "start": true,
+}
+
+func allow(reason string) Decision { return Decision{Allowed: true, Reason: reason} }
+
+func deny(format string, args ...interface{}) Decision {
+	return Decision{Reason: fmt.Sprintf(format, args...)}
+}
+
+// CheckShell decides whether deviceID may run cmdline through shell_exec in
+// workDir. Under rules or DefaultDeny, every command of a pipeline or list is
+// checked, and constructs that hide what runs (substitution, redirection,
+// subshells, wrappers such as eval or sh -c) are refused.
+func CheckShell(p *config.ExecPolicy, deviceID, cmdline, workDir string) Decision {
+	eff := p.ForDevice(deviceID)
+	if eff.DisableShell {
+		return deny("shell_exec is disabled")
+	}
+	dir, d := checkDir(&eff, workDir)
+	if !d.Allowed {
+		return d
+	}
+	if len(eff.Rules) == 0 && !eff.DefaultDeny {
+		d = allow("no rules")
+		d.Dir = dir
+		return d
+	}
+
+	commands, err := splitCommands(cmdline, runtime.GOOS == "windows")
+	if err != nil {
+		return deny("%v", err)
+	}
+	d = allow("no rules")
+	for _, words := range commands {
+		if base, _ := execNames(words[0]); wrappers[base] {
+			return deny("%s runs other commands, which the exec policy cannot check", base)
+		}
+		d = matchRules(&eff, words[0], words[1:])
+		if !d.Allowed {
+			return d
+		}
+	}
+	d.Dir = dir
+	return d
+}
+
+// CheckCommand decides whether deviceID may run name with args in workDir,
+// as a script does. An empty deviceID stands for the host's own schedule and
+// REST callers, which get the global policy.
+func CheckCommand(p *config.ExecPolicy, deviceID, name string, args []string, workDir string) Decision {
+	eff := p.ForDevice(deviceID)
+	dir, d := checkDir(&eff, workDir)
+	if !d.Allowed {
+		return d
+	}
+	d = matchRules(&eff, name, args)
+	if d.Allowed {
+		d.Dir = dir
+	}
+	return d
+}
+
+// Env returns the environment for commands under p, or nil for the host's
+// own when p does not scrub it.
+func Env(p *config.ExecPolicy) []string {
+	if !p.ScrubEnv {
+		return nil
+	}
+	keep := append(append([]string(nil), safeEnv...), p.KeepEnv...)
+	env := []string{}
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		for _, k := range keep {
+			if strings.EqualFold(name, k) {
+				env = append(env, kv)
+				break
+			}
+		}
+	}
+	return env
+}
+
+// checkDir resolves the working directory under the policy's WorkDirs.
+func checkDir(p *config.ExecPolicy, workDir string) (string, Decision) {
+	if len(p.WorkDirs) == 0 {
+		return workDir, allow("")
+	}
+	if workDir == "" {
+		return p.WorkDirs[0], allow("")
+	}
+	abs, err := resolveDir(workDir)
+	if err != nil {
+		return "", deny("invalid working directory %q", workDir)
+	}
+	for _, root := range p.WorkDirs {
+		rootAbs, err := resolveDir(root)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(rootAbs, abs)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return abs, allow("")
+		}
+	}
+	return "", deny("working directory %s is outside the allowed directories", workDir)
+}
+
+// resolveDir makes dir absolute and follows symlinks, so that a link inside
+// an allowed directory cannot lead out of it.
+func resolveDir(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved, nil
+	}
+	return abs, nil
+}
+
+// matchRules applies the first rule matching the command, or the default.
+func matchRules(p *config.ExecPolicy, name string, args []string) Decision {
+	base, full := execNames(name)
+	joined := strings.Join(args, " ")
+	for i, r := range p.Rules {
+		matched, err := execMatches(r.Exec, base, full)
+		if err != nil {
+			return deny("rule %d has an invalid exec pattern", i+1)
+		}
+		if !matched {
+			continue
+		}
+		if r.Args != "" {
+			re, err := regexp.Compile(r.Args)
+			if err != nil {
+				return deny("rule %d has an invalid args pattern", i+1)
+			}
+			if !re.MatchString(joined) {
+				continue
+			}
+		}
+		if r.Action == config.ExecAllow {
+			return allow(fmt.Sprintf("%s allowed by rule %d (%s)", base, i+1, describe(r)))
+		}
+		return deny("%s denied by rule %d (%s)", base, i+1, describe(r))
+	}
+	if p.DefaultDeny {
+		return deny("%s is not on the allowlist", base)
+	}
+	if len(p.Rules) == 0 {
+		return allow("no rules")
+	}
+	return allow(fmt.Sprintf("%s matches no deny rule", base))
+}
+
+// execNames returns the executable's base name and full path as rules see
+// them: with forward slashes and, on Windows, lower-cased without extension.
+func execNames(name string) (base, full string) {
+	full = strings.ReplaceAll(name, `\`, "/")
+	base = path.Base(full)
+	if runtime.GOOS == "windows" {
+		full = strings.ToLower(full)
+		base = strings.ToLower(base)
+		base = trimExt(base)
+	}
+	return base, full
+}
+
+// trimExt drops the extensions Windows runs without being told.
+func trimExt(name string) string {
+	for _, ext := range []string{".exe", ".com", ".bat", ".cmd"} {
+		name = strings.TrimSuffix(name, ext)
+	}
+	return name
+}
+
+func execMatches(pattern, base, full string) (bool, error) {
+	if runtime.GOOS == "windows" {
+		pattern = strings.ToLower(pattern)
+	}
+	if strings.ContainsAny(pattern, `/\`) {
+		return path.Match(strings.ReplaceAll(pattern, `\`, "/"), full)
+	}
+	if runtime.GOOS == "windows" {
+		pattern = trimExt(pattern)
+	}
+	return path.Match(pattern, base)
+}
+
+func describe(r config.ExecRule) string {
+	if r.Args == "" {
+		return "exec " + r.Exec
+	}
+	return fmt.Sprintf("exec %s, args /%s/", r.Exec, r.Args)
+}
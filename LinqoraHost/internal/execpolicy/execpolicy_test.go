@@ -0,0 +1,156 @@
+package execpolicy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"LinqoraHost/internal/config"
+)
+
+func TestSplitCommands(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    [][]string
+		wantErr bool
+	}{
+		{line: `ls -la`, want: [][]string{{"ls", "-la"}}},
+		{line: `echo "a b" 'c d' e\ f`, want: [][]string{{"echo", "a b", "c d", "e f"}}},
+		{line: `ps aux | grep x && uptime; date`, want: [][]string{{"ps", "aux"}, {"grep", "x"}, {"uptime"}, {"date"}}},
+		{line: `echo "a | b"`, want: [][]string{{"echo", "a | b"}}},
+		{line: `echo $(rm -rf /)`, wantErr: true},
+		{line: "echo `id`", wantErr: true},
+		{line: `echo "$(id)"`, wantErr: true},
+		{line: `cat < /etc/shadow`, wantErr: true},
+		{line: `(rm x)`, wantErr: true},
+		{line: `$CMD -f`, wantErr: true},
+		{line: `r* -f`, wantErr: true},
+		{line: `FOO=1 rm x`, wantErr: true},
+		{line: `if true; then rm x; fi`, wantErr: true},
+		{line: `echo "open`, wantErr: true},
+		{line: ` ; `, wantErr: true},
+		{line: "r\\\nm -rf /tmp/x", want: [][]string{{"rm", "-rf", "/tmp/x"}}},
+		{line: "echo \"a\\\r\nb\"", want: [][]string{{"echo", "ab"}}},
+		{line: "echo 'a\\\nb'", want: [][]string{{"echo", "a\\\nb"}}},
+		{line: `{rm,-rf,/tmp/x}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := splitCommands(tt.line, false)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("splitCommands(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitCommands(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestSplitCommandsWindows(t *testing.T) {
+	got, err := splitCommands(`dir "C:\Program Files" & echo a^&b`, true)
+	want := [][]string{{"dir", `C:\Program Files`}, {"echo", "a&b"}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, %v; want %q", got, err, want)
+	}
+	if _, err := splitCommands(`%COMSPEC% /c del x`, true); err == nil {
+		t.Error("expected a variable command name to be refused")
+	}
+	if got, err := splitCommands("de^\r\nl x", true); err != nil || !reflect.DeepEqual(got, [][]string{{"del", "x"}}) {
+		t.Errorf("expected a continued line to be joined, got %q, %v", got, err)
+	}
+}
+
+func TestCheckShell(t *testing.T) {
+	deny := &config.ExecPolicy{Rules: []config.ExecRule{
+		{Action: config.ExecDeny, Exec: "rm"},
+		{Action: config.ExecDeny, Exec: "git", Args: `^push\b`},
+	}}
+	allowlist := &config.ExecPolicy{
+		DefaultDeny: true,
+		Rules:       []config.ExecRule{{Action: config.ExecAllow, Exec: "uptime"}, {Action: config.ExecAllow, Exec: "ls"}},
+		Devices: map[string]config.ExecOverride{
+			"laptop": {Rules: []config.ExecRule{{Action: config.ExecAllow, Exec: "git"}}},
+			"kiosk":  {DisableShell: boolPtr(true)},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		policy *config.ExecPolicy
+		device string
+		line   string
+		want   bool
+	}{
+		{"no policy allows anything", &config.ExecPolicy{}, "", "rm -rf /tmp/x > /dev/null", true},
+		{"disabled", &config.ExecPolicy{DisableShell: true}, "", "ls", false},
+		{"denylist blocks", deny, "", "rm -f x", false},
+		{"denylist blocks by path", deny, "", "/bin/rm x", false},
+		{"denylist blocks in a pipeline", deny, "", "ls | rm x", false},
+		{"denylist args", deny, "", "git push origin", false},
+		{"denylist other args", deny, "", "git status", true},
+		{"allowlist allows", allowlist, "", "uptime && ls -l", true},
+		{"allowlist refuses", allowlist, "", "uptime; whoami", false},
+		{"device override adds", allowlist, "laptop", "git status", true},
+		{"override is per device", allowlist, "phone", "git status", false},
+		{"device can be switched off", allowlist, "kiosk", "uptime", false},
+		{"line continuation", deny, "", "r\\\nm -rf /tmp/x", false},
+		{"command wrapper", deny, "", "command rm x", false},
+		{"exec wrapper", deny, "", "exec rm x", false},
+		{"eval wrapper", deny, "", "eval rm x", false},
+		{"builtin wrapper", deny, "", "builtin eval rm x", false},
+		{"env wrapper", deny, "", "/usr/bin/env rm x", false},
+		{"nohup wrapper", deny, "", "nohup rm x", false},
+		{"xargs wrapper", deny, "", "echo x | xargs rm", false},
+		{"sh -c", deny, "", "sh -c 'rm x'", false},
+		{"bash -c", deny, "", "bash -c 'rm x'", false},
+		{"piped into a shell", deny, "", "echo 'rm x' | bash", false},
+		{"brace expansion", deny, "", "{rm,-rf,/tmp/x}", false},
+		{"wrappers refused on an allowlist too", allowlist, "", "env uptime", false},
+	}
+	for _, tt := range tests {
+		d := CheckShell(tt.policy, tt.device, tt.line, "")
+		if d.Allowed != tt.want {
+			t.Errorf("%s: CheckShell(%q) = %+v, want allowed=%v", tt.name, tt.line, d, tt.want)
+		}
+		if d.Reason == "" {
+			t.Errorf("%s: expected a reason", tt.name)
+		}
+	}
+}
+
+func TestCheckCommandWorkDirs(t *testing.T) {
+	root := t.TempDir()
+	inside := filepath.Join(root, "sub")
+	os.Mkdir(inside, 0755)
+	p := &config.ExecPolicy{WorkDirs: []string{root}}
+
+	if d := CheckCommand(p, "", "ls", nil, ""); !d.Allowed || d.Dir != root {
+		t.Errorf("expected the first work dir by default, got %+v", d)
+	}
+	if d := CheckCommand(p, "", "ls", nil, inside); !d.Allowed {
+		t.Errorf("expected a subdirectory to be allowed, got %+v", d)
+	}
+	if d := CheckCommand(p, "", "ls", nil, filepath.Join(root, "..")); d.Allowed {
+		t.Errorf("expected the parent directory to be refused, got %+v", d)
+	}
+}
+
+func TestEnvScrubs(t *testing.T) {
+	t.Setenv("LINQORA_TEST_SECRET", "x")
+	t.Setenv("LINQORA_TEST_KEEP", "y")
+
+	if env := Env(&config.ExecPolicy{}); env != nil {
+		t.Error("expected the host environment without scrubbing")
+	}
+	env := Env(&config.ExecPolicy{ScrubEnv: true, KeepEnv: []string{"LINQORA_TEST_KEEP"}})
+	has := map[string]bool{}
+	for _, kv := range env {
+		has[kv] = true
+	}
+	if has["LINQORA_TEST_SECRET=x"] || !has["LINQORA_TEST_KEEP=y"] {
+		t.Errorf("unexpected scrubbed environment %q", env)
+	}
+}
+
+func boolPtr(b bool) *bool { return &b }
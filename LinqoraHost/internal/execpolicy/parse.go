@@ -0,0 +1,186 @@
+package execpolicy
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	errEmpty        = errors.New("empty command")
+	errQuote        = errors.New("unterminated quote")
+	errSubstitution = errors.New("command substitution is not allowed by the exec policy")
+	errRedirect     = errors.New("redirection is not allowed by the exec policy")
+	errSubshell     = errors.New("subshells and grouping are not allowed by the exec policy")
+	errExpansion    = errors.New("the command name must be literal under the exec policy")
+	errAssignment   = errors.New("environment assignments are not allowed by the exec policy")
+)
+
+var assignment = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// keywords start compound commands, whose inner commands would otherwise
+// pass as arguments.
+var keywords = map[string]bool{
+	"!": true, "{": true, "}": true, "[[": true, "if": true, "then": true, "else": true,
+	"elif": true, "fi": true, "case": true, "esac": true, "for": true, "select": true,
+	"while": true, "until": true, "do": true, "done": true, "function": true, "time": true,
+	"coproc": true,
+}
+
+// windowsKeywords are the cmd.exe equivalents.
+var windowsKeywords = map[string]bool{"if": true, "for": true, "call": true}
+
+// word is one shell word. expands is set when an unquoted part could change
+// it at run time (variables, globs, brace expansion), which only matters for
+// command names.
+type word struct {
+	text    strings.Builder
+	expands bool
+	started bool
+}
+
+// splitCommands splits a shell command line into the commands it runs, each
+// as its words, so that every command of a pipeline or list can be checked.
+// It understands quoting, escapes and line continuations of sh, or of
+// cmd.exe when windows is set, and refuses whatever it cannot see through.
+func splitCommands(line string, windows bool) ([][]string, error) {
+	var (
+		commands [][]string
+		current  []string
+		w        word
+		quote    rune
+	)
+
+	endWord := func() error {
+		if !w.started {
+			return nil
+		}
+		if len(current) == 0 {
+			if w.expands {
+				return errExpansion
+			}
+			name := w.text.String()
+			if !windows && assignment.MatchString(name) {
+				return errAssignment
+			}
+			if (!windows && keywords[name]) || (windows && windowsKeywords[strings.ToLower(name)]) {
+				return fmt.Errorf("shell keyword %q is not allowed by the exec policy", name)
+			}
+		}
+		current = append(current, w.text.String())
+		w = word{}
+		return nil
+	}
+	endCommand := func() error {
+		if err := endWord(); err != nil {
+			return err
+		}
+		if len(current) > 0 {
+			commands = append(commands, current)
+			current = nil
+		}
+		return nil
+	}
+
+	escape := '\\'
+	if windows {
+		escape = '^'
+	}
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				w.text.WriteRune(c)
+			}
+			continue
+		case quote == '"':
+			switch {
+			case c == '"':
+				quote = 0
+			case !windows && c == '`':
+				return nil, errSubstitution
+			case !windows && c == '$' && i+1 < len(runes) && runes[i+1] == '(':
+				return nil, errSubstitution
+			case !windows && c == '\\' && continuationLen(runes, i) > 0:
+				i += continuationLen(runes, i)
+			case !windows && c == '\\' && i+1 < len(runes):
+				i++
+				w.text.WriteRune(runes[i])
+			default:
+				if c == '$' || (windows && c == '%') {
+					w.expands = true
+				}
+				w.text.WriteRune(c)
+			}
+			continue
+		}
+
+		switch {
+		case c == escape && continuationLen(runes, i) > 0:
+			// An escaped line break joins the lines, even inside a word.
+			i += continuationLen(runes, i)
+		case c == escape:
+			if i+1 < len(runes) {
+				i++
+				w.started = true
+				w.text.WriteRune(runes[i])
+			}
+		case c == '"' || (!windows && c == '\''):
+			quote = c
+			w.started = true
+		case c == ' ' || c == '\t':
+			if err := endWord(); err != nil {
+				return nil, err
+			}
+		case c == '|' || c == '&' || c == ';' || c == '\n' || c == '\r':
+			if err := endCommand(); err != nil {
+				return nil, err
+			}
+		case c == '<' || c == '>':
+			return nil, errRedirect
+		case c == '(' || c == ')':
+			return nil, errSubshell
+		case !windows && c == '`':
+			return nil, errSubstitution
+		case !windows && c == '$' && i+1 < len(runes) && runes[i+1] == '(':
+			return nil, errSubstitution
+		default:
+			if c == '$' || c == '*' || c == '?' || c == '[' || c == '~' || (!windows && c == '{') || (windows && c == '%') {
+				w.expands = true
+			}
+			w.started = true
+			w.text.WriteRune(c)
+		}
+	}
+
+	if quote != 0 {
+		return nil, errQuote
+	}
+	if err := endCommand(); err != nil {
+		return nil, err
+	}
+	if len(commands) == 0 {
+		return nil, errEmpty
+	}
+	return commands, nil
+}
+
+// continuationLen returns how many runes after the escape at runes[i] make up
+// a line break, which the shell removes together with the escape, or 0 if
+// there is none.
+func continuationLen(runes []rune, i int) int {
+	switch {
+	case i+1 < len(runes) && runes[i+1] == '\n':
+		return 1
+	case i+2 < len(runes) && runes[i+1] == '\r' && runes[i+2] == '\n':
+		return 2
+	}
+	return 0
+}
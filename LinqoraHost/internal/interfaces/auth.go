@@ -6,10 +6,10 @@ import (
 
 // PendingAuthRequest represents an authorization request waiting for manual approval.
 type PendingAuthRequest struct {
-	DeviceName  string
-	DeviceID    string
-	IP          string
-	RequestTime time.Time
+	DeviceName  string    `json:"deviceName"`
+	DeviceID    string    `json:"deviceId"`
+	IP          string    `json:"ip"`
+	RequestTime time.Time `json:"requestTime"`
 }
 
 // WSClient defines the required behavior for a WebSocket client in the auth subsystem.
@@ -19,9 +19,21 @@ type WSClient interface {
 	GetIP() string
 	GetDeviceID() string
 	GetDeviceName() string
+	// SetDeviceID binds the connection to a device once it has proven to be
+	// it; the server authorises messages by this ID.
 	SetDeviceID(id string)
 	SetDeviceName(name string)
+	// ClaimDeviceID records the device a client asks to authenticate as,
+	// and whether that claim is proven, e.g. by an answered challenge.
+	ClaimDeviceID(id string, proven bool)
+	// ClaimedDeviceID returns the last claim and whether it was proven.
+	ClaimedDeviceID() (string, bool)
 	IsClosed() bool
+	// MarkAuthorized is called after the client has been told it is authorised.
+	MarkAuthorized()
+	// CertDeviceID returns the device named by a valid client certificate
+	// presented on the connection, or "".
+	CertDeviceID() string
 }
 
 // WSMessage defines a generic interface for raw WebSocket messages.
@@ -33,12 +45,37 @@ type WSMessage interface {
 // AuthManagerInterface defines the contract for authorization management services.
 type AuthManagerInterface interface {
 	RequestAuthorization(deviceName, deviceID, ip string) bool
-	RespondToAuthRequest(deviceID string, approved bool)
+	// RespondToAuthRequest approves or rejects a pending request; an
+	// approval with a non-nil expiresAt is time-limited.
+	RespondToAuthRequest(deviceID string, approved bool, scopes []string, expiresAt *time.Time)
 	IsAuthorized(deviceID string) bool
+	HasScope(deviceID, scope string) bool
 	CheckPendingResult(deviceID string) (bool, bool)
+	// ListPending returns the requests waiting for a decision.
+	ListPending() []PendingAuthRequest
+	// ResolvePending approves or rejects a pending request on behalf of by,
+	// an admin device or API token. It reports whether a request was pending.
+	ResolvePending(deviceID string, approved bool, scopes []string, expiresAt *time.Time, by string) bool
+	// IsAdmin reports whether a device may manage other devices.
+	IsAdmin(deviceID string) bool
 	RevokeAuth(deviceID string)
+	// CertificateDevice reports whether serial is the current client
+	// certificate of an authorized device.
+	CertificateDevice(deviceID, serial string) bool
+	// StepUpChallenge issues a step-up challenge for an authorized device
+	// and names the secret that answers it; VerifyStepUp checks the answer.
+	StepUpChallenge(deviceID string) (token, method string, err error)
+	VerifyStepUp(client WSClient, token, response string) bool
+	// SetStepUpKey stores the PIN-derived key a device steps up with.
+	SetStepUpKey(deviceID, key string) error
+	// SweepExpired revokes the devices whose authorization has expired and
+	// returns their IDs.
+	SweepExpired() []string
+	// AuthenticateToken checks a REST API token and returns its name and scopes.
+	AuthenticateToken(token string) (name string, scopes []string, ok bool)
 
 	HandleAuthRequest(client WSClient, msg WSMessage)
 	HandleAuthCheck(client WSClient)
 	HandleChallengeResponse(client WSClient, msg WSMessage)
+	HandlePairRequest(client WSClient, msg WSMessage)
 }
@@ -6,6 +6,7 @@ import (
 	"os"
 	"strings"
 
+	"LinqoraHost/internal/certutils"
 	"LinqoraHost/internal/config"
 
 	"github.com/grandcat/zeroconf"
@@ -46,10 +47,12 @@ func NewMDNSServer(cfg *config.ServerConfig) *MDNSServer {
 // Start registers the mDNS service with the provided configuration.
 func (s *MDNSServer) Start() error {
 
-	// Prepare TXT records with service information
-	txtRecords := []string{
-		fmt.Sprintf("hostname=%s", s.hostname),
-		fmt.Sprintf("tls=%v", s.config.EnableTLS),
+	fp := ""
+	if s.config.EnableTLS {
+		var err error
+		if fp, err = certutils.Fingerprint(s.config.CertFile); err != nil {
+			slog.Warn("Certificate fingerprint not published over mDNS", "err", err)
+		}
 	}
 
 	// Create mDNS
@@ -58,7 +61,7 @@ func (s *MDNSServer) Start() error {
 		s.mdnsType,
 		s.mdnsDomain,
 		s.config.Port,
-		txtRecords,
+		s.txtRecords(fp),
 		nil,
 	)
 	if err != nil {
@@ -70,6 +73,27 @@ func (s *MDNSServer) Start() error {
 	return nil
 }
 
+// txtRecords returns the TXT records announcing the service. The app pins
+// the certificate fingerprint it finds in fp.
+func (s *MDNSServer) txtRecords(fp string) []string {
+	records := []string{
+		fmt.Sprintf("hostname=%s", s.hostname),
+		fmt.Sprintf("tls=%v", s.config.EnableTLS),
+	}
+	if fp != "" {
+		records = append(records, "fp="+fp)
+	}
+	return records
+}
+
+// SetFingerprint announces fp as the certificate fingerprint, after the
+// server reloaded its certificate.
+func (s *MDNSServer) SetFingerprint(fp string) {
+	if s.server != nil {
+		s.server.SetText(s.txtRecords(fp))
+	}
+}
+
 func (s *MDNSServer) Stop() {
 	if s.server != nil {
 		slog.Info("Shutting down mDNS server")
@@ -5,18 +5,35 @@ import (
 	"log/slog"
 	"sync"
 	"time"
+
+	"LinqoraHost/internal/events"
 )
 
+// LockState reports a change of the device lock state.
+type LockState struct {
+	Locked    bool  `json:"locked"`
+	Timestamp int64 `json:"timestamp"`
+}
+
+// LockStateTopic carries every lock and unlock of the device.
+var LockStateTopic = events.NewTopic[LockState]("lock_state")
+
 var (
 	deviceLocked     bool
 	deviceLockedTime time.Time
+	lockBus          *events.Bus
 	lockMutex        sync.RWMutex
 )
 
 // StartLockStateMonitor watches the OS lock state and synchronises the internal
 // flag. The goroutine exits when ctx is cancelled, so it stops cleanly on
 // server shutdown (previously it leaked forever with time.Sleep).
-func StartLockStateMonitor(ctx context.Context) {
+// From then on every lock state change is published to bus.
+func StartLockStateMonitor(ctx context.Context, bus *events.Bus) {
+	lockMutex.Lock()
+	lockBus = bus
+	lockMutex.Unlock()
+
 	go func() {
 		ticker := time.NewTicker(5 * time.Second)
 		defer ticker.Stop()
@@ -49,13 +66,21 @@ func IsDeviceLocked() bool {
 	return deviceLocked
 }
 
-// SetDeviceLocked updates the internal lock state.
+// SetDeviceLocked updates the internal lock state and publishes the change.
 func SetDeviceLocked(locked bool) {
+	now := time.Now()
+
 	lockMutex.Lock()
-	defer lockMutex.Unlock()
+	changed := deviceLocked != locked
 	deviceLocked = locked
 	if locked {
-		deviceLockedTime = time.Now()
+		deviceLockedTime = now
+	}
+	bus := lockBus
+	lockMutex.Unlock()
+
+	if changed {
+		events.Publish(bus, LockStateTopic, LockState{Locked: locked, Timestamp: now.Unix()})
 	}
 }
 
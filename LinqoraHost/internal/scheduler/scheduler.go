@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -16,6 +17,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"LinqoraHost/internal/events"
 )
 
 const maxRuntime = 5 * time.Minute // Increased for more complex tasks
@@ -49,12 +52,47 @@ type OutputChunk struct {
 	Text   string `json:"text"`
 }
 
+// Triggers recorded in ScriptResult.
+const (
+	TriggerSchedule = "schedule"
+	TriggerClient   = "client"
+	TriggerREST     = "rest"
+)
+
+// ScriptResult is a RunResult together with what started the run.
+type ScriptResult struct {
+	RunResult
+	Trigger string `json:"triggered"`
+}
+
+var (
+	// ScriptOutputTopic carries output lines of scheduled runs. Interactive
+	// runs stream their output to the requesting client only.
+	ScriptOutputTopic = events.NewTopic[OutputChunk]("script_output")
+	// ScriptResultTopic carries the outcome of every completed script run.
+	ScriptResultTopic = events.NewTopic[ScriptResult]("script_result")
+)
+
+// ErrDenied wraps the reason a Guard refused to run a script.
+var ErrDenied = errors.New("not allowed")
+
+// Launch is how a script is started once a Guard lets it run.
+type Launch struct {
+	Dir string   // working directory; empty keeps the script's own
+	Env []string // environment; nil inherits the host's
+}
+
+// Guard decides whether script may run for deviceID, which is empty for
+// scheduled and REST runs. A nil error lets it run as described by Launch.
+type Guard func(script Script, deviceID string) (Launch, error)
+
 // Manager holds the list of server-registered scripts and manages their execution.
 type Manager struct {
 	path    string
 	scripts []Script
 	mu      sync.RWMutex
 	running map[string]context.CancelFunc
+	guard   Guard
 }
 
 // NewManager loads scripts from [path].
@@ -165,6 +203,13 @@ func (m *Manager) Delete(id string) error {
 
 // Execution
 
+// SetGuard makes every later run ask guard first.
+func (m *Manager) SetGuard(guard Guard) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.guard = guard
+}
+
 func (m *Manager) Stop(id string) {
 	m.mu.Lock()
 	if cancel, ok := m.running[id]; ok {
@@ -176,20 +221,36 @@ func (m *Manager) Stop(id string) {
 
 // Execute runs the script and streams output via the onOutput callback.
 func (m *Manager) Execute(id string, onOutput func(OutputChunk)) (RunResult, error) {
+	return m.ExecuteFor(id, "", onOutput)
+}
+
+// ExecuteFor is Execute on behalf of deviceID, whose policy the guard applies.
+// A refused run returns an error wrapping ErrDenied.
+func (m *Manager) ExecuteFor(id, deviceID string, onOutput func(OutputChunk)) (RunResult, error) {
 	m.mu.RLock()
 	var script *Script
 	for i := range m.scripts {
 		if m.scripts[i].ID == id {
-			script = &m.scripts[i]
+			copied := m.scripts[i]
+			script = &copied
 			break
 		}
 	}
+	guard := m.guard
 	m.mu.RUnlock()
 
 	if script == nil {
 		return RunResult{}, fmt.Errorf("script %q not found", id)
 	}
 
+	var launch Launch
+	if guard != nil {
+		var err error
+		if launch, err = guard(*script, deviceID); err != nil {
+			return RunResult{}, fmt.Errorf("%w: %v", ErrDenied, err)
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), maxRuntime)
 	defer cancel()
 
@@ -212,6 +273,10 @@ func (m *Manager) Execute(id string, onOutput func(OutputChunk)) (RunResult, err
 	if script.WorkDir != "" {
 		cmd.Dir = script.WorkDir
 	}
+	if launch.Dir != "" {
+		cmd.Dir = launch.Dir
+	}
+	cmd.Env = launch.Env
 
 	stdoutPipe, _ := cmd.StdoutPipe()
 	stderrPipe, _ := cmd.StderrPipe()
@@ -314,11 +379,11 @@ func DefaultScriptsPath() string {
 type triggerKind int
 
 const (
-	triggerNone    triggerKind = iota
-	triggerDaily               // @daily / @midnight — 00:00 each day
-	triggerHourly              // @hourly — :00 of each hour
-	triggerEvery               // @every <duration> — interval from epoch
-	triggerAtTime              // HH:MM — fixed time each day
+	triggerNone   triggerKind = iota
+	triggerDaily              // @daily / @midnight — 00:00 each day
+	triggerHourly             // @hourly — :00 of each hour
+	triggerEvery              // @every <duration> — interval from epoch
+	triggerAtTime             // HH:MM — fixed time each day
 )
 
 type parsedSchedule struct {
@@ -383,8 +448,21 @@ func shouldFire(ps parsedSchedule, now time.Time) bool {
 }
 
 // StartCronLoop starts a background goroutine aligned to whole-minute ticks.
-// onTrigger is called (in a new goroutine per script) whenever a schedule fires.
-func (m *Manager) StartCronLoop(ctx context.Context, onTrigger func(scriptID string)) {
+// Scripts whose schedule fires are run in their own goroutine; their output
+// and results are published to bus.
+func (m *Manager) StartCronLoop(ctx context.Context, bus *events.Bus) {
+	onTrigger := func(scriptID string) {
+		slog.Info("Cron trigger", "script", scriptID)
+		result, err := m.Execute(scriptID, func(chunk OutputChunk) {
+			events.Publish(bus, ScriptOutputTopic, chunk)
+		})
+		if err != nil {
+			slog.Error("Scheduled script failed", "script", scriptID, "err", err)
+			return
+		}
+		events.Publish(bus, ScriptResultTopic, ScriptResult{RunResult: result, Trigger: TriggerSchedule})
+	}
+
 	go func() {
 		for {
 			now := time.Now()
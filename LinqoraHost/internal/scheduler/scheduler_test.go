@@ -1,6 +1,7 @@
 package scheduler
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -60,6 +61,23 @@ func TestManagerExecuteSimple(t *testing.T) {
 	}
 }
 
+func TestManagerExecuteForAsksGuard(t *testing.T) {
+	m := NewManagerWithScripts([]Script{{ID: "noop", Name: "No-op", Command: "true"}})
+	var asked string
+	m.SetGuard(func(s Script, deviceID string) (Launch, error) {
+		asked = deviceID
+		return Launch{}, errors.New("not on the allowlist")
+	})
+
+	_, err := m.ExecuteFor("noop", "phone", nil)
+	if !errors.Is(err, ErrDenied) {
+		t.Fatalf("expected ErrDenied, got %v", err)
+	}
+	if asked != "phone" {
+		t.Errorf("expected the guard to see device %q, got %q", "phone", asked)
+	}
+}
+
 func TestNewManagerLoadsFromFile(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "scripts.json")
@@ -0,0 +1,164 @@
+package ws
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"LinqoraHost/internal/audit"
+)
+
+// SetAuditLog makes the server record every dispatched message and REST
+// call in l. A nil log turns auditing off.
+func (s *WSServer) SetAuditLog(l *audit.Log) {
+	s.auditLog.Store(l)
+}
+
+// replyResult remembers the first error replied to a message, which is the
+// outcome the audit log records.
+type replyResult struct {
+	mu      sync.Mutex
+	code    int
+	message string
+	failed  bool
+}
+
+// noteError records an error reply to msg, keeping the first.
+func (msg *ClientMessage) noteError(message string, code []int) {
+	if msg == nil || msg.result == nil {
+		return
+	}
+	msg.result.mu.Lock()
+	defer msg.result.mu.Unlock()
+	if msg.result.failed {
+		return
+	}
+	msg.result.failed, msg.result.message = true, message
+	if len(code) > 0 {
+		msg.result.code = code[0]
+	}
+}
+
+// auditRecord times one message until its handler is done.
+type auditRecord struct {
+	log    *audit.Log
+	client *Client
+	msg    *ClientMessage
+	start  time.Time
+	off    bool
+}
+
+// beginAudit starts recording msg, or returns nil when it is not audited.
+func (s *WSServer) beginAudit(client *Client, msg *ClientMessage, handler *Handler) *auditRecord {
+	l := s.auditLog.Load()
+	if l == nil || handler.NoAudit {
+		return nil
+	}
+	msg.result = &replyResult{}
+	return &auditRecord{log: l, client: client, msg: msg, start: time.Now()}
+}
+
+// detach hands the record over to a goroutine that finishes the message; the
+// dispatcher's own end then does nothing.
+func (r *auditRecord) detach() *auditRecord {
+	if r == nil {
+		return nil
+	}
+	handed := *r
+	r.off = true
+	return &handed
+}
+
+// end writes the entry.
+func (r *auditRecord) end() {
+	if r == nil || r.off {
+		return
+	}
+	deviceID := r.client.GetDeviceID()
+	if deviceID == "" {
+		deviceID = messageDeviceID(r.msg)
+	}
+	entry := audit.Entry{
+		Time:       r.start,
+		DeviceID:   deviceID,
+		DeviceName: r.client.GetDeviceName(),
+		IP:         r.client.GetIP(),
+		Type:       r.msg.Type,
+		Params:     audit.Redact(r.msg.Data),
+		Outcome:    audit.OutcomeOK,
+		DurationMs: time.Since(r.start).Milliseconds(),
+	}
+	r.msg.result.mu.Lock()
+	if r.msg.result.failed {
+		entry.Outcome, entry.Code, entry.Error = audit.OutcomeError, r.msg.result.code, r.msg.result.message
+	}
+	r.msg.result.mu.Unlock()
+	appendAudit(r.log, entry)
+}
+
+func appendAudit(l *audit.Log, entry audit.Entry) {
+	// A stopped server's log is closed; late handlers have nothing to add to it.
+	if err := l.Append(entry); err != nil && !errors.Is(err, os.ErrClosed) {
+		slog.Error("Failed to write audit log", "type", entry.Type, "err", err)
+	}
+}
+
+// statusRecorder remembers the status a REST handler answered with.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Flush keeps event streams working through the recorder.
+func (w *statusRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// auditREST starts recording a REST call by caller, nil when it failed to
+// authenticate. The handler writes to the returned writer and calls done
+// when it returns.
+func (s *WSServer) auditREST(w http.ResponseWriter, r *http.Request, caller *restCaller) (http.ResponseWriter, func()) {
+	l := s.auditLog.Load()
+	if l == nil {
+		return w, func() {}
+	}
+
+	start := time.Now()
+	body, _ := io.ReadAll(io.LimitReader(r.Body, maxMessageSize))
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	rec := &statusRecorder{ResponseWriter: w}
+
+	return rec, func() {
+		name := "unauthorized"
+		if caller != nil {
+			name = caller.name()
+		}
+		entry := audit.Entry{
+			Time:       start,
+			DeviceName: name,
+			IP:         r.RemoteAddr,
+			Type:       r.Method + " " + r.URL.Path,
+			Params:     audit.Redact(body),
+			Outcome:    audit.OutcomeOK,
+			DurationMs: time.Since(start).Milliseconds(),
+		}
+		if rec.status >= 400 {
+			entry.Outcome, entry.Code, entry.Error = audit.OutcomeError, rec.status, http.StatusText(rec.status)
+		}
+		appendAudit(l, entry)
+	}
+}
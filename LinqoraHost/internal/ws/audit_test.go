@@ -0,0 +1,77 @@
+package ws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"LinqoraHost/internal/audit"
+	"LinqoraHost/internal/config"
+)
+
+func TestDispatchedMessagesAreAudited(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := audit.Open(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewWSServer(config.DefaultConfig(), &MockAuthManager{})
+	server.SetAuditLog(l)
+	done := make(chan struct{})
+	server.Registry().Register(Handler{Type: "typed", Handle: func(c *Client, m *ClientMessage) {
+		c.ReplyError(m, "nothing to type into", 409)
+	}})
+	server.Registry().Register(Handler{Type: "slow", Async: true, Handle: func(c *Client, m *ClientMessage) {
+		c.ReplySuccess(m, "slow", nil)
+		close(done)
+	}})
+	client, _ := connectAuthorized(t, server, "phone")
+
+	server.handleClientMessage(client, &ClientMessage{Type: "ping"})
+	server.handleClientMessage(client, &ClientMessage{Type: "typed", Data: []byte(`{"text":"hunter2"}`)})
+	server.handleClientMessage(client, &ClientMessage{Type: "slow"})
+	<-done
+	l.Close()
+
+	entries, err := audit.Read(path, audit.Filter{Device: "phone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, e := range entries {
+		types = append(types, e.Type)
+	}
+	if got := strings.Join(types, ","); !strings.HasSuffix(got, "typed,slow") || strings.Contains(got, "ping") {
+		t.Fatalf("Unexpected audited types %q", got)
+	}
+	typed := entries[len(entries)-2]
+	if typed.Outcome != audit.OutcomeError || typed.Code != 409 || strings.Contains(string(typed.Params), "hunter2") {
+		t.Errorf("Expected a redacted failed entry, got %+v", typed)
+	}
+	if entries[len(entries)-1].Outcome != audit.OutcomeOK {
+		t.Errorf("Expected the async message to be recorded once it finished, got %+v", entries[len(entries)-1])
+	}
+	if _, err := audit.Verify(path); err != nil {
+		t.Errorf("Expected an intact chain, got %v", err)
+	}
+}
+
+func TestRESTCallsAreAudited(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, _ := audit.Open(path, 0, 0)
+	cfg := config.DefaultConfig()
+	cfg.SharedSecret = "s3cret"
+	server := NewWSServer(cfg, &MockAuthManager{})
+	server.SetAuditLog(l)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/info", nil)
+	server.restHandler(RESTRoute{Path: "/api/v1/info", Handle: server.restInfo})(httptest.NewRecorder(), req)
+	l.Close()
+
+	entries, _ := audit.Read(path, audit.Filter{})
+	if len(entries) != 1 || entries[0].Type != "GET /api/v1/info" || entries[0].Code != http.StatusUnauthorized {
+		t.Errorf("Expected the refused call to be recorded, got %+v", entries)
+	}
+}
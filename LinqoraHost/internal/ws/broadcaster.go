@@ -2,7 +2,9 @@ package ws
 
 import (
 	"encoding/json"
-	"log/slog"
+
+	"LinqoraHost/internal/collectors"
+	"LinqoraHost/internal/events"
 )
 
 // BroadcastMessage defines a common interface for messages distributed across rooms.
@@ -43,55 +45,36 @@ func (m MediaMessage) ToJSON() ([]byte, error) {
 	return json.Marshal(m)
 }
 
-// Broadcaster provides high-level methods to distribute messages into specific rooms.
+// Broadcaster forwards room-scoped events from the bus to the rooms that
+// stream them. Collectors only publish while a room has members, because
+// the CollectorManager starts and stops them on room activity.
 type Broadcaster struct {
 	roomManager *RoomManager
 }
 
-// NewBroadcaster creates a new Broadcaster instance associated with a RoomManager.
-func NewBroadcaster(roomManager *RoomManager) *Broadcaster {
-	return &Broadcaster{
+// NewBroadcaster creates a Broadcaster that subscribes to the collector
+// topics on bus.
+func NewBroadcaster(roomManager *RoomManager, bus *events.Bus) *Broadcaster {
+	b := &Broadcaster{
 		roomManager: roomManager,
 	}
+	events.Subscribe(bus, collectors.MetricsTopic, b.BroadcastMetrics)
+	events.Subscribe(bus, collectors.MediaTopic, b.BroadcastMedia)
+	events.Subscribe(bus, collectors.ClipboardTopic, b.BroadcastClipboard)
+	return b
 }
 
 // BroadcastMetrics sends system metrics to all clients subscribed to the "metrics" room.
-func (b *Broadcaster) BroadcastMetrics(metricsData []byte) {
-	var payload interface{}
-	if err := json.Unmarshal(metricsData, &payload); err != nil {
-		slog.Error("Error unmarshaling metrics data", "err", err)
-		return
-	}
-
-	b.roomManager.SendToRoom("metrics", "metrics", payload, nil)
+func (b *Broadcaster) BroadcastMetrics(sample collectors.SystemMetrics) {
+	b.roomManager.SendToRoom("metrics", "metrics", sample, nil)
 }
 
 // BroadcastMedia sends multimedia state to all clients subscribed to the "media" room.
-func (b *Broadcaster) BroadcastMedia(mediaData []byte) {
-	var payload interface{}
-	if err := json.Unmarshal(mediaData, &payload); err != nil {
-		slog.Error("Error unmarshaling media data", "err", err)
-		return
-	}
-	b.roomManager.SendToRoom("media", "media", payload, nil)
-}
-
-// GetMetricsBroadcaster returns a callback function for broadcasting metrics.
-func (b *Broadcaster) GetMetricsBroadcaster() func([]byte) {
-	return b.BroadcastMetrics
-}
-
-// GetMediaBroadcaster returns a callback function for broadcasting media data.
-func (b *Broadcaster) GetMediaBroadcaster() func([]byte) {
-	return b.BroadcastMedia
+func (b *Broadcaster) BroadcastMedia(state collectors.MediaResponse) {
+	b.roomManager.SendToRoom("media", "media", state, nil)
 }
 
 // BroadcastClipboard sends the host clipboard text to all clients in the "clipboard" room.
-func (b *Broadcaster) BroadcastClipboard(text []byte) {
-	b.roomManager.SendToRoom("clipboard", "clipboard_update", string(text), nil)
-}
-
-// GetClipboardBroadcaster returns a callback function for broadcasting clipboard updates.
-func (b *Broadcaster) GetClipboardBroadcaster() func([]byte) {
-	return b.BroadcastClipboard
+func (b *Broadcaster) BroadcastClipboard(text string) {
+	b.roomManager.SendToRoom("clipboard", "clipboard_update", text, nil)
 }
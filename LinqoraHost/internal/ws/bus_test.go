@@ -0,0 +1,45 @@
+package ws
+
+import (
+	"testing"
+
+	"LinqoraHost/internal/collectors"
+	"LinqoraHost/internal/config"
+	"LinqoraHost/internal/events"
+	"LinqoraHost/internal/power"
+)
+
+func TestServerWideEventsReachAllClients(t *testing.T) {
+	server := NewWSServer(config.DefaultConfig(), &MockAuthManager{})
+	client := NewClient(nil, "127.0.0.1")
+	server.clients[client] = true
+
+	events.Publish(server.Events(), collectors.BatteryAlertTopic, collectors.BatteryAlert{Percent: 9, Threshold: 20})
+	events.Publish(server.Events(), power.LockStateTopic, power.LockState{Locked: true})
+
+	for _, want := range []string{"battery_alert", "lock_state"} {
+		if resp := readResponse(t, client); resp.Type != want {
+			t.Errorf("Expected %s, got %s", want, resp.Type)
+		}
+	}
+}
+
+func TestBroadcasterForwardsCollectorTopicsToRooms(t *testing.T) {
+	bus := events.NewBus()
+	rooms := NewRoomManager()
+	NewBroadcaster(rooms, bus)
+
+	member := NewClient(nil, "127.0.0.1")
+	outsider := NewClient(nil, "127.0.0.1")
+	rooms.AddClientToRoom("clipboard", member)
+
+	events.Publish(bus, collectors.ClipboardTopic, "copied")
+
+	resp := readResponse(t, member)
+	if resp.Type != "clipboard_update" || resp.Data != "copied" {
+		t.Errorf("Expected clipboard_update with text, got %+v", resp)
+	}
+	if _, ok, _ := outsider.queue.pop(); ok {
+		t.Error("Clients outside the room must not receive room events")
+	}
+}
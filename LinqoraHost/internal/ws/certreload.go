@@ -0,0 +1,64 @@
+package ws
+
+import (
+	"crypto/tls"
+
+	"LinqoraHost/internal/certutils"
+	"LinqoraHost/internal/events"
+)
+
+// CertificateTopic carries the certificate served after each reload, so that
+// whatever advertises its fingerprint (mDNS, the QR code) can follow.
+var CertificateTopic = events.NewTopic[certutils.CertStatus]("certificate_reloaded")
+
+// tlsConfig returns the TLS settings of the HTTP server: the certificate
+// comes from reloader, client certificates from the CA if one is set.
+func (s *WSServer) tlsConfig(reloader *certutils.CertReloader) *tls.Config {
+	conf := s.clientCertTLSConfig()
+	if conf == nil {
+		conf = &tls.Config{}
+	}
+	conf.GetCertificate = reloader.GetCertificate
+	return conf
+}
+
+// startCertReloader loads the configured certificate and watches its files
+// until the server stops.
+func (s *WSServer) startCertReloader() (*certutils.CertReloader, error) {
+	cfg := s.Config()
+	reloader, err := certutils.NewCertReloader(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	s.certs.Store(reloader)
+	go reloader.Watch(s.ctx, certutils.ReloadPollInterval, func(status certutils.CertStatus) {
+		events.Publish(s.bus, CertificateTopic, status)
+	})
+	return reloader, nil
+}
+
+// ReloadCertificate reads the certificate and key files again without
+// waiting for the watcher. New handshakes use the new pair; connected
+// clients are not interrupted.
+func (s *WSServer) ReloadCertificate() (certutils.CertStatus, error) {
+	reloader := s.certs.Load()
+	if reloader == nil {
+		return certutils.CertStatus{}, certutils.ErrNoCertificate
+	}
+	status, err := reloader.Reload()
+	if err != nil {
+		return certutils.CertStatus{}, err
+	}
+	events.Publish(s.bus, CertificateTopic, status)
+	return status, nil
+}
+
+// ServedFingerprint returns the fingerprint of the certificate the server is
+// serving, which may differ from the file on disk while a replacement is
+// refused. Before Start it falls back to the configured file.
+func (s *WSServer) ServedFingerprint() string {
+	if reloader := s.certs.Load(); reloader != nil {
+		return reloader.Status().Fingerprint
+	}
+	return CertFingerprint(s.Config())
+}
@@ -0,0 +1,107 @@
+package ws
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"LinqoraHost/internal/certutils"
+	"LinqoraHost/internal/config"
+	"LinqoraHost/internal/events"
+)
+
+// servedFingerprint completes a handshake with ln and returns the
+// fingerprint of the certificate it presents.
+func servedFingerprint(t *testing.T, ln net.Listener) string {
+	t.Helper()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	sum := sha256.Sum256(conn.ConnectionState().PeerCertificates[0].Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestReloadCertificateServesNewPair(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.DefaultConfig()
+	cfg.EnableTLS = true
+	cfg.CertFile, cfg.KeyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := certutils.GenerateHostCert(cfg.CertFile, cfg.KeyFile); err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewWSServer(cfg, &MockAuthManager{})
+	defer server.cancel()
+	reloader, err := server.startCertReloader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", server.tlsConfig(reloader))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	before, _ := certutils.Fingerprint(cfg.CertFile)
+	if got := servedFingerprint(t, ln); got != before {
+		t.Fatalf("served %q, want %q", got, before)
+	}
+
+	published := make(chan string, 1)
+	events.Subscribe(server.Events(), CertificateTopic, func(status certutils.CertStatus) {
+		published <- status.Fingerprint
+	})
+	if err := certutils.GenerateHostCert(cfg.CertFile, cfg.KeyFile); err != nil {
+		t.Fatal(err)
+	}
+	status, err := server.ReloadCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, _ := certutils.Fingerprint(cfg.CertFile)
+	if status.Fingerprint != after || after == before {
+		t.Fatalf("reload reported %q, want the new %q", status.Fingerprint, after)
+	}
+	if got := servedFingerprint(t, ln); got != after {
+		t.Errorf("served %q after reload, want %q", got, after)
+	}
+	if got := <-published; got != after {
+		t.Errorf("published %q, want %q", got, after)
+	}
+	if got := server.ServedFingerprint(); got != after {
+		t.Errorf("ServedFingerprint = %q, want %q", got, after)
+	}
+
+	// A broken key leaves the previous pair in service.
+	if err := os.WriteFile(cfg.KeyFile, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.ReloadCertificate(); err == nil {
+		t.Fatal("ReloadCertificate accepted a broken key")
+	}
+	if got := servedFingerprint(t, ln); got != after {
+		t.Errorf("served %q after a refused reload, want %q", got, after)
+	}
+}
+
+func TestReloadCertificateWithoutTLS(t *testing.T) {
+	server := NewWSServer(config.DefaultConfig(), &MockAuthManager{})
+	defer server.cancel()
+	if _, err := server.ReloadCertificate(); !errors.Is(err, certutils.ErrNoCertificate) {
+		t.Errorf("ReloadCertificate without TLS = %v, want ErrNoCertificate", err)
+	}
+}
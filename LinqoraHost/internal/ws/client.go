@@ -2,11 +2,16 @@ package ws
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"LinqoraHost/internal/interfaces"
+
 	"github.com/gorilla/websocket"
 )
 
@@ -21,31 +26,78 @@ const (
 	maxMessageSize = 4 * 1024 * 1024
 )
 
+// nextConnID numbers connections for session_list and session_kick.
+var nextConnID atomic.Uint64
+
+// errEnvelopeSequence reports a replayed, reordered or skipped encrypted frame.
+var errEnvelopeSequence = errors.New("unexpected envelope sequence number")
+
 // Client represents a connected WebSocket client.
 type Client struct {
+	// id identifies the connection, unlike DeviceID which a device keeps
+	// across connections.
+	id           string
 	Conn         *websocket.Conn
 	DeviceCode   string
 	DeviceName   string
 	IP           string
 	Rooms        map[string]bool
-	SendChannel  chan []byte
+	queue        *outboundQueue
 	mu           sync.Mutex
 	closed       bool
 	DeviceID     string
 	lastPingTime time.Time
 	limiter      *clientRateLimiter
+	connectedAt  time.Time
 	e2eeKey      []byte
+	// sessionKeyed is set once e2eeKey comes from a key exchange rather
+	// than the shared secret. Keyed connections number their envelopes.
+	sessionKeyed bool
+	// sealer encrypts outbound frames under the current key; nil without
+	// E2EE. recvSeq is the last envelope sequence number accepted under it.
+	sealer  *frameSealer
+	recvSeq uint64
+	// sendMu orders picking the sealer and queueing, so that a key change
+	// falls between two frames.
+	sendMu sync.Mutex
+	codec  Codec
+	// sessions, when set, keeps replies that arrive after the connection
+	// dropped so they can be replayed on resume.
+	sessions *SessionStore
+	// onAuthorized, when set, runs each time the auth layer grants access.
+	onAuthorized func(*Client)
+	// received and sent count messages from and to the device.
+	received atomic.Uint64
+	sent     atomic.Uint64
+	// certDeviceID is the device named by a valid client certificate, set
+	// once when the connection is accepted.
+	certDeviceID string
+	// stepUpUntil ends the grace period of the last step-up, and stepUpHeld
+	// is the message waiting for the answer to a step-up challenge. Both
+	// are guarded by mu.
+	stepUpUntil time.Time
+	stepUpHeld  *heldMessage
+	// claimedID is the device an auth_request asked for, and claimProven
+	// is set once the claim has been proven. Both are guarded by mu.
+	claimedID   string
+	claimProven bool
+	// streamCaller is the REST caller that opened an event stream; nil for
+	// WebSocket connections.
+	streamCaller *restCaller
 }
 
 // NewClient creates a new Client instance.
 func NewClient(conn *websocket.Conn, ip string) *Client {
 	return &Client{
+		id:           "c" + strconv.FormatUint(nextConnID.Add(1), 10),
 		Conn:         conn,
 		IP:           ip,
 		Rooms:        make(map[string]bool),
-		SendChannel:  make(chan []byte, 256),
+		queue:        newOutboundQueue(),
 		lastPingTime: time.Now(),
 		limiter:      newClientRateLimiter(),
+		connectedAt:  time.Now(),
+		codec:        JSONCodec,
 	}
 }
 
@@ -54,6 +106,12 @@ func (c *Client) GetIP() string {
 	return c.IP
 }
 
+// CertDeviceID returns the device named by a valid client certificate on
+// the connection, or "".
+func (c *Client) CertDeviceID() string {
+	return c.certDeviceID
+}
+
 // GetDeviceID returns the unique device identifier.
 func (c *Client) GetDeviceID() string {
 	return c.DeviceID
@@ -69,6 +127,40 @@ func (c *Client) SetDeviceID(id string) {
 	c.DeviceID = id
 }
 
+// ClaimDeviceID records the device the client asks to authenticate as.
+func (c *Client) ClaimDeviceID(id string, proven bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.claimedID, c.claimProven = id, proven
+}
+
+// ClaimedDeviceID returns the device last claimed and whether the claim was
+// proven.
+func (c *Client) ClaimedDeviceID() (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.claimedID, c.claimProven
+}
+
+// MarkAuthorized is called by the auth layer after it has told the client
+// that access was granted.
+func (c *Client) MarkAuthorized() {
+	if c.onAuthorized != nil {
+		c.onAuthorized(c)
+	}
+}
+
+// RoomNames returns the rooms the client has joined, in no particular order.
+func (c *Client) RoomNames() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := make([]string, 0, len(c.Rooms))
+	for name := range c.Rooms {
+		names = append(names, name)
+	}
+	return names
+}
+
 // IsClosed reports whether the client connection is closed.
 func (c *Client) IsClosed() bool {
 	c.mu.Lock()
@@ -88,6 +180,36 @@ func (c *Client) SetE2EEKey(key []byte) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.e2eeKey = key
+	c.sealer = nil
+	if key != nil {
+		c.sealer = &frameSealer{key: key}
+	}
+}
+
+// HasSessionKey reports whether the connection completed a key exchange.
+func (c *Client) HasSessionKey() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sessionKeyed
+}
+
+// SetCodec selects the wire encoding negotiated for this connection.
+func (c *Client) SetCodec(codec Codec) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.codec = codec
+}
+
+// Codec returns the wire encoding used for this connection.
+func (c *Client) Codec() Codec {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.codec
+}
+
+// GetID returns the client-supplied correlation ID, if any.
+func (m *ClientMessage) GetID() string {
+	return m.ID
 }
 
 // GetType returns the message type.
@@ -132,38 +254,38 @@ func (c *Client) StartReadPump(handleMessage func(*ClientMessage), onDisconnect
 			break
 		}
 
-		if messageType != websocket.TextMessage {
+		// Text frames are always JSON; binary frames use the negotiated codec.
+		codec := JSONCodec
+		switch messageType {
+		case websocket.TextMessage:
+		case websocket.BinaryMessage:
+			codec = c.Codec()
+			if codec.FrameType() != websocket.BinaryMessage {
+				continue
+			}
+		default:
 			continue
 		}
 
-		var clientMsg ClientMessage
-		if err := json.Unmarshal(message, &clientMsg); err != nil {
-			slog.Error("Error unmarshaling message", "err", err)
+		clientMsg, err := decodeClientMessage(codec, message)
+		if err != nil {
+			slog.Error("Error unmarshaling message", "codec", codec.Name(), "err", err)
 			continue
 		}
 
 		// Decrypt payload if E2EE is enabled and message type is "encrypted"
-		if clientMsg.Type == "encrypted" && c.e2eeKey != nil {
-			var cryptoMsg struct {
-				Payload string `json:"payload"`
-			}
-			if err := json.Unmarshal(clientMsg.Data, &cryptoMsg); err == nil {
-				decrypted, err := Decrypt(cryptoMsg.Payload, c.e2eeKey)
-				if err == nil {
-					// Replace original message with decrypted content
-					var innerMsg ClientMessage
-					if err := json.Unmarshal(decrypted, &innerMsg); err == nil {
-						clientMsg = innerMsg
-					}
+		if clientMsg.Type == "encrypted" && c.hasE2EEKey() {
+			innerMsg, err := c.openEnvelope(codec, clientMsg.Data)
+			if err != nil {
+				slog.Warn("Rejected encrypted message", "device", c.DeviceName, "ip", c.IP, "err", err)
+				if errors.Is(err, errEnvelopeSequence) {
+					c.ReplyError(&clientMsg, "Replayed or out-of-order message rejected", 409)
+				} else {
+					c.ReplyError(&clientMsg, "Failed to decrypt message", 400)
 				}
+				continue
 			}
-		}
-
-		// Rate limiting protection
-		if clientMsg.Type != "ping" && !c.limiter.Allow() {
-			slog.Warn("Rate limit exceeded for client", "device", c.DeviceName, "type", clientMsg.Type)
-			c.SendError(clientMsg.Type, "Rate limit exceeded, slow down", 429)
-			continue
+			clientMsg = innerMsg
 		}
 
 		// Handle message with panic protection
@@ -197,30 +319,26 @@ func (c *Client) StartWritePump() {
 		}
 
 		select {
-		case message, ok := <-c.SendChannel:
-			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if !ok {
-				slog.Info("SendChannel closed, exiting WritePump", "device", c.DeviceName)
-				c.Conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(
-					websocket.CloseNormalClosure, "channel closed"))
-				return
-			}
-
-			w, err := c.Conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				slog.Error("Error getting writer", "device", c.DeviceName, "err", err)
-				return
-			}
-
-			_, err = w.Write(message)
-			if err != nil {
-				slog.Error("Error writing message", "device", c.DeviceName, "err", err)
-				return
-			}
-
-			if err := w.Close(); err != nil {
-				slog.Error("Error closing writer", "device", c.DeviceName, "err", err)
-				return
+		case <-c.queue.ready:
+			for {
+				message, ok, closed, err := c.popFrame()
+				if err != nil {
+					slog.Error("Error sealing message", "device", c.DeviceName, "err", err)
+					return
+				}
+				if closed {
+					slog.Info("Outbound queue closed, exiting WritePump", "device", c.DeviceName)
+					c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+					c.Conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(
+						websocket.CloseNormalClosure, "channel closed"))
+					return
+				}
+				if !ok {
+					break
+				}
+				if err := c.writeFrame(message); err != nil {
+					return
+				}
 			}
 		case <-ticker.C:
 			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
@@ -232,38 +350,200 @@ func (c *Client) StartWritePump() {
 	}
 }
 
-// sendMessage queues a message for delivery.
-func (c *Client) sendMessage(message []byte) error {
+// writeFrame writes a single encoded message using the negotiated frame type.
+func (c *Client) writeFrame(message []byte) error {
+	c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+
+	w, err := c.Conn.NextWriter(c.Codec().FrameType())
+	if err != nil {
+		slog.Error("Error getting writer", "device", c.DeviceName, "err", err)
+		return err
+	}
+
+	if _, err := w.Write(message); err != nil {
+		slog.Error("Error writing message", "device", c.DeviceName, "err", err)
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		slog.Error("Error closing writer", "device", c.DeviceName, "err", err)
+		return err
+	}
+	return nil
+}
+
+// encryptedPayload is the data of an "encrypted" envelope. Payload holds the
+// sealed inner message; JSON carries it as base64, binary codecs as raw bytes.
+// Seq numbers envelopes on keyed connections and is omitted otherwise.
+type encryptedPayload struct {
+	Payload []byte `json:"payload"`
+	Seq     uint64 `json:"seq,omitempty"`
+}
+
+// sendMessage queues an already-encoded reply of the given type for
+// delivery, sealing it in an encrypted envelope first when E2EE is active.
+// Replies are never coalesced or dropped if the client falls behind.
+func (c *Client) sendMessage(msgType string, message []byte) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	return c.enqueue(classReliable, msgType, message)
+}
+
+// sendEvent is sendMessage for broadcasts, which are classified by type: a
+// queued snapshot gives way to a newer one and best-effort output can be
+// dropped. A reply of the same type is never touched.
+func (c *Client) sendEvent(msgType string, message []byte) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	return c.enqueue(classify(msgType), msgType, message)
+}
+
+// SendEvent formats and queues a broadcast; see sendEvent.
+func (c *Client) SendEvent(eventType string, data interface{}) error {
+	encoded, err := c.Codec().Marshal(NewSuccessResponse(eventType, data))
+	if err != nil {
+		slog.Error("Error marshaling event", "type", eventType, "err", err)
+		return err
+	}
+	return c.sendEvent(eventType, encoded)
+}
+
+// hasE2EEKey reports whether application-layer encryption is active.
+func (c *Client) hasE2EEKey() bool {
 	c.mu.Lock()
-	e2eeKey := c.e2eeKey
+	defer c.mu.Unlock()
+	return c.e2eeKey != nil
+}
+
+// openEnvelope decrypts the data of an "encrypted" message. On keyed
+// connections the envelope must carry the next client sequence number, which
+// rejects replayed, reordered and dropped frames, and its direction must be
+// client to server.
+func (c *Client) openEnvelope(codec Codec, data []byte) (ClientMessage, error) {
+	var envelope encryptedPayload
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return ClientMessage{}, fmt.Errorf("invalid envelope: %w", err)
+	}
+
+	c.mu.Lock()
+	key := c.e2eeKey
+	sequenced := c.sessionKeyed
+	expected := c.recvSeq + 1
 	c.mu.Unlock()
 
-	if e2eeKey != nil {
-		encrypted, err := Encrypt(message, e2eeKey)
-		if err == nil {
-			// Wrap in an encrypted message structure
-			envelope := NewSuccessResponse("encrypted", map[string]string{
-				"payload": encrypted,
-			})
-			message, _ = json.Marshal(envelope)
+	var plain []byte
+	var err error
+	if sequenced {
+		if envelope.Seq != expected {
+			return ClientMessage{}, fmt.Errorf("%w: got %d, want %d", errEnvelopeSequence, envelope.Seq, expected)
 		}
+		plain, err = OpenSequenced(envelope.Payload, key, DirectionClientToServer, envelope.Seq)
+	} else {
+		plain, err = Open(envelope.Payload, key)
+	}
+	if err != nil {
+		return ClientMessage{}, err
+	}
+
+	if sequenced {
+		c.mu.Lock()
+		c.recvSeq = envelope.Seq
+		c.mu.Unlock()
+	}
+	return decodeClientMessage(codec, plain)
+}
+
+// replyAndRekey queues response under the current key and then switches the
+// connection to key, with no other frame in between. Sequence numbers start
+// again from 1 in both directions under the new key.
+func (c *Client) replyAndRekey(response ServerResponse, key []byte) error {
+	encoded, err := c.Codec().Marshal(response)
+	if err != nil {
+		return err
+	}
+
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	if err := c.enqueue(classReliable, response.Type, encoded); err != nil {
+		return err
 	}
 
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.e2eeKey = key
+	c.sessionKeyed = true
+	c.sealer = &frameSealer{key: key, sequenced: true}
+	c.recvSeq = 0
+	c.mu.Unlock()
+	return nil
+}
+
+// frameSealer wraps outbound messages in encrypted envelopes under one key.
+// Messages are sealed as they leave the queue rather than as they enter it,
+// so sequence numbers follow the order frames are written in, and a
+// snapshot replaced or a best-effort message dropped while queued never
+// leaves a gap. Only the write pump seals, so seq needs no lock.
+type frameSealer struct {
+	key []byte
+	// sequenced numbers the envelopes, on keyed connections.
+	sequenced bool
+	// seq is the last sequence number sent.
+	seq uint64
+}
 
-	if c.closed || c.SendChannel == nil {
+// seal encrypts message and encodes the envelope with codec.
+func (f *frameSealer) seal(codec Codec, msgType string, message []byte) ([]byte, error) {
+	var seq uint64
+	var sealed []byte
+	var err error
+	if f.sequenced {
+		seq = f.seq + 1
+		sealed, err = SealSequenced(message, f.key, DirectionServerToClient, seq)
+	} else {
+		sealed, err = Seal(message, f.key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to seal %s message: %w", msgType, err)
+	}
+	f.seq = seq
+	return codec.Marshal(NewSuccessResponse("encrypted", encryptedPayload{Payload: sealed, Seq: seq}))
+}
+
+// popFrame takes the oldest queued message and seals it if it was queued
+// under an E2EE key. ok and closed are as for outboundQueue.pop.
+func (c *Client) popFrame() (frame []byte, ok bool, closed bool, err error) {
+	item, ok, closed := c.queue.popItem()
+	if !ok || item.sealer == nil {
+		return item.payload, ok, closed, nil
+	}
+	frame, err = item.sealer.seal(c.Codec(), item.msgType, item.payload)
+	return frame, ok, closed, err
+}
+
+// enqueue pushes message onto the outbound queue as class, to be sealed
+// under the current key when E2EE is active. Must be called with c.sendMu
+// held.
+func (c *Client) enqueue(class messageClass, msgType string, message []byte) error {
+	c.mu.Lock()
+	sealer := c.sealer
+	c.mu.Unlock()
+
+	if c.IsClosed() {
 		return fmt.Errorf("attempting to send message to closed client: %s", c.DeviceName)
 	}
 
-	// Non-blocking send; if the channel is full the client is too slow.
-	select {
-	case c.SendChannel <- message:
-		return nil
-	default:
+	if !c.queue.push(class, msgType, message, sealer) {
+		// Reliable messages are never dropped, so a client that has let the
+		// queue reach its hard limit is no longer reading at all.
 		go c.Close()
-		return fmt.Errorf("send channel full for client: %s", c.DeviceName)
+		return fmt.Errorf("outbound queue overflow for client: %s", c.DeviceName)
 	}
+	c.sent.Add(1)
+	return nil
+}
+
+// QueueStats returns the backpressure counters of the client's outbound queue.
+func (c *Client) QueueStats() QueueStats {
+	return c.queue.stats()
 }
 
 // UpdateLastPingTime records the time of the most recent ping received.
@@ -273,6 +553,13 @@ func (c *Client) UpdateLastPingTime() {
 	c.lastPingTime = time.Now()
 }
 
+// LastPing returns the time of the most recent ping received.
+func (c *Client) LastPing() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastPingTime
+}
+
 // TimeSinceLastPing returns the duration since the last ping message.
 func (c *Client) TimeSinceLastPing() time.Duration {
 	c.mu.Lock()
@@ -280,26 +567,66 @@ func (c *Client) TimeSinceLastPing() time.Duration {
 	return time.Since(c.lastPingTime)
 }
 
-// SendError formats and sends an error response to the client.
-func (c *Client) SendError(requestType string, message string, errorCode ...int) error {
-	errorResponse := NewErrorResponse(requestType, message, errorCode...)
-	if jsonMsg, err := json.Marshal(errorResponse); err == nil {
-		return c.sendMessage(jsonMsg)
-	} else {
-		slog.Error("Error marshaling error message", "err", err)
+// sendResponse serialises a response and queues it for delivery. If the
+// connection is already gone the response is kept in the client's session
+// backlog instead, when it has one.
+func (c *Client) sendResponse(response ServerResponse) error {
+	if c.sessions != nil && c.IsClosed() && c.sessions.BufferFor(c, response) {
+		return nil
+	}
+
+	encoded, err := c.Codec().Marshal(response)
+	if err != nil {
+		slog.Error("Error marshaling response", "type", response.Type, "err", err)
 		return err
 	}
+	return c.sendMessage(response.Type, encoded)
+}
+
+// SendError formats and sends an error response to the client.
+func (c *Client) SendError(requestType string, message string, errorCode ...int) error {
+	return c.sendResponse(NewErrorResponse(requestType, message, errorCode...))
 }
 
 // SendSuccess formats and sends a success response to the client.
 func (c *Client) SendSuccess(responseType string, data interface{}) error {
-	successResponse := NewSuccessResponse(responseType, data)
-	if jsonMsg, err := json.Marshal(successResponse); err == nil {
-		return c.sendMessage(jsonMsg)
-	} else {
-		slog.Error("Error marshaling success message", "err", err)
-		return err
-	}
+	return c.sendResponse(NewSuccessResponse(responseType, data))
+}
+
+// ReplyError sends an error response for msg, echoing its type and correlation ID.
+func (c *Client) ReplyError(msg *ClientMessage, message string, errorCode ...int) error {
+	msg.noteError(message, errorCode)
+	return c.sendResponse(NewErrorResponse(msg.Type, message, errorCode...).WithID(msg.ID))
+}
+
+// ReplySuccess sends a success response that echoes the correlation ID of msg.
+func (c *Client) ReplySuccess(msg *ClientMessage, responseType string, data interface{}) error {
+	return c.sendResponse(NewSuccessResponse(responseType, data).WithID(msg.ID))
+}
+
+// ForRequest returns a view of the client whose SendSuccess and SendError
+// echo the correlation ID of msg. It lets packages that only know about
+// interfaces.WSClient (such as auth) reply to a specific request, including
+// from goroutines that outlive the original handler call.
+func (c *Client) ForRequest(msg *ClientMessage) interfaces.WSClient {
+	return &requestClient{Client: c, msg: msg}
+}
+
+// requestClient binds a Client to the correlation ID of a single request.
+type requestClient struct {
+	*Client
+	msg *ClientMessage
+}
+
+// SendError sends an error response carrying the request's correlation ID.
+func (r *requestClient) SendError(requestType string, message string, errorCode ...int) error {
+	r.msg.noteError(message, errorCode)
+	return r.sendResponse(NewErrorResponse(requestType, message, errorCode...).WithID(r.msg.ID))
+}
+
+// SendSuccess sends a success response carrying the request's correlation ID.
+func (r *requestClient) SendSuccess(responseType string, data interface{}) error {
+	return r.sendResponse(NewSuccessResponse(responseType, data).WithID(r.msg.ID))
 }
 
 // Close safely terminates the client connection and releases resources.
@@ -317,12 +644,14 @@ func (c *Client) Close() {
 		c.Conn.Close()
 	}
 
-	if c.SendChannel != nil {
-		close(c.SendChannel)
-		c.SendChannel = nil
-	}
+	c.queue.close()
 
-	slog.Info("Client closed gracefully", "device", c.DeviceName)
+	if stats := c.queue.stats(); stats.Coalesced > 0 || stats.Dropped > 0 {
+		slog.Info("Client closed gracefully", "device", c.DeviceName,
+			"coalesced", stats.Coalesced, "dropped", stats.Dropped)
+	} else {
+		slog.Info("Client closed gracefully", "device", c.DeviceName)
+	}
 }
 
 // Lock acquires the client's mutex.
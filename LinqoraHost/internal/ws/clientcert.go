@@ -0,0 +1,46 @@
+package ws
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+
+	"LinqoraHost/internal/ca"
+)
+
+// SetCA makes the server ask TLS clients for a certificate signed by
+// authority. Devices presenting a valid one skip the HMAC challenge. It must
+// be called before Start.
+func (s *WSServer) SetCA(authority *ca.Authority) {
+	s.ca = authority
+}
+
+// clientCertTLSConfig returns the TLS settings for client certificates, or
+// nil without a CA. Certificates are optional, so devices without one
+// authenticate as before.
+func (s *WSServer) clientCertTLSConfig() *tls.Config {
+	if s.ca == nil {
+		return nil
+	}
+	return &tls.Config{
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		ClientCAs:  s.ca.Pool(),
+	}
+}
+
+// certificateDevice returns the device named by the verified client
+// certificate of r, if the certificate is still that device's current one
+// and not on the deny list. Checking per connection makes a revocation
+// apply to the next connection at once.
+func (s *WSServer) certificateDevice(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return ""
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	deviceID, serial := cert.Subject.CommonName, ca.SerialString(cert.SerialNumber)
+	if !s.authManager.CertificateDevice(deviceID, serial) {
+		slog.Warn("Ignoring revoked or replaced client certificate", "device_id", deviceID, "serial", serial, "remote_addr", r.RemoteAddr)
+		return ""
+	}
+	return deviceID
+}
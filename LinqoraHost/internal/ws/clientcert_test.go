@@ -0,0 +1,103 @@
+package ws
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"LinqoraHost/internal/ca"
+	"LinqoraHost/internal/config"
+
+	"github.com/gorilla/websocket"
+)
+
+// certAuthManager accepts one certificate serial.
+type certAuthManager struct {
+	MockAuthManager
+	serial atomic.Value
+}
+
+func (m *certAuthManager) CertificateDevice(deviceID, serial string) bool {
+	return deviceID == "phone-1" && serial == m.serial.Load()
+}
+
+// clientAt returns the registered client connected from addr.
+func (s *WSServer) clientAt(addr string) *Client {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+	for client := range s.clients {
+		if client.GetIP() == addr {
+			return client
+		}
+	}
+	return nil
+}
+
+func TestClientCertificateIdentifiesConnection(t *testing.T) {
+	authority, err := ca.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, key)
+	issued, err := authority.Sign(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})), "phone-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	clientCert, err := tls.X509KeyPair([]byte(issued.CertPEM), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	am := &certAuthManager{}
+	am.serial.Store(issued.Serial)
+	server := NewWSServer(config.DefaultConfig(), am)
+	server.SetCA(authority)
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(server.handleWSConnection))
+	ts.TLS = server.clientCertTLSConfig()
+	ts.StartTLS()
+	defer ts.Close()
+
+	connect := func(certs []tls.Certificate) string {
+		t.Helper()
+		dialer := websocket.Dialer{TLSClientConfig: &tls.Config{
+			RootCAs:      ts.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs,
+			Certificates: certs,
+		}}
+		conn, _, err := dialer.Dial("wss"+strings.TrimPrefix(ts.URL, "https"), nil)
+		if err != nil {
+			t.Fatalf("Dial: %v", err)
+		}
+		defer conn.Close()
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			if client := server.clientAt(conn.LocalAddr().String()); client != nil {
+				return client.CertDeviceID()
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatal("Connection was not registered")
+		return ""
+	}
+
+	if id := connect([]tls.Certificate{clientCert}); id != "phone-1" {
+		t.Errorf("Expected the certificate to name phone-1, got %q", id)
+	}
+	if id := connect(nil); id != "" {
+		t.Errorf("Expected no identity without a certificate, got %q", id)
+	}
+	am.serial.Store("revoked")
+	if id := connect([]tls.Certificate{clientCert}); id != "" {
+		t.Errorf("Expected a denied certificate to be ignored, got %q", id)
+	}
+}
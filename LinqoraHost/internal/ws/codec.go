@@ -0,0 +1,164 @@
+package ws
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// WebSocket subprotocols a client may offer during the upgrade to choose the
+// wire encoding. A client that offers none of them gets JSON text frames.
+const (
+	SubprotocolJSON    = "linqora.json"
+	SubprotocolMsgPack = "linqora.msgpack"
+	SubprotocolCBOR    = "linqora.cbor"
+)
+
+// Codec serialises messages for one wire encoding.
+type Codec interface {
+	// Name identifies the encoding ("json", "msgpack", "cbor").
+	Name() string
+	// FrameType is the WebSocket frame type the encoding travels in.
+	FrameType() int
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	// JSONCodec is the default encoding, carried in text frames.
+	JSONCodec Codec = jsonCodec{}
+	// MsgPackCodec encodes messages as MessagePack in binary frames.
+	MsgPackCodec Codec = msgpackCodec{}
+	// CBORCodec encodes messages as CBOR in binary frames.
+	CBORCodec Codec = newCBORCodec()
+)
+
+// subprotocolCodecs maps each supported subprotocol to its codec, in server
+// preference order.
+var subprotocolCodecs = []struct {
+	name  string
+	codec Codec
+}{
+	{SubprotocolMsgPack, MsgPackCodec},
+	{SubprotocolCBOR, CBORCodec},
+	{SubprotocolJSON, JSONCodec},
+}
+
+// Subprotocols returns the subprotocol names the upgrader should advertise.
+func Subprotocols() []string {
+	names := make([]string, 0, len(subprotocolCodecs))
+	for _, entry := range subprotocolCodecs {
+		names = append(names, entry.name)
+	}
+	return names
+}
+
+// CodecForSubprotocol returns the codec negotiated for a subprotocol,
+// falling back to JSON when none was agreed.
+func CodecForSubprotocol(subprotocol string) Codec {
+	for _, entry := range subprotocolCodecs {
+		if entry.name == subprotocol {
+			return entry.codec
+		}
+	}
+	return JSONCodec
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string                               { return "json" }
+func (jsonCodec) FrameType() int                             { return websocket.TextMessage }
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// msgpackCodec reuses the json struct tags so existing payload types need no
+// extra annotations.
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string   { return "msgpack" }
+func (msgpackCodec) FrameType() int { return websocket.BinaryMessage }
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// cborCodec honours json struct tags natively and decodes maps with string
+// keys so they can be re-encoded as JSON.
+type cborCodec struct {
+	enc cbor.EncMode
+	dec cbor.DecMode
+}
+
+func newCBORCodec() cborCodec {
+	enc, err := cbor.EncOptions{}.EncMode()
+	if err != nil {
+		panic(fmt.Sprintf("ws: cbor encoder: %v", err))
+	}
+	dec, err := cbor.DecOptions{
+		DefaultMapType: reflect.TypeOf(map[string]interface{}(nil)),
+	}.DecMode()
+	if err != nil {
+		panic(fmt.Sprintf("ws: cbor decoder: %v", err))
+	}
+	return cborCodec{enc: enc, dec: dec}
+}
+
+func (cborCodec) Name() string                                 { return "cbor" }
+func (cborCodec) FrameType() int                               { return websocket.BinaryMessage }
+func (c cborCodec) Marshal(v interface{}) ([]byte, error)      { return c.enc.Marshal(v) }
+func (c cborCodec) Unmarshal(data []byte, v interface{}) error { return c.dec.Unmarshal(data, v) }
+
+// wireMessage is the codec-neutral shape of a ClientMessage whose data has
+// not been converted to JSON yet.
+type wireMessage struct {
+	ID   string      `json:"id,omitempty"`
+	Type string      `json:"type"`
+	Room string      `json:"room,omitempty"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// decodeClientMessage parses a frame with the given codec. Binary payloads
+// are transcoded so that handlers always receive Data as JSON; byte strings
+// become base64, which is how encoding/json represents []byte fields.
+func decodeClientMessage(codec Codec, raw []byte) (ClientMessage, error) {
+	var msg ClientMessage
+	if codec == JSONCodec {
+		err := json.Unmarshal(raw, &msg)
+		return msg, err
+	}
+
+	var wire wireMessage
+	if err := codec.Unmarshal(raw, &wire); err != nil {
+		return msg, err
+	}
+
+	msg.ID = wire.ID
+	msg.Type = wire.Type
+	msg.Room = wire.Room
+	if wire.Data != nil {
+		data, err := json.Marshal(wire.Data)
+		if err != nil {
+			return msg, fmt.Errorf("failed to transcode %s data: %w", codec.Name(), err)
+		}
+		msg.Data = data
+	}
+	return msg, nil
+}
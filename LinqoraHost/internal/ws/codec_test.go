@@ -0,0 +1,111 @@
+package ws
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestCodecForSubprotocol(t *testing.T) {
+	cases := map[string]Codec{
+		SubprotocolMsgPack: MsgPackCodec,
+		SubprotocolCBOR:    CBORCodec,
+		SubprotocolJSON:    JSONCodec,
+		"":                 JSONCodec,
+		"unknown":          JSONCodec,
+	}
+	for proto, want := range cases {
+		if got := CodecForSubprotocol(proto); got.Name() != want.Name() {
+			t.Errorf("CodecForSubprotocol(%q) = %s, want %s", proto, got.Name(), want.Name())
+		}
+	}
+	if MsgPackCodec.FrameType() != websocket.BinaryMessage || JSONCodec.FrameType() != websocket.TextMessage {
+		t.Error("Unexpected frame types")
+	}
+}
+
+func TestBinaryCodecsDecodeClientMessage(t *testing.T) {
+	for _, codec := range []Codec{MsgPackCodec, CBORCodec} {
+		raw, err := codec.Marshal(map[string]interface{}{
+			"id":   "7",
+			"type": "file_write",
+			"data": map[string]interface{}{"path": "/tmp/x", "content": []byte{0, 1, 2}},
+		})
+		if err != nil {
+			t.Fatalf("%s: marshal failed: %v", codec.Name(), err)
+		}
+
+		msg, err := decodeClientMessage(codec, raw)
+		if err != nil {
+			t.Fatalf("%s: decode failed: %v", codec.Name(), err)
+		}
+		if msg.ID != "7" || msg.Type != "file_write" {
+			t.Errorf("%s: unexpected envelope %+v", codec.Name(), msg)
+		}
+
+		// Handlers still parse Data as JSON, with byte strings as []byte.
+		var data struct {
+			Path    string `json:"path"`
+			Content []byte `json:"content"`
+		}
+		if err := json.Unmarshal(msg.Data, &data); err != nil {
+			t.Fatalf("%s: data is not JSON: %v", codec.Name(), err)
+		}
+		if data.Path != "/tmp/x" || !bytes.Equal(data.Content, []byte{0, 1, 2}) {
+			t.Errorf("%s: unexpected data %+v", codec.Name(), data)
+		}
+	}
+}
+
+func TestRoomSerialisesPerCodec(t *testing.T) {
+	room := NewRoom("metrics")
+	jsonClient := NewClient(nil, "127.0.0.1")
+	packClient := NewClient(nil, "127.0.0.2")
+	packClient.SetCodec(MsgPackCodec)
+	room.AddClient(jsonClient)
+	room.AddClient(packClient)
+
+	room.SendToAllClients("metrics", map[string]int{"cpu": 42}, nil)
+
+	var fromJSON, fromPack ServerResponse
+	if err := json.Unmarshal(nextFrame(t, jsonClient), &fromJSON); err != nil {
+		t.Fatalf("JSON client got undecodable frame: %v", err)
+	}
+	if err := MsgPackCodec.Unmarshal(nextFrame(t, packClient), &fromPack); err != nil {
+		t.Fatalf("MessagePack client got undecodable frame: %v", err)
+	}
+	if fromJSON.Type != "metrics" || fromPack.Type != "metrics" {
+		t.Errorf("Unexpected types %q / %q", fromJSON.Type, fromPack.Type)
+	}
+}
+
+func TestEncryptedEnvelopeWithBinaryCodec(t *testing.T) {
+	key := DeriveKey("secret")
+	client := NewClient(nil, "127.0.0.1")
+	client.SetCodec(CBORCodec)
+	client.SetE2EEKey(key)
+
+	client.SendSuccess("pong", map[string]int{"timestamp": 1})
+
+	var envelope struct {
+		Type string           `json:"type"`
+		Data encryptedPayload `json:"data"`
+	}
+	if err := CBORCodec.Unmarshal(nextFrame(t, client), &envelope); err != nil {
+		t.Fatalf("Envelope decode failed: %v", err)
+	}
+	if envelope.Type != "encrypted" {
+		t.Fatalf("Expected encrypted envelope, got %q", envelope.Type)
+	}
+
+	inner, err := Open(envelope.Data.Payload, key)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	var resp ServerResponse
+	if err := CBORCodec.Unmarshal(inner, &resp); err != nil || resp.Type != "pong" {
+		t.Errorf("Unexpected inner message %+v (%v)", resp, err)
+	}
+}
@@ -0,0 +1,99 @@
+package ws
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"LinqoraHost/internal/confirm"
+)
+
+// maxConfirmSummary bounds the request details shown in a confirmation prompt.
+const maxConfirmSummary = 160
+
+// Confirmations returns the broker through which guarded actions are put to
+// the host. The console or GUI reads its requests and answers them.
+func (s *WSServer) Confirmations() *confirm.Broker {
+	return s.confirmations
+}
+
+// needsConfirmation reports whether the operator requires action to be
+// allowed at the host.
+func (s *WSServer) needsConfirmation(action string) bool {
+	return action != "" && s.Config().RequiresConfirmation(action)
+}
+
+// confirmThenHandle tells the client that msg waits for the host, asks the
+// host, and runs the handler only if the action is allowed. It runs in its own
+// goroutine so that the connection keeps working meanwhile.
+func (s *WSServer) confirmThenHandle(client *Client, msg *ClientMessage, handler *Handler, record *auditRecord) {
+	defer record.end()
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("Panic recovered in confirmed handler", "type", msg.Type, "err", r)
+		}
+	}()
+
+	timeout := s.Config().ConfirmWait()
+	client.ReplySuccess(msg, "pending_confirmation", map[string]interface{}{
+		"requestType":    msg.Type,
+		"action":         handler.Confirm,
+		"timeoutSeconds": int(timeout.Seconds()),
+	})
+
+	outcome := s.confirmations.Ask(confirm.Request{
+		Action:     handler.Confirm,
+		Summary:    confirmSummary(msg.Type, msg.Data),
+		DeviceID:   client.GetDeviceID(),
+		DeviceName: client.GetDeviceName(),
+		IP:         client.GetIP(),
+	}, timeout)
+
+	switch outcome {
+	case confirm.Allowed:
+		handler.Handle(client, msg)
+	case confirm.Denied:
+		client.ReplyError(msg, "Denied at the host", 403)
+	default:
+		client.ReplyError(msg, "Not confirmed at the host in time", 408)
+	}
+}
+
+// restConfirm asks the host to allow a guarded REST call. It blocks until the
+// host answers and writes the refusal itself, returning false, when the call
+// may not proceed.
+func (s *WSServer) restConfirm(w http.ResponseWriter, r *http.Request, route RESTRoute, caller *restCaller) bool {
+	// Read the body for the prompt and put it back for the handler.
+	body, _ := io.ReadAll(io.LimitReader(r.Body, maxMessageSize))
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	outcome := s.confirmations.Ask(confirm.Request{
+		Action:     route.Confirm,
+		Summary:    confirmSummary(r.Method+" "+route.Path, body),
+		DeviceName: caller.name(),
+		IP:         r.RemoteAddr,
+	}, s.Config().ConfirmWait())
+
+	switch outcome {
+	case confirm.Allowed:
+		return true
+	case confirm.Denied:
+		restWriteJSON(w, http.StatusForbidden, map[string]string{"error": "denied at the host"})
+	default:
+		restWriteJSON(w, http.StatusRequestTimeout, map[string]string{"error": "not confirmed at the host in time"})
+	}
+	return false
+}
+
+// confirmSummary describes a request for the person asked to confirm it.
+func confirmSummary(what string, data []byte) string {
+	summary := what
+	if len(bytes.TrimSpace(data)) > 0 {
+		summary += " " + string(bytes.TrimSpace(data))
+	}
+	if len(summary) > maxConfirmSummary {
+		summary = truncateUTF8(summary, maxConfirmSummary) + "…"
+	}
+	return summary
+}
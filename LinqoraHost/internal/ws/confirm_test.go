@@ -0,0 +1,72 @@
+package ws
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"LinqoraHost/internal/config"
+)
+
+// awaitResponse waits for an asynchronous reply and pops it.
+func awaitResponse(t *testing.T, client *Client) ServerResponse {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for client.queue.stats().Pending == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return readResponse(t, client)
+}
+
+func TestGuardedActionWaitsForHost(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.ConfirmActions = []string{config.ConfirmPower, config.ConfirmShell}
+	server := NewWSServer(cfg, &MockAuthManager{})
+	server.Registry().Register(Handler{Type: "guarded", Confirm: config.ConfirmShell, Handle: func(c *Client, m *ClientMessage) {
+		c.ReplySuccess(m, "guarded", nil)
+	}})
+	client, _ := connectAuthorized(t, server, "phone")
+	broker := server.Confirmations()
+
+	server.handleClientMessage(client, &ClientMessage{ID: "p", Type: "power", Data: []byte(`{"action":0}`)})
+	req := <-broker.Requests()
+	if resp := readResponse(t, client); resp.Type != "pending_confirmation" || resp.ID != "p" {
+		t.Fatalf("Expected pending_confirmation first, got %+v", resp)
+	}
+	if req.Action != config.ConfirmPower || req.DeviceID != "phone" {
+		t.Errorf("Unexpected confirmation request %+v", req)
+	}
+	broker.Respond(req.ID, false)
+	if resp := awaitResponse(t, client); resp.Error == nil || *resp.Error.Code != 403 {
+		t.Fatalf("Expected a denied action to fail with 403, got %+v", resp)
+	}
+
+	server.handleClientMessage(client, &ClientMessage{ID: "g", Type: "guarded"})
+	req = <-broker.Requests()
+	readResponse(t, client)
+	if _, ok, _ := client.queue.pop(); ok {
+		t.Fatal("The handler ran before the host allowed it")
+	}
+	broker.Respond(req.ID, true)
+	if resp := awaitResponse(t, client); resp.Error != nil || resp.Type != "guarded" {
+		t.Fatalf("Expected the allowed action to run, got %+v", resp)
+	}
+
+	cfg.ConfirmActions = nil
+	server.handleClientMessage(client, &ClientMessage{Type: "guarded"})
+	if resp := readResponse(t, client); resp.Type != "guarded" {
+		t.Errorf("Expected an unguarded action to run at once, got %+v", resp)
+	}
+}
+
+func TestConfirmSummaryKeepsCharactersWhole(t *testing.T) {
+	text := strings.Repeat("é", maxConfirmSummary)
+	summary := confirmSummary("clipboard_set", []byte(`{"text":"`+text+`"}`))
+	if !utf8.ValidString(summary) {
+		t.Errorf("Expected valid UTF-8, got %q", summary)
+	}
+	if !strings.HasSuffix(summary, "…") || len(summary) > maxConfirmSummary+len("…") {
+		t.Errorf("Expected the summary to be cut, got %d bytes", len(summary))
+	}
+}
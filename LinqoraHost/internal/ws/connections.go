@@ -0,0 +1,296 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// defaultKickReason is sent in the close frame when the admin gives none.
+	defaultKickReason = "disconnected by administrator"
+	// maxCloseReason is the longest reason a close frame can carry.
+	maxCloseReason = 123
+	// maxBlock bounds how long a kicked device can be kept out.
+	maxBlock = 7 * 24 * time.Hour
+)
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// ConnectionInfo describes one open WebSocket connection.
+type ConnectionInfo struct {
+	ID               string    `json:"id"`
+	DeviceID         string    `json:"deviceId"`
+	DeviceName       string    `json:"deviceName"`
+	IP               string    `json:"ip"`
+	Authorized       bool      `json:"authorized"`
+	Rooms            []string  `json:"rooms"`
+	ConnectedAt      time.Time `json:"connectedAt"`
+	LastPing         time.Time `json:"lastPing"`
+	MessagesReceived uint64    `json:"messagesReceived"`
+	MessagesSent     uint64    `json:"messagesSent"`
+	// BlockedUntil is set while the device is kept out after a kick.
+	BlockedUntil *time.Time `json:"blockedUntil,omitempty"`
+}
+
+// KickRequest is the body of session_kick, over WebSocket and REST. It names
+// one connection by ID, or every connection of DeviceID. BlockSeconds keeps
+// the device from authenticating again for that long.
+type KickRequest struct {
+	ID           string `json:"id,omitempty"`
+	DeviceID     string `json:"deviceId,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+	BlockSeconds int    `json:"blockSeconds,omitempty"`
+}
+
+var (
+	errKickTarget  = errors.New("id or deviceId is required")
+	errKickUnknown = errors.New("no such connection")
+	errKickBlock   = errors.New("only an identified device can be blocked")
+)
+
+// deviceBlocks remembers devices kept out after a kick, until a deadline.
+type deviceBlocks struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+	now   func() time.Time
+}
+
+func newDeviceBlocks() *deviceBlocks {
+	return &deviceBlocks{until: make(map[string]time.Time), now: time.Now}
+}
+
+// block keeps deviceID out for d.
+func (b *deviceBlocks) block(deviceID string, d time.Duration) time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	until := b.now().Add(d)
+	b.until[deviceID] = until
+	return until
+}
+
+// blockedUntil reports whether deviceID is blocked, and until when.
+func (b *deviceBlocks) blockedUntil(deviceID string) (time.Time, bool) {
+	if deviceID == "" {
+		return time.Time{}, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	until, ok := b.until[deviceID]
+	if ok && !b.now().Before(until) {
+		delete(b.until, deviceID)
+		return time.Time{}, false
+	}
+	return until, ok
+}
+
+// Connections lists the open WebSocket connections, oldest first. REST event
+// streams are not included.
+func (s *WSServer) Connections() []ConnectionInfo {
+	s.clientsMutex.Lock()
+	snapshot := make([]*Client, 0, len(s.clients))
+	for client := range s.clients {
+		snapshot = append(snapshot, client)
+	}
+	s.clientsMutex.Unlock()
+
+	conns := make([]ConnectionInfo, 0, len(snapshot))
+	for _, client := range snapshot {
+		rooms := client.RoomNames()
+		sort.Strings(rooms)
+		deviceID := client.GetDeviceID()
+		info := ConnectionInfo{
+			ID:               client.id,
+			DeviceID:         deviceID,
+			DeviceName:       client.GetDeviceName(),
+			IP:               client.GetIP(),
+			Authorized:       deviceID != "" && s.authManager.IsAuthorized(deviceID),
+			Rooms:            rooms,
+			ConnectedAt:      client.connectedAt,
+			LastPing:         client.LastPing(),
+			MessagesReceived: client.received.Load(),
+			MessagesSent:     client.sent.Load(),
+		}
+		if until, blocked := s.blocks.blockedUntil(deviceID); blocked {
+			info.BlockedUntil = &until
+		}
+		conns = append(conns, info)
+	}
+	sort.Slice(conns, func(i, j int) bool {
+		return conns[i].ConnectedAt.Before(conns[j].ConnectedAt)
+	})
+	return conns
+}
+
+// DisconnectDevice closes every connection of a device and drops its
+// resumable sessions, so that it has to authenticate again. reason is sent in
+// the close frame. It returns the number of connections closed.
+func (s *WSServer) DisconnectDevice(deviceID, reason string) int {
+	if deviceID == "" {
+		return 0
+	}
+
+	s.clientsMutex.Lock()
+	var targets []*Client
+	for client := range s.clients {
+		if client.GetDeviceID() == deviceID {
+			targets = append(targets, client)
+		}
+	}
+	s.clientsMutex.Unlock()
+
+	for _, client := range targets {
+		s.dropClient(client, reason)
+	}
+	if len(targets) > 0 {
+		slog.Info("Device disconnected", "device_id", deviceID, "connections", len(targets), "reason", reason)
+	}
+	return len(targets)
+}
+
+// Kick closes the connections named by req and, when asked, blocks the
+// device for a while. It returns the number of connections closed.
+func (s *WSServer) Kick(req KickRequest) (int, error) {
+	if req.ID == "" && req.DeviceID == "" {
+		return 0, errKickTarget
+	}
+	if req.BlockSeconds < 0 || time.Duration(req.BlockSeconds)*time.Second > maxBlock {
+		return 0, fmt.Errorf("blockSeconds must be between 0 and %d", int(maxBlock.Seconds()))
+	}
+	reason := req.Reason
+	if reason == "" {
+		reason = defaultKickReason
+	}
+	reason = truncateUTF8(reason, maxCloseReason)
+
+	deviceID := req.DeviceID
+	var target *Client
+	if req.ID != "" {
+		s.clientsMutex.Lock()
+		for client := range s.clients {
+			if client.id == req.ID {
+				target = client
+				break
+			}
+		}
+		s.clientsMutex.Unlock()
+		if target == nil {
+			return 0, errKickUnknown
+		}
+		deviceID = target.GetDeviceID()
+	}
+
+	if req.BlockSeconds > 0 {
+		if deviceID == "" {
+			return 0, errKickBlock
+		}
+		until := s.blocks.block(deviceID, time.Duration(req.BlockSeconds)*time.Second)
+		slog.Info("Device blocked", "device_id", deviceID, "until", until.Format(time.RFC3339))
+		// A blocked device loses every connection, not only the one named.
+		target = nil
+	}
+
+	if target != nil {
+		s.dropClient(target, reason)
+		slog.Info("Connection kicked", "id", target.id, "device", target.GetDeviceName(), "reason", reason)
+		return 1, nil
+	}
+	n := s.DisconnectDevice(deviceID, reason)
+	if n == 0 && req.BlockSeconds == 0 {
+		return 0, errKickUnknown
+	}
+	return n, nil
+}
+
+// dropClient closes one connection with reason and forgets its session.
+func (s *WSServer) dropClient(client *Client, reason string) {
+	closeConn(client, websocket.ClosePolicyViolation, reason)
+	s.disconnectClient(client)
+	s.sessions.Discard(client)
+}
+
+// refuseBlocked answers a message from a blocked device.
+func refuseBlocked(client *Client, msg *ClientMessage, until time.Time) {
+	client.ReplyError(msg, fmt.Sprintf("Device is blocked until %s", until.Format(time.RFC3339)), 403)
+}
+
+// messageDeviceID returns the deviceId carried by an authentication message.
+func messageDeviceID(msg *ClientMessage) string {
+	var data struct {
+		DeviceID string `json:"deviceId"`
+	}
+	json.Unmarshal(msg.Data, &data)
+	return data.DeviceID
+}
+
+// handleSessionList returns the open connections to an admin device.
+func (s *WSServer) handleSessionList(client *Client, msg *ClientMessage) {
+	client.ReplySuccess(msg, "session_list", map[string]interface{}{
+		"sessions": s.Connections(),
+	})
+}
+
+// handleSessionKick closes connections on behalf of an admin device.
+func (s *WSServer) handleSessionKick(client *Client, msg *ClientMessage) {
+	var req KickRequest
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		client.ReplyError(msg, "Invalid format", 400)
+		return
+	}
+
+	n, err := s.Kick(req)
+	if err != nil {
+		client.ReplyError(msg, err.Error(), kickErrorStatus(err))
+		return
+	}
+	slog.Info("Session kick requested", "by", client.GetDeviceName(), "id", req.ID, "device_id", req.DeviceID)
+	client.ReplySuccess(msg, "session_kick", map[string]int{"closed": n})
+}
+
+// restSessions handles GET /api/v1/sessions.
+func (s *WSServer) restSessions(w http.ResponseWriter, r *http.Request) {
+	restWriteJSON(w, http.StatusOK, map[string]interface{}{
+		"sessions": s.Connections(),
+	})
+}
+
+// restSessionKick handles POST /api/v1/sessions/kick.
+func (s *WSServer) restSessionKick(w http.ResponseWriter, r *http.Request) {
+	var req KickRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		restWriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid body"})
+		return
+	}
+
+	n, err := s.Kick(req)
+	if err != nil {
+		restWriteJSON(w, kickErrorStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+	restWriteJSON(w, http.StatusOK, map[string]int{"closed": n})
+}
+
+// kickErrorStatus maps a Kick error to an HTTP-style status code.
+func kickErrorStatus(err error) int {
+	if errors.Is(err, errKickUnknown) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
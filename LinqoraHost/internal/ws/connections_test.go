@@ -0,0 +1,166 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"LinqoraHost/internal/config"
+)
+
+// adminAuthManager treats every device as an admin.
+type adminAuthManager struct{ MockAuthManager }
+
+func (m *adminAuthManager) IsAdmin(deviceID string) bool { return true }
+
+func TestDisconnectDeviceDropsConnectionsAndSessions(t *testing.T) {
+	server := NewWSServer(config.DefaultConfig(), &MockAuthManager{})
+	revoked, token := connectAuthorized(t, server, "dev-1")
+	other, _ := connectAuthorized(t, server, "dev-2")
+
+	if n := server.DisconnectDevice("dev-1", "authorization revoked"); n != 1 {
+		t.Fatalf("Expected 1 connection closed, got %d", n)
+	}
+	if !revoked.IsClosed() || other.IsClosed() {
+		t.Error("Expected only the device's own connection to be closed")
+	}
+	if conns := server.Connections(); len(conns) != 1 || conns[0].DeviceID != "dev-2" {
+		t.Errorf("Expected only dev-2 to remain connected, got %+v", conns)
+	}
+
+	fresh := NewClient(nil, "127.0.0.1")
+	server.handleClientMessage(fresh, resumeMessage(token))
+	if resp := readResponse(t, fresh); resp.Error == nil || *resp.Error.Code != 401 {
+		t.Errorf("Expected the dropped session not to resume, got %+v", resp)
+	}
+}
+
+func TestSessionKickClosesAndBlocks(t *testing.T) {
+	server := NewWSServer(config.DefaultConfig(), &adminAuthManager{})
+	admin, _ := connectAuthorized(t, server, "admin")
+	phone, token := connectAuthorized(t, server, "phone")
+
+	server.handleClientMessage(admin, &ClientMessage{Type: "session_list"})
+	resp := readResponse(t, admin)
+	raw, _ := json.Marshal(resp.Data)
+	var list struct {
+		Sessions []ConnectionInfo `json:"sessions"`
+	}
+	json.Unmarshal(raw, &list)
+	if len(list.Sessions) != 2 || list.Sessions[1].ID != phone.id || list.Sessions[0].MessagesReceived != 1 {
+		t.Fatalf("Unexpected session list %+v", list.Sessions)
+	}
+
+	kick, _ := json.Marshal(KickRequest{ID: phone.id, Reason: "bye", BlockSeconds: 600})
+	server.handleClientMessage(admin, &ClientMessage{ID: "k", Type: "session_kick", Data: kick})
+	if resp := readResponse(t, admin); resp.Error != nil {
+		t.Fatalf("Expected the kick to succeed, got %+v", resp.Error)
+	}
+	if !phone.IsClosed() {
+		t.Error("Expected the kicked connection to be closed")
+	}
+
+	retry := NewClient(nil, "127.0.0.1")
+	auth, _ := json.Marshal(map[string]string{"deviceId": "phone"})
+	server.handleClientMessage(retry, &ClientMessage{Type: "auth_request", Data: auth})
+	if resp := readResponse(t, retry); resp.Error == nil || *resp.Error.Code != 403 {
+		t.Errorf("Expected a blocked device to be refused, got %+v", resp)
+	}
+	server.handleClientMessage(retry, resumeMessage(token))
+	if resp := readResponse(t, retry); resp.Error == nil {
+		t.Error("Expected a blocked device not to resume")
+	}
+
+	missing, _ := json.Marshal(KickRequest{ID: "c0"})
+	server.handleClientMessage(admin, &ClientMessage{Type: "session_kick", Data: missing})
+	if resp := readResponse(t, admin); resp.Error == nil || *resp.Error.Code != 404 {
+		t.Errorf("Expected 404 for an unknown connection, got %+v", resp)
+	}
+}
+
+// expiringAuthManager reports its devices as expired on the next sweep.
+type expiringAuthManager struct {
+	MockAuthManager
+	expired []string
+}
+
+func (m *expiringAuthManager) SweepExpired() []string {
+	ids := m.expired
+	m.expired = nil
+	return ids
+}
+
+func TestSweepExpiredDevicesDisconnectsGuests(t *testing.T) {
+	am := &expiringAuthManager{expired: []string{"guest"}}
+	server := NewWSServer(config.DefaultConfig(), am)
+	guest, _ := connectAuthorized(t, server, "guest")
+	owner, _ := connectAuthorized(t, server, "owner")
+
+	server.sweepExpiredDevices()
+	if !guest.IsClosed() || owner.IsClosed() {
+		t.Error("Expected only the expired guest to be disconnected")
+	}
+	if conns := server.Connections(); len(conns) != 1 || conns[0].DeviceID != "owner" {
+		t.Errorf("Expected only owner to remain connected, got %+v", conns)
+	}
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	cases := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"bye", 10, "bye"},
+		{"goodbye", 4, "good"},
+		{"grüße", 3, "gr"},
+		{"grüße", 4, "grü"},
+		{"日本", 2, ""},
+	}
+	for _, c := range cases {
+		got := truncateUTF8(c.in, c.n)
+		if got != c.want || !utf8.ValidString(got) {
+			t.Errorf("truncateUTF8(%q, %d) = %q, want %q", c.in, c.n, got, c.want)
+		}
+	}
+}
+
+func TestRESTSessionsRequireAdminToken(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.SharedSecret = "secret"
+	server := NewWSServer(cfg, &tokenAuthManager{token: "lqt_admin", scopes: []string{config.ScopeAdmin}})
+	routes := make(map[string]RESTRoute)
+	for _, route := range server.registry.Routes() {
+		routes[route.Path] = route
+	}
+
+	call := func(path, body, credential string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(routes[path].Method, path, strings.NewReader(body))
+		if credential != "" {
+			req.Header.Set("Authorization", "Bearer "+credential)
+		}
+		server.restHandler(routes[path])(rec, req)
+		return rec.Code
+	}
+
+	kick := `{"deviceId":"dev-1","blockSeconds":600}`
+	for _, c := range []struct {
+		path, body, credential string
+		want                   int
+	}{
+		{"/api/v1/sessions", "", "", http.StatusUnauthorized},
+		{"/api/v1/sessions", "", "secret", http.StatusForbidden},
+		{"/api/v1/sessions", "", "lqt_admin", http.StatusOK},
+		{"/api/v1/sessions/kick", kick, "", http.StatusUnauthorized},
+		{"/api/v1/sessions/kick", kick, "secret", http.StatusForbidden},
+		{"/api/v1/sessions/kick", kick, "lqt_admin", http.StatusOK},
+	} {
+		if code := call(c.path, c.body, c.credential); code != c.want {
+			t.Errorf("%s with %q: expected %d, got %d", c.path, c.credential, c.want, code)
+		}
+	}
+}
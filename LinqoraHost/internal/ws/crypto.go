@@ -6,44 +6,97 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
 	"fmt"
 	"io"
 )
 
+// Directions bound into the associated data of sequenced envelopes, so a
+// frame cannot be reflected back to the side that sent it.
+const (
+	DirectionClientToServer byte = 'C'
+	DirectionServerToClient byte = 'S'
+)
+
+// envelopeAADLabel prefixes the associated data of sequenced envelopes.
+const envelopeAADLabel = "linqora-e2ee-v1"
+
+// envelopeAAD builds the associated data for a sequenced envelope.
+func envelopeAAD(direction byte, seq uint64) []byte {
+	aad := make([]byte, 0, len(envelopeAADLabel)+9)
+	aad = append(aad, envelopeAADLabel...)
+	aad = append(aad, direction)
+	return binary.BigEndian.AppendUint64(aad, seq)
+}
+
 // DeriveKey generates a 32-byte AES key from a string secret.
 func DeriveKey(secret string) []byte {
 	hash := sha256.Sum256([]byte(secret))
 	return hash[:]
 }
 
-// Encrypt encrypts plain text using AES-GCM with the provided key.
+// Encrypt encrypts plain text using AES-GCM with the provided key and
+// returns the nonce-prefixed cipher text as base64.
 func Encrypt(plainText []byte, key []byte) (string, error) {
-	block, err := aes.NewCipher(key)
+	sealed, err := Seal(plainText, key)
 	if err != nil {
 		return "", err
 	}
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts base64 encoded cipher text using AES-GCM.
+func Decrypt(cryptoText string, key []byte) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(cryptoText)
+	if err != nil {
+		return nil, err
+	}
+	return Open(data, key)
+}
+
+// Seal encrypts plain text using AES-GCM and returns nonce || cipher text.
+// Binary codecs carry this directly; Encrypt wraps it in base64 for JSON.
+func Seal(plainText []byte, key []byte) ([]byte, error) {
+	return seal(plainText, key, nil)
+}
+
+// SealSequenced is Seal with the direction and sequence number authenticated
+// as associated data.
+func SealSequenced(plainText []byte, key []byte, direction byte, seq uint64) ([]byte, error) {
+	return seal(plainText, key, envelopeAAD(direction, seq))
+}
+
+func seal(plainText, key, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
 
 	gcm, err := cipher.NewGCM(block)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	nonce := make([]byte, gcm.NonceSize())
 	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", err
+		return nil, err
 	}
 
-	cipherText := gcm.Seal(nonce, nonce, plainText, nil)
-	return base64.StdEncoding.EncodeToString(cipherText), nil
+	return gcm.Seal(nonce, nonce, plainText, aad), nil
 }
 
-// Decrypt decrypts base64 encoded cipher text using AES-GCM.
-func Decrypt(cryptoText string, key []byte) ([]byte, error) {
-	data, err := base64.StdEncoding.DecodeString(cryptoText)
-	if err != nil {
-		return nil, err
-	}
+// Open decrypts nonce || cipher text produced by Seal.
+func Open(data []byte, key []byte) ([]byte, error) {
+	return open(data, key, nil)
+}
+
+// OpenSequenced decrypts data produced by SealSequenced. It fails unless
+// direction and seq match the values used when sealing.
+func OpenSequenced(data []byte, key []byte, direction byte, seq uint64) ([]byte, error) {
+	return open(data, key, envelopeAAD(direction, seq))
+}
 
+func open(data, key, aad []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
@@ -60,5 +113,5 @@ func Decrypt(cryptoText string, key []byte) ([]byte, error) {
 	}
 
 	nonce, cipherText := data[:nonceSize], data[nonceSize:]
-	return gcm.Open(nil, nonce, cipherText, nil)
+	return gcm.Open(nil, nonce, cipherText, aad)
 }
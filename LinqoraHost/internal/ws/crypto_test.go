@@ -59,3 +59,24 @@ func TestDecryptInvalidData(t *testing.T) {
 		t.Error("Decryption of too short data should fail")
 	}
 }
+
+func TestSequencedEnvelopeBindsDirectionAndSequence(t *testing.T) {
+	key := DeriveKey("secret")
+	sealed, err := SealSequenced([]byte("power off"), key, DirectionClientToServer, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenSequenced(sealed, key, DirectionClientToServer, 7); err != nil {
+		t.Errorf("Expected matching direction and sequence to open, got %v", err)
+	}
+	if _, err := OpenSequenced(sealed, key, DirectionClientToServer, 8); err == nil {
+		t.Error("Expected a different sequence number to fail")
+	}
+	if _, err := OpenSequenced(sealed, key, DirectionServerToClient, 7); err == nil {
+		t.Error("Expected the opposite direction to fail")
+	}
+	if _, err := Open(sealed, key); err == nil {
+		t.Error("Expected an unsequenced open to fail")
+	}
+}
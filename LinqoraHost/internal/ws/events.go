@@ -0,0 +1,113 @@
+package ws
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// sseKeepAlive is the interval between comment lines that stop idle event
+// streams from being closed by proxies.
+const sseKeepAlive = 25 * time.Second
+
+// parseRooms splits a comma-separated room list, dropping blanks and duplicates.
+func parseRooms(raw string) []string {
+	seen := make(map[string]bool)
+	rooms := make([]string, 0)
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		rooms = append(rooms, name)
+	}
+	return rooms
+}
+
+// restEvents streams room broadcasts and server-wide events as Server-Sent
+// Events. The stream is backed by a connection-less Client, so it joins rooms
+// through the RoomManager and collectors start and stop exactly as they do
+// for WebSocket clients.
+func (s *WSServer) restEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		restWriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming unsupported"})
+		return
+	}
+
+	rooms := parseRooms(r.URL.Query().Get("rooms"))
+	caller := restCallerFrom(r)
+	for _, room := range rooms {
+		if !caller.allowed(roomScopes[room]) {
+			restWriteJSON(w, http.StatusForbidden, map[string]string{"error": "missing scope: " + roomScopes[room]})
+			return
+		}
+	}
+
+	client := NewClient(nil, r.RemoteAddr)
+	client.DeviceName = "sse " + r.RemoteAddr
+	client.streamCaller = caller
+	client.queue.totals = &s.outboundTotals
+
+	if status, reason, ok := s.admitStream(client); !ok {
+		slog.Warn("Event stream refused", "remote_addr", r.RemoteAddr, "reason", reason)
+		restWriteJSON(w, status, map[string]string{"error": reason})
+		return
+	}
+
+	for _, room := range rooms {
+		s.roomManager.AddClientToRoom(room, client)
+	}
+	slog.Info("Event stream opened", "remote_addr", r.RemoteAddr, "rooms", rooms)
+
+	defer func() {
+		s.roomManager.RemoveClientFromAllRooms(client)
+		s.clientsMutex.Lock()
+		delete(s.eventStreams, client)
+		s.clientsMutex.Unlock()
+		client.Close()
+		slog.Info("Event stream closed", "remote_addr", r.RemoteAddr)
+	}()
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	flusher.Flush()
+
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-client.queue.ready:
+			for {
+				msgType, payload, ok, closed := client.queue.popMessage()
+				if closed {
+					return
+				}
+				if !ok {
+					break
+				}
+				// Payloads are single-line JSON, so one data field suffices.
+				if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msgType, payload); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package ws
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"LinqoraHost/internal/config"
+)
+
+func TestParseRooms(t *testing.T) {
+	got := parseRooms(" metrics,,media , metrics")
+	if want := []string{"metrics", "media"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+// readEvent returns the next "event:"/"data:" pair from an SSE stream,
+// skipping comments and the retry hint.
+func readEvent(t *testing.T, r *bufio.Reader) (string, string) {
+	t.Helper()
+	var event, data string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Stream ended: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "" && event != "":
+			return event, data
+		}
+	}
+}
+
+func TestEventStreamDeliversRoomAndServerWideEvents(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.SharedSecret = "secret"
+	server := NewWSServer(cfg, &MockAuthManager{})
+	route := RESTRoute{Path: "/api/v1/events", Method: http.MethodGet, Handle: server.restEvents}
+	ts := httptest.NewServer(server.restHandler(route))
+	defer ts.Close()
+
+	if resp, err := http.Get(ts.URL + "?rooms=alerts"); err != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected 401 without a token, got %v %v", resp, err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"?rooms=alerts", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected text/event-stream, got %q", ct)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for server.roomManager.GetRoom("alerts") == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Event stream never joined its room")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	body := bufio.NewReader(resp.Body)
+
+	server.roomManager.SendToRoom("alerts", "room_event", map[string]int{"n": 1}, nil)
+	if event, data := readEvent(t, body); event != "room_event" || !strings.Contains(data, `"n":1`) {
+		t.Errorf("Expected room_event, got %s %s", event, data)
+	}
+
+	server.broadcastToAll("battery_alert", map[string]int{"percent": 10})
+	if event, _ := readEvent(t, body); event != "battery_alert" {
+		t.Errorf("Expected battery_alert, got %s", event)
+	}
+
+	resp.Body.Close()
+	for server.roomManager.GetRoom("alerts") != nil {
+		if time.Now().After(deadline.Add(2 * time.Second)) {
+			t.Fatal("Event stream did not leave its room after disconnect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// tokenAuthManager accepts a single API token with fixed scopes.
+type tokenAuthManager struct {
+	MockAuthManager
+	token  string
+	scopes []string
+}
+
+func (m *tokenAuthManager) AuthenticateToken(token string) (string, []string, bool) {
+	return "integration", m.scopes, token == m.token
+}
+
+func TestRESTTokenScopes(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.SharedSecret = "secret"
+	server := NewWSServer(cfg, &tokenAuthManager{token: "lqt_metrics", scopes: []string{config.ScopeMetrics}})
+
+	stats := httptest.NewServer(server.restHandler(RESTRoute{Path: "/api/v1/stats", Method: http.MethodGet, Handle: server.restStats, Scope: config.ScopeMetrics}))
+	defer stats.Close()
+	power := httptest.NewServer(server.restHandler(RESTRoute{Path: "/api/v1/power", Method: http.MethodPost, Handle: server.restPower, Scope: config.ScopePower}))
+	defer power.Close()
+
+	call := func(url, method, token string) int {
+		req, _ := http.NewRequest(method, url, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := call(stats.URL, http.MethodGet, "lqt_metrics"); code != http.StatusOK {
+		t.Errorf("Expected 200 for a token with the metrics scope, got %d", code)
+	}
+	if code := call(power.URL, http.MethodPost, "lqt_metrics"); code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a token without the power scope, got %d", code)
+	}
+	if code := call(stats.URL, http.MethodGet, "lqt_wrong"); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for an unknown token, got %d", code)
+	}
+	if code := call(stats.URL, http.MethodGet, "secret"); code != http.StatusOK {
+		t.Errorf("Expected the shared secret to keep full access, got %d", code)
+	}
+}
+
+func TestRESTRefusedWithoutCredentials(t *testing.T) {
+	// Neither a shared secret nor API tokens are configured.
+	server := NewWSServer(config.DefaultConfig(), &MockAuthManager{})
+	handler := server.restHandler(RESTRoute{Path: "/api/v1/stats", Method: http.MethodGet, Handle: server.restStats, Scope: config.ScopeMetrics})
+
+	for _, header := range []string{"", "Bearer ", "Bearer anything"} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/stats", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		handler(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 with Authorization %q, got %d", header, rec.Code)
+		}
+	}
+}
+
+func TestEventStreamSkipsEventsOutsideTokenScopes(t *testing.T) {
+	cfg := config.DefaultConfig()
+	server := NewWSServer(cfg, &tokenAuthManager{token: "lqt_metrics", scopes: []string{config.ScopeMetrics}})
+	route := RESTRoute{Path: "/api/v1/events", Method: http.MethodGet, Handle: server.restEvents, Scope: config.ScopeMetrics}
+	ts := httptest.NewServer(server.restHandler(route))
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	req.Header.Set("Authorization", "Bearer lqt_metrics")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the stream to open, got %v %v", resp, err)
+	}
+	defer resp.Body.Close()
+
+	server.broadcastToAll("script_output", map[string]string{"line": "secret"})
+	server.broadcastToAll("battery_alert", map[string]int{"percent": 10})
+	if event, data := readEvent(t, bufio.NewReader(resp.Body)); event != "battery_alert" {
+		t.Errorf("Expected script output to be withheld from a metrics token, got %s %s", event, data)
+	}
+}
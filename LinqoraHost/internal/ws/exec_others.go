@@ -0,0 +1,13 @@
+//go:build !windows
+
+package ws
+
+import (
+	"context"
+	"os/exec"
+)
+
+// shellCommand builds the command used by shell_exec.
+func shellCommand(ctx context.Context, rawCmd string) *exec.Cmd {
+	return exec.CommandContext(ctx, "sh", "-c", rawCmd)
+}
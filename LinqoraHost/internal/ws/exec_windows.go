@@ -0,0 +1,21 @@
+//go:build windows
+
+package ws
+
+import (
+	"context"
+	"os/exec"
+	"syscall"
+)
+
+// shellCommand builds the command used by shell_exec, hiding the console window.
+// The command line is handed to cmd.exe as typed: /S strips only the outer
+// quotes added here, so quoted arguments survive.
+func shellCommand(ctx context.Context, rawCmd string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "cmd.exe")
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		HideWindow: true,
+		CmdLine:    `cmd.exe /S /C "` + rawCmd + `"`,
+	}
+	return cmd
+}
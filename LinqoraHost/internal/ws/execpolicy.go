@@ -0,0 +1,31 @@
+package ws
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+
+	"LinqoraHost/internal/execpolicy"
+	"LinqoraHost/internal/scheduler"
+)
+
+// logExecDecision records why a command was allowed or refused.
+func logExecDecision(kind, deviceID, command string, d execpolicy.Decision) {
+	log := slog.Info
+	if !d.Allowed {
+		log = slog.Warn
+	}
+	log("Exec policy decision", "kind", kind, "device_id", deviceID, "command", command,
+		"allowed", d.Allowed, "reason", d.Reason)
+}
+
+// scriptGuard applies the exec policy to every script run.
+func (s *WSServer) scriptGuard(script scheduler.Script, deviceID string) (scheduler.Launch, error) {
+	policy := &s.Config().ExecPolicy
+	d := execpolicy.CheckCommand(policy, deviceID, script.Command, script.Args, script.WorkDir)
+	logExecDecision("script "+script.ID, deviceID, strings.TrimSpace(script.Command+" "+strings.Join(script.Args, " ")), d)
+	if !d.Allowed {
+		return scheduler.Launch{}, errors.New(d.Reason)
+	}
+	return scheduler.Launch{Dir: d.Dir, Env: execpolicy.Env(policy)}, nil
+}
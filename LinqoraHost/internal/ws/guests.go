@@ -0,0 +1,37 @@
+package ws
+
+import (
+	"log/slog"
+	"time"
+)
+
+// expirySweepInterval is how often time-limited authorizations are checked.
+// An expired device is refused at once; the sweep revokes the entry and
+// closes sessions that are still open.
+const expirySweepInterval = 15 * time.Second
+
+// StartExpirySweeper periodically revokes devices whose authorization has
+// expired and disconnects them.
+func (s *WSServer) StartExpirySweeper() {
+	go func() {
+		ticker := time.NewTicker(expirySweepInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.sweepExpiredDevices()
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// sweepExpiredDevices revokes expired devices and closes their connections.
+func (s *WSServer) sweepExpiredDevices() {
+	for _, deviceID := range s.authManager.SweepExpired() {
+		n := s.DisconnectDevice(deviceID, "authorization expired")
+		slog.Info("Guest authorization expired", "device_id", deviceID, "disconnected", n)
+	}
+}
@@ -0,0 +1,91 @@
+package ws
+
+import (
+	"net/http"
+
+	"LinqoraHost/internal/config"
+)
+
+// registerBuiltinHandlers registers every message type served by the host itself.
+func (s *WSServer) registerBuiltinHandlers() {
+	r := s.registry
+
+	// Session and authentication
+	r.Register(Handler{Type: "ping", Handle: s.handlePingMessage, AuthExempt: true, RateLimitExempt: true, NoAudit: true})
+	r.Register(Handler{Type: "auth_request", Handle: s.handleAuthRequest, AuthExempt: true})
+	r.Register(Handler{Type: "auth_check", Handle: s.handleAuthCheck, AuthExempt: true})
+	r.Register(Handler{Type: "auth_challenge_response", Handle: s.handleChallengeResponse, AuthExempt: true})
+	r.Register(Handler{Type: "pair_request", Handle: s.handlePairRequest, AuthExempt: true, Cost: 10})
+	r.Register(Handler{Type: "session_resume", Handle: s.handleSessionResume, AuthExempt: true, Cost: 5})
+	r.Register(Handler{Type: "key_exchange", Handle: s.handleKeyExchange, Cost: 5})
+	r.Register(Handler{Type: "step_up_response", Handle: s.handleStepUpResponse, Cost: 5})
+	r.Register(Handler{Type: "step_up_pin", Handle: s.handleStepUpPIN, Cost: 5, StepUp: true, Confirm: config.ConfirmStepUpPIN})
+	r.Register(Handler{Type: "host_info", Handle: s.handleHostInfoMessage, Cost: 5})
+	r.Register(Handler{Type: "platform_caps", Handle: s.handlePlatformCaps})
+	r.Register(Handler{Type: "join_room", Handle: s.handleJoinRoomMessage})
+	r.Register(Handler{Type: "leave_room", Handle: s.handleLeaveRoomMessage})
+
+	// Device administration
+	r.Register(Handler{Type: "auth_pending_list", Handle: s.handlePendingList, Admin: true})
+	r.Register(Handler{Type: "auth_approve", Handle: s.handlePendingDecision, Admin: true})
+	r.Register(Handler{Type: "auth_reject", Handle: s.handlePendingDecision, Admin: true})
+	r.Register(Handler{Type: "session_list", Handle: s.handleSessionList, Admin: true})
+	r.Register(Handler{Type: "session_kick", Handle: s.handleSessionKick, Admin: true})
+
+	// Input and media
+	r.Register(Handler{Type: "media", Handle: s.handleMediaCommand, Room: "media", Scope: config.ScopeMedia})
+	r.Register(Handler{Type: "mouse", Handle: s.handleMouseCommand, Scope: config.ScopeInput, NoAudit: true})
+	r.Register(Handler{Type: "keyboard", Handle: s.handleKeyboardCommand, Scope: config.ScopeInput})
+	r.Register(Handler{Type: "keyboard_type", Handle: s.handleKeyboardTypeCommand, Cost: 2, Scope: config.ScopeInput})
+	r.Register(Handler{Type: "clipboard_set", Handle: s.handleClipboardSet, Scope: config.ScopeClipboard})
+
+	// System control
+	r.Register(Handler{Type: "power", Handle: s.handlePowerCommand, Cost: 5, Scope: config.ScopePower, Confirm: config.ConfirmPower})
+	r.Register(Handler{Type: "display_cmd", Handle: s.handleDisplayCommand, Scope: config.ScopeDisplay})
+	r.Register(Handler{Type: "monitor_list", Handle: s.handleMonitorList, Cost: 2, Scope: config.ScopeDisplay})
+	r.Register(Handler{Type: "monitor_cmd", Handle: s.handleMonitorCommand, Cost: 5, Scope: config.ScopeDisplay})
+	r.Register(Handler{Type: "monitor_set_resolution", Handle: s.handleMonitorSetResolution, Cost: 5, Scope: config.ScopeDisplay})
+	r.Register(Handler{Type: "monitor_set_primary", Handle: s.handleMonitorSetPrimary, Cost: 5, Scope: config.ScopeDisplay})
+	r.Register(Handler{Type: "process_list", Handle: s.handleProcessList, Cost: 5, Scope: config.ScopeProcesses})
+	r.Register(Handler{Type: "process_kill", Handle: s.handleProcessKill, Cost: 2, Scope: config.ScopeProcesses, Confirm: config.ConfirmProcessKill})
+	r.Register(Handler{Type: "startup_list", Handle: s.handleStartupList, Cost: 2, Scope: config.ScopeProcesses})
+	r.Register(Handler{Type: "startup_set", Handle: s.handleStartupSet, Cost: 2, Scope: config.ScopeProcesses})
+	r.Register(Handler{Type: "battery_alert_config", Handle: s.handleBatteryAlertConfig})
+	r.Register(Handler{Type: "shell_exec", Handle: s.handleShellExec, Cost: 10, Async: true, Scope: config.ScopeShell, Confirm: config.ConfirmShell})
+
+	// Scripts
+	r.Register(Handler{Type: "script_list", Handle: s.handleScriptList, Scope: config.ScopeScripts})
+	r.Register(Handler{Type: "script_add", Handle: s.handleScriptAdd, Cost: 2, Scope: config.ScopeScripts})
+	r.Register(Handler{Type: "script_update", Handle: s.handleScriptUpdate, Cost: 2, Scope: config.ScopeScripts})
+	r.Register(Handler{Type: "script_delete", Handle: s.handleScriptDelete, Cost: 2, Scope: config.ScopeScripts})
+	r.Register(Handler{Type: "script_stop", Handle: s.handleScriptStop, Scope: config.ScopeScripts})
+	r.Register(Handler{Type: "script_execute", Handle: s.handleScriptExecute, Cost: 5, Async: true, Scope: config.ScopeScripts})
+
+	// Files
+	r.Register(Handler{Type: "file_list", Handle: s.handleFileList, Cost: 2, Scope: config.ScopeFilesRead})
+	r.Register(Handler{Type: "file_read", Handle: s.handleFileRead, Cost: 5, Scope: config.ScopeFilesRead})
+	r.Register(Handler{Type: "file_write", Handle: s.handleFileWrite, Cost: 5, Scope: config.ScopeFilesWrite, Confirm: config.ConfirmFileWrite})
+}
+
+// registerBuiltinRoutes registers the REST API served by the host itself.
+func (s *WSServer) registerBuiltinRoutes() {
+	r := s.registry
+
+	r.RegisterREST(RESTRoute{Path: "/api/v1/info", Method: http.MethodGet, Handle: s.restInfo, Scope: config.ScopeMetrics})
+	r.RegisterREST(RESTRoute{Path: "/api/v1/stats", Method: http.MethodGet, Handle: s.restStats, Scope: config.ScopeMetrics})
+	r.RegisterREST(RESTRoute{Path: "/api/v1/events", Method: http.MethodGet, Handle: s.restEvents, Scope: config.ScopeMetrics})
+	r.RegisterREST(RESTRoute{Path: "/api/v1/processes", Method: http.MethodGet, Handle: s.restProcesses, Scope: config.ScopeProcesses})
+	r.RegisterREST(RESTRoute{Path: "/api/v1/processes/kill", Method: http.MethodPost, Handle: s.restKillProcess, Scope: config.ScopeProcesses, Confirm: config.ConfirmProcessKill})
+	r.RegisterREST(RESTRoute{Path: "/api/v1/qr", Method: http.MethodGet, Handle: s.restQR})
+	r.RegisterREST(RESTRoute{Path: "/api/v1/metrics", Method: http.MethodGet, Handle: s.restMetrics, Scope: config.ScopeMetrics})
+	r.RegisterREST(RESTRoute{Path: "/api/v1/scripts", Method: http.MethodGet, Handle: s.restScripts, Scope: config.ScopeScripts})
+	r.RegisterREST(RESTRoute{Path: "/api/v1/scripts/execute", Method: http.MethodPost, Handle: s.restScriptExecute, Scope: config.ScopeScripts})
+	r.RegisterREST(RESTRoute{Path: "/api/v1/media", Method: http.MethodPost, Handle: s.restMedia, Scope: config.ScopeMedia})
+	r.RegisterREST(RESTRoute{Path: "/api/v1/power", Method: http.MethodPost, Handle: s.restPower, Scope: config.ScopePower, Confirm: config.ConfirmPower})
+	r.RegisterREST(RESTRoute{Path: "/api/v1/keyboard/type", Method: http.MethodPost, Handle: s.restKeyboardType, Scope: config.ScopeInput})
+	r.RegisterREST(RESTRoute{Path: "/api/v1/auth/pending", Method: http.MethodGet, Handle: s.restPendingList, Scope: config.ScopeAdmin, TokenOnly: true})
+	r.RegisterREST(RESTRoute{Path: "/api/v1/auth/approve", Method: http.MethodPost, Handle: s.restPendingDecision, Scope: config.ScopeAdmin, TokenOnly: true})
+	r.RegisterREST(RESTRoute{Path: "/api/v1/auth/reject", Method: http.MethodPost, Handle: s.restPendingDecision, Scope: config.ScopeAdmin, TokenOnly: true})
+	r.RegisterREST(RESTRoute{Path: "/api/v1/sessions", Method: http.MethodGet, Handle: s.restSessions, Scope: config.ScopeAdmin, TokenOnly: true})
+	r.RegisterREST(RESTRoute{Path: "/api/v1/sessions/kick", Method: http.MethodPost, Handle: s.restSessionKick, Scope: config.ScopeAdmin, TokenOnly: true})
+}
@@ -0,0 +1,119 @@
+package ws
+
+import (
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"log/slog"
+)
+
+const (
+	// keyExchangeLabel versions the handshake transcript and key derivation.
+	keyExchangeLabel = "linqora-kx-v1"
+	// sessionKeyInfo is the HKDF info string for the connection key.
+	sessionKeyInfo = "linqora e2ee session key"
+)
+
+// keyExchangeRequest is the data of a client's "key_exchange" message.
+// MAC is HMAC-SHA256(shared secret, label | "client" | PublicKey).
+type keyExchangeRequest struct {
+	PublicKey []byte `json:"public_key"`
+	MAC       []byte `json:"mac"`
+}
+
+// keyExchangeResponse is the server's reply. MAC is
+// HMAC-SHA256(shared secret, label | "server" | PublicKey | client key), so
+// the client knows the reply answers its own request.
+type keyExchangeResponse struct {
+	PublicKey []byte `json:"public_key"`
+	MAC       []byte `json:"mac"`
+}
+
+// keyExchangeMAC authenticates one side of the handshake with the shared secret.
+func keyExchangeMAC(secret, role string, keys ...[]byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(keyExchangeLabel))
+	mac.Write([]byte(role))
+	for _, key := range keys {
+		mac.Write(key)
+	}
+	return mac.Sum(nil)
+}
+
+// deriveSessionKey turns an X25519 shared secret into an AES-256 key bound to
+// both public keys.
+func deriveSessionKey(shared, clientPub, serverPub []byte) ([]byte, error) {
+	salt := make([]byte, 0, len(clientPub)+len(serverPub))
+	salt = append(salt, clientPub...)
+	salt = append(salt, serverPub...)
+	return hkdf.Key(sha256.New, shared, salt, sessionKeyInfo, 32)
+}
+
+// handleKeyExchange replaces the connection's E2EE key with a fresh one agreed
+// over X25519. The server key pair lives only for this call, so a leaked shared
+// secret does not expose traffic recorded earlier. Clients that never send
+// key_exchange keep the key derived from the shared secret.
+func (s *WSServer) handleKeyExchange(client *Client, msg *ClientMessage) {
+	cfg := s.Config()
+	if !cfg.EnableE2EE || cfg.SharedSecret == "" {
+		client.ReplyError(msg, "E2EE is disabled on this host", 400)
+		return
+	}
+
+	var req keyExchangeRequest
+	if err := json.Unmarshal(msg.Data, &req); err != nil || len(req.PublicKey) == 0 {
+		client.ReplyError(msg, "Invalid key_exchange format", 400)
+		return
+	}
+
+	expected := keyExchangeMAC(cfg.SharedSecret, "client", req.PublicKey)
+	if !hmac.Equal(req.MAC, expected) {
+		slog.Warn("Key exchange MAC mismatch", "device", client.DeviceName, "ip", client.IP)
+		client.ReplyError(msg, "Key exchange authentication failed", 401)
+		return
+	}
+
+	curve := ecdh.X25519()
+	clientKey, err := curve.NewPublicKey(req.PublicKey)
+	if err != nil {
+		client.ReplyError(msg, "Invalid public key", 400)
+		return
+	}
+
+	serverKey, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		slog.Error("Failed to generate key exchange key", "err", err)
+		client.ReplyError(msg, "Key exchange failed", 500)
+		return
+	}
+
+	shared, err := serverKey.ECDH(clientKey)
+	if err != nil {
+		client.ReplyError(msg, "Invalid public key", 400)
+		return
+	}
+
+	serverPub := serverKey.PublicKey().Bytes()
+	sessionKey, err := deriveSessionKey(shared, req.PublicKey, serverPub)
+	if err != nil {
+		slog.Error("Failed to derive session key", "err", err)
+		client.ReplyError(msg, "Key exchange failed", 500)
+		return
+	}
+
+	reply := NewSuccessResponse("key_exchange", keyExchangeResponse{
+		PublicKey: serverPub,
+		MAC:       keyExchangeMAC(cfg.SharedSecret, "server", serverPub, req.PublicKey),
+	}).WithID(msg.ID)
+
+	// The reply still travels under the previous key; everything after it
+	// uses the new one.
+	if err := client.replyAndRekey(reply, sessionKey); err != nil {
+		slog.Error("Failed to complete key exchange", "device", client.DeviceName, "err", err)
+		return
+	}
+	slog.Info("E2EE session key established", "device", client.DeviceName)
+}
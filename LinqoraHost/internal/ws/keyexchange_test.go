@@ -0,0 +1,235 @@
+package ws
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"LinqoraHost/internal/config"
+)
+
+// openEnvelope decrypts an "encrypted" frame with key and returns the inner
+// response. Sequenced frames are opened as sent from the server.
+func openEnvelope(t *testing.T, frame []byte, key []byte) ServerResponse {
+	t.Helper()
+	var outer struct {
+		Type string           `json:"type"`
+		Data encryptedPayload `json:"data"`
+	}
+	if err := json.Unmarshal(frame, &outer); err != nil || outer.Type != "encrypted" {
+		t.Fatalf("Expected an encrypted envelope, got %s", frame)
+	}
+	plain, err := Open(outer.Data.Payload, key)
+	if outer.Data.Seq > 0 {
+		plain, err = OpenSequenced(outer.Data.Payload, key, DirectionServerToClient, outer.Data.Seq)
+	}
+	if err != nil {
+		t.Fatalf("Failed to open envelope: %v", err)
+	}
+	var resp ServerResponse
+	if err := json.Unmarshal(plain, &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func newE2EEServer() (*WSServer, *Client) {
+	cfg := config.DefaultConfig()
+	cfg.EnableE2EE = true
+	cfg.SharedSecret = "test-secret"
+	cfg.RequireKeyExchange = true
+
+	server := NewWSServer(cfg, &MockAuthManager{})
+	client := NewClient(nil, "127.0.0.1")
+	client.SetE2EEKey(DeriveKey(cfg.SharedSecret))
+	return server, client
+}
+
+func TestKeyExchangeDerivesPerConnectionKey(t *testing.T) {
+	server, client := newE2EEServer()
+	legacyKey := DeriveKey("test-secret")
+
+	server.handleClientMessage(client, &ClientMessage{Type: "host_info"})
+	if resp := openEnvelope(t, nextFrame(t, client), legacyKey); resp.Error == nil {
+		t.Fatal("Expected traffic before key_exchange to be refused")
+	}
+
+	priv, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	pub := priv.PublicKey().Bytes()
+	data, _ := json.Marshal(keyExchangeRequest{PublicKey: pub, MAC: keyExchangeMAC("test-secret", "client", pub)})
+	server.handleClientMessage(client, &ClientMessage{ID: "kx", Type: "key_exchange", Data: data})
+
+	resp := openEnvelope(t, nextFrame(t, client), legacyKey)
+	if resp.ID != "kx" || resp.Error != nil {
+		t.Fatalf("Expected a successful key_exchange reply, got %+v", resp)
+	}
+	var reply keyExchangeResponse
+	raw, _ := json.Marshal(resp.Data)
+	json.Unmarshal(raw, &reply)
+	if !hmac.Equal(reply.MAC, keyExchangeMAC("test-secret", "server", reply.PublicKey, pub)) {
+		t.Fatal("Server MAC does not verify")
+	}
+
+	serverPub, err := ecdh.X25519().NewPublicKey(reply.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared, _ := priv.ECDH(serverPub)
+	sessionKey, _ := deriveSessionKey(shared, pub, reply.PublicKey)
+	if bytes.Equal(sessionKey, legacyKey) {
+		t.Fatal("Session key must differ from the shared-secret key")
+	}
+
+	server.handleClientMessage(client, &ClientMessage{ID: "p", Type: "ping"})
+	if resp := openEnvelope(t, nextFrame(t, client), sessionKey); resp.Type != "pong" {
+		t.Errorf("Expected pong under the session key, got %s", resp.Type)
+	}
+	if !client.HasSessionKey() {
+		t.Error("Expected the client to be marked as keyed")
+	}
+}
+
+func TestKeyExchangeRejectsBadMAC(t *testing.T) {
+	server, client := newE2EEServer()
+
+	priv, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	pub := priv.PublicKey().Bytes()
+	data, _ := json.Marshal(keyExchangeRequest{PublicKey: pub, MAC: keyExchangeMAC("wrong", "client", pub)})
+	server.handleClientMessage(client, &ClientMessage{Type: "key_exchange", Data: data})
+
+	resp := openEnvelope(t, nextFrame(t, client), DeriveKey("test-secret"))
+	if resp.Error == nil || *resp.Error.Code != 401 {
+		t.Errorf("Expected 401 for a bad MAC, got %+v", resp)
+	}
+	if client.HasSessionKey() {
+		t.Error("Key must not change after a failed exchange")
+	}
+}
+
+// sealFromClient builds the data of an "encrypted" message as a keyed client would.
+func sealFromClient(t *testing.T, key []byte, seq uint64, msg ClientMessage) []byte {
+	t.Helper()
+	inner, _ := json.Marshal(msg)
+	sealed, err := SealSequenced(inner, key, DirectionClientToServer, seq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(encryptedPayload{Payload: sealed, Seq: seq})
+	return data
+}
+
+func TestKeyedEnvelopesRejectReplayAndReordering(t *testing.T) {
+	client := NewClient(nil, "127.0.0.1")
+	client.SetE2EEKey(DeriveKey("test-secret"))
+	key := DeriveKey("session")
+	if err := client.replyAndRekey(NewSuccessResponse("key_exchange", nil), key); err != nil {
+		t.Fatal(err)
+	}
+	nextFrame(t, client)
+
+	first := sealFromClient(t, key, 1, ClientMessage{Type: "power"})
+	if msg, err := client.openEnvelope(JSONCodec, first); err != nil || msg.Type != "power" {
+		t.Fatalf("Expected the first frame to open, got %v %v", msg, err)
+	}
+	if _, err := client.openEnvelope(JSONCodec, first); !errors.Is(err, errEnvelopeSequence) {
+		t.Errorf("Expected a replayed frame to be rejected, got %v", err)
+	}
+	if _, err := client.openEnvelope(JSONCodec, sealFromClient(t, key, 3, ClientMessage{Type: "ping"})); !errors.Is(err, errEnvelopeSequence) {
+		t.Errorf("Expected a skipped sequence number to be rejected, got %v", err)
+	}
+
+	// A frame the server sent, reflected back with the expected number.
+	reflected, _ := SealSequenced([]byte(`{"type":"ping"}`), key, DirectionServerToClient, 2)
+	data, _ := json.Marshal(encryptedPayload{Payload: reflected, Seq: 2})
+	if _, err := client.openEnvelope(JSONCodec, data); err == nil {
+		t.Error("Expected a server-to-client frame to be rejected")
+	}
+
+	if _, err := client.openEnvelope(JSONCodec, sealFromClient(t, key, 2, ClientMessage{Type: "ping"})); err != nil {
+		t.Errorf("Expected the next frame to open, got %v", err)
+	}
+}
+
+func TestOutboundEnvelopesAreNumbered(t *testing.T) {
+	client := NewClient(nil, "127.0.0.1")
+	key := DeriveKey("session")
+	client.SetE2EEKey(DeriveKey("test-secret"))
+	client.replyAndRekey(NewSuccessResponse("key_exchange", nil), key)
+	nextFrame(t, client)
+
+	client.SendSuccess("a", nil)
+	client.SendSuccess("b", nil)
+	for want := uint64(1); want <= 2; want++ {
+		var outer struct {
+			Data encryptedPayload `json:"data"`
+		}
+		json.Unmarshal(nextFrame(t, client), &outer)
+		if outer.Data.Seq != want {
+			t.Errorf("Expected seq %d, got %d", want, outer.Data.Seq)
+		}
+	}
+}
+
+func TestEnvelopeSequenceSurvivesCoalescingAndDrops(t *testing.T) {
+	client := NewClient(nil, "127.0.0.1")
+	key := DeriveKey("session")
+	client.SetE2EEKey(DeriveKey("test-secret"))
+	client.replyAndRekey(NewSuccessResponse("key_exchange", nil), key)
+	nextFrame(t, client)
+
+	// Senders race each other; snapshots coalesce and, past the soft limit,
+	// best-effort output is dropped.
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < outboundBestEffortLimit/4; i++ {
+				client.SendSuccess("reply", nil)
+				client.SendEvent("metrics", map[string]int{"i": i})
+			}
+		}()
+	}
+	wg.Wait()
+	client.SendEvent("script_output", "chunk")
+
+	stats := client.QueueStats()
+	if stats.Coalesced == 0 || stats.Dropped != 1 {
+		t.Fatalf("Expected coalesced snapshots and a dropped chunk, got %+v", stats)
+	}
+	for want := uint64(1); want <= uint64(stats.Pending); want++ {
+		var outer struct {
+			Data encryptedPayload `json:"data"`
+		}
+		frame := nextFrame(t, client)
+		json.Unmarshal(frame, &outer)
+		if outer.Data.Seq != want {
+			t.Fatalf("Expected seq %d, got %d", want, outer.Data.Seq)
+		}
+		openEnvelope(t, frame, key)
+	}
+}
+
+func TestLegacyEnvelopesCannotCarrySensitiveActions(t *testing.T) {
+	server, client := newE2EEServer()
+	server.Config().RequireKeyExchange = false
+	legacyKey := DeriveKey("test-secret")
+
+	server.handleClientMessage(client, &ClientMessage{ID: "p", Type: "ping"})
+	if resp := openEnvelope(t, nextFrame(t, client), legacyKey); resp.Type != "pong" {
+		t.Fatalf("Expected legacy clients to keep working, got %+v", resp)
+	}
+
+	for _, msgType := range []string{"power", "process_kill", "step_up_pin"} {
+		server.handleClientMessage(client, &ClientMessage{ID: msgType, Type: msgType, Data: json.RawMessage(`{}`)})
+		resp := openEnvelope(t, nextFrame(t, client), legacyKey)
+		if resp.Error == nil || *resp.Error.Code != 403 {
+			t.Errorf("Expected %s to need a key exchange, got %+v", msgType, resp)
+		}
+	}
+}
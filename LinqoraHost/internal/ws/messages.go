@@ -5,14 +5,23 @@ import (
 )
 
 // ClientMessage represents a message received from a client.
+// ID is an optional client-supplied correlation identifier that is echoed
+// back in every reply produced while handling the message.
 type ClientMessage struct {
+	ID   string          `json:"id,omitempty"`
 	Type string          `json:"type"`
 	Room string          `json:"room,omitempty"`
 	Data json.RawMessage `json:"data,omitempty"`
+
+	// result collects the outcome for the audit log.
+	result *replyResult
 }
 
 // ServerResponse represents the unified format for all server responses.
+// ID is set only on replies to a client request; unsolicited broadcasts
+// leave it empty so clients can tell the two apart.
 type ServerResponse struct {
+	ID    string      `json:"id,omitempty"`
 	Type  string      `json:"type"`
 	Data  interface{} `json:"data,omitempty"`
 	Error *ErrorInfo  `json:"error,omitempty"`
@@ -51,3 +60,9 @@ func NewErrorResponse(responseType string, message string, code ...int) ServerRe
 		Error: &errorInfo,
 	}
 }
+
+// WithID returns a copy of the response correlated with the given request ID.
+func (r ServerResponse) WithID(id string) ServerResponse {
+	r.ID = id
+	return r
+}
@@ -0,0 +1,115 @@
+package ws
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// Close reasons sent when a connection limit is hit. A client over the
+// per-address limit gets ClosePolicyViolation, since another attempt from
+// the same address fails the same way until one of its connections ends; a
+// full server gets CloseTryAgainLater.
+const (
+	reasonTooManyFromIP = "too many connections from this address"
+	reasonServerFull    = "server connection limit reached, try again later"
+)
+
+// remoteIP returns the address part of a RemoteAddr, or nil.
+func remoteIP(addr string) net.IP {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	return net.ParseIP(host)
+}
+
+// allowRemote checks the address of r against the allow and deny lists and
+// answers 403 if it may not connect.
+func (s *WSServer) allowRemote(w http.ResponseWriter, r *http.Request) bool {
+	if err := s.Config().CheckIP(remoteIP(r.RemoteAddr)); err != nil {
+		slog.Warn("Connection refused by network policy", "remote_addr", r.RemoteAddr, "path", r.URL.Path, "reason", err)
+		restWriteJSON(w, http.StatusForbidden, map[string]string{"error": "address not allowed"})
+		return false
+	}
+	return true
+}
+
+// admitClient registers client unless a connection limit is reached, in
+// which case it returns the close code and reason to send instead.
+func (s *WSServer) admitClient(client *Client) (int, string, bool) {
+	return s.admit(client, s.clients)
+}
+
+// admitStream registers an event stream under the same limits as WebSocket
+// connections, or returns the HTTP status and reason to answer instead.
+func (s *WSServer) admitStream(stream *Client) (int, string, bool) {
+	code, reason, ok := s.admit(stream, s.eventStreams)
+	switch {
+	case ok:
+		return 0, "", true
+	case code == websocket.CloseTryAgainLater:
+		return http.StatusServiceUnavailable, reason, false
+	default:
+		return http.StatusTooManyRequests, reason, false
+	}
+}
+
+// admit adds client to into, s.clients or s.eventStreams, unless that would
+// exceed a connection limit. WebSocket connections and event streams count
+// towards the same limits.
+func (s *WSServer) admit(client *Client, into map[*Client]bool) (int, string, bool) {
+	perIP, total := s.Config().ConnLimits()
+	ip := remoteIP(client.GetIP())
+
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+
+	if len(s.clients)+len(s.eventStreams) >= total {
+		return websocket.CloseTryAgainLater, reasonServerFull, false
+	}
+	fromIP := 0
+	for _, open := range []map[*Client]bool{s.clients, s.eventStreams} {
+		for c := range open {
+			if remoteIP(c.GetIP()).Equal(ip) {
+				fromIP++
+			}
+		}
+	}
+	if fromIP >= perIP {
+		return websocket.ClosePolicyViolation, reasonTooManyFromIP, false
+	}
+	into[client] = true
+	return 0, "", true
+}
+
+// EnforceNetworkPolicy closes the WebSocket connections and event streams
+// whose address the allow and deny lists no longer admit, after a config
+// reload. It returns how many were closed.
+func (s *WSServer) EnforceNetworkPolicy() int {
+	var refused, streams []*Client
+	s.clientsMutex.Lock()
+	for client := range s.clients {
+		if s.Config().CheckIP(remoteIP(client.GetIP())) != nil {
+			refused = append(refused, client)
+		}
+	}
+	for stream := range s.eventStreams {
+		if s.Config().CheckIP(remoteIP(stream.GetIP())) != nil {
+			streams = append(streams, stream)
+		}
+	}
+	s.clientsMutex.Unlock()
+
+	for _, client := range refused {
+		s.dropClient(client, "address no longer allowed")
+		slog.Info("Connection closed by network policy", "id", client.id, "ip", client.GetIP())
+	}
+	// Event streams end once their queue is closed; the handler cleans up.
+	for _, stream := range streams {
+		stream.Close()
+	}
+	return len(refused) + len(streams)
+}
@@ -0,0 +1,129 @@
+package ws
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"LinqoraHost/internal/config"
+
+	"github.com/gorilla/websocket"
+)
+
+func dialTest(t *testing.T, ts *httptest.Server) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	return websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+}
+
+func TestNetworkPolicyRefusesBeforeUpgrade(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.AllowCIDRs = []string{"192.168.1.0/24"}
+	server := NewWSServer(cfg, &MockAuthManager{})
+	ts := httptest.NewServer(http.HandlerFunc(server.handleWSConnection))
+	defer ts.Close()
+
+	if _, resp, err := dialTest(t, ts); err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Expected 403 before the upgrade, got %v (%v)", resp, err)
+	}
+
+	cfg.AllowCIDRs = []string{"127.0.0.0/8"}
+	cfg.DenyCIDRs = []string{"127.0.0.1"}
+	if _, resp, err := dialTest(t, ts); err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Expected the deny list to win over the allow list, got %v (%v)", resp, err)
+	}
+
+	cfg.DenyCIDRs = nil
+	conn, _, err := dialTest(t, ts)
+	if err != nil {
+		t.Fatalf("Expected an allowed address to connect: %v", err)
+	}
+	defer conn.Close()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/info", nil)
+	req.RemoteAddr = "10.0.0.7:5000"
+	server.restHandler(RESTRoute{Path: "/api/v1/info", Handle: server.restInfo})(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected REST from a refused address to get 403, got %d", rec.Code)
+	}
+}
+
+func TestConnectionLimitsClose(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.MaxConnsPerIP = 1
+	server := NewWSServer(cfg, &MockAuthManager{})
+	ts := httptest.NewServer(http.HandlerFunc(server.handleWSConnection))
+	defer ts.Close()
+
+	first, _, err := dialTest(t, ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+
+	expectClose := func(code int, reason string) {
+		t.Helper()
+		conn, _, err := dialTest(t, ts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		_, _, err = conn.ReadMessage()
+		var ce *websocket.CloseError
+		if !errors.As(err, &ce) || ce.Code != code || ce.Text != reason {
+			t.Fatalf("Expected close %d %q, got %v", code, reason, err)
+		}
+	}
+	expectClose(websocket.ClosePolicyViolation, reasonTooManyFromIP)
+
+	cfg.MaxConnsPerIP = 5
+	cfg.MaxConns = 1
+	expectClose(websocket.CloseTryAgainLater, reasonServerFull)
+}
+
+func TestEventStreamsCountTowardsConnectionLimits(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.MaxConnsPerIP = 1
+	server := NewWSServer(cfg, &MockAuthManager{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", server.handleWSConnection)
+	mux.HandleFunc("/events", server.restEvents)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	stream, err := http.Get(ts.URL + "/events")
+	if err != nil || stream.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the first event stream to open, got %v %v", stream, err)
+	}
+	defer stream.Body.Close()
+
+	conn, _, err := dialTest(t, ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, _, err = conn.ReadMessage()
+	var ce *websocket.CloseError
+	if !errors.As(err, &ce) || ce.Code != websocket.ClosePolicyViolation {
+		t.Fatalf("Expected the WebSocket to be refused while the stream is open, got %v", err)
+	}
+
+	expectStatus := func(want int) {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/events")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("Expected %d for another event stream, got %d", want, resp.StatusCode)
+		}
+	}
+	expectStatus(http.StatusTooManyRequests)
+
+	cfg.MaxConnsPerIP = 5
+	cfg.MaxConns = 1
+	expectStatus(http.StatusServiceUnavailable)
+}
@@ -0,0 +1,198 @@
+package ws
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// outboundBestEffortLimit is the queue length above which best-effort
+	// messages are dropped instead of queued.
+	outboundBestEffortLimit = 256
+	// outboundHardLimit is the queue length at which a client is considered
+	// dead: reliable messages are never dropped, so the connection is closed.
+	outboundHardLimit = 4096
+)
+
+// messageClass decides how an outbound message behaves under backpressure.
+type messageClass int
+
+const (
+	// classReliable covers command replies and alerts. They are always queued.
+	classReliable messageClass = iota
+	// classSnapshot covers periodic state broadcasts. A newer snapshot of
+	// the same type replaces an older one that is still waiting in the queue.
+	classSnapshot
+	// classBestEffort covers broadcasts whose loss is harmless, such as
+	// streamed script output (the final script_execute result carries it in
+	// full).
+	classBestEffort
+)
+
+var snapshotTypes = map[string]bool{
+	"metrics":          true,
+	"media":            true,
+	"clipboard_update": true,
+}
+
+var bestEffortTypes = map[string]bool{
+	"script_output": true,
+}
+
+// classify returns the queueing class for a broadcast of a message type.
+// Replies are always classReliable, whatever their type: the reply to the
+// media command shares its type with media snapshots.
+func classify(msgType string) messageClass {
+	switch {
+	case snapshotTypes[msgType]:
+		return classSnapshot
+	case bestEffortTypes[msgType]:
+		return classBestEffort
+	default:
+		return classReliable
+	}
+}
+
+// QueueStats reports backpressure counters for outbound queues.
+type QueueStats struct {
+	Pending   int    `json:"pending"`
+	Coalesced uint64 `json:"coalesced"`
+	Dropped   uint64 `json:"dropped"`
+}
+
+// queueCounters accumulates coalesce and drop counts across many queues.
+type queueCounters struct {
+	coalesced atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+type outboundItem struct {
+	class   messageClass
+	msgType string
+	payload []byte
+	// sealer, when set, encrypts payload as it leaves the queue.
+	sealer *frameSealer
+}
+
+// outboundQueue is a per-client FIFO that understands message classes.
+// Writers never block; the write pump waits on ready.
+type outboundQueue struct {
+	mu        sync.Mutex
+	items     []outboundItem
+	ready     chan struct{}
+	closed    bool
+	coalesced uint64
+	dropped   uint64
+	// totals, when set, also receives this queue's counters.
+	totals *queueCounters
+}
+
+// newOutboundQueue creates an empty queue.
+func newOutboundQueue() *outboundQueue {
+	return &outboundQueue{
+		ready: make(chan struct{}, 1),
+	}
+}
+
+// push enqueues a message of class, to be sealed by sealer when it is not
+// nil. It returns false if the queue is closed or has reached the hard limit
+// and the client should be disconnected.
+func (q *outboundQueue) push(class messageClass, msgType string, payload []byte, sealer *frameSealer) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return false
+	}
+
+	switch class {
+	case classSnapshot:
+		for i := range q.items {
+			// A snapshot queued under a key that has since been replaced
+			// must still go out under that key, so it is not reused.
+			if q.items[i].class == classSnapshot && q.items[i].msgType == msgType && q.items[i].sealer == sealer {
+				q.items[i].payload = payload
+				q.coalesced++
+				if q.totals != nil {
+					q.totals.coalesced.Add(1)
+				}
+				return true
+			}
+		}
+	case classBestEffort:
+		if len(q.items) >= outboundBestEffortLimit {
+			q.dropped++
+			if q.totals != nil {
+				q.totals.dropped.Add(1)
+			}
+			return true
+		}
+	}
+
+	if len(q.items) >= outboundHardLimit {
+		return false
+	}
+
+	q.items = append(q.items, outboundItem{class: class, msgType: msgType, payload: payload, sealer: sealer})
+	q.signal()
+	return true
+}
+
+// signal wakes the write pump without blocking. Must be called with q.mu held.
+func (q *outboundQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// pop removes the oldest message. ok is false when the queue is empty;
+// closed reports whether the queue has been shut down.
+func (q *outboundQueue) pop() (payload []byte, ok bool, closed bool) {
+	item, ok, closed := q.popItem()
+	return item.payload, ok, closed
+}
+
+// popMessage is pop that also returns the message type, for transports
+// that frame each message themselves.
+func (q *outboundQueue) popMessage() (msgType string, payload []byte, ok bool, closed bool) {
+	item, ok, closed := q.popItem()
+	return item.msgType, item.payload, ok, closed
+}
+
+// popItem is pop that returns the whole entry, sealer included.
+func (q *outboundQueue) popItem() (item outboundItem, ok bool, closed bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) == 0 {
+		return outboundItem{}, false, q.closed
+	}
+	item = q.items[0]
+	q.items[0] = outboundItem{}
+	q.items = q.items[1:]
+	return item, true, q.closed
+}
+
+// close marks the queue as closed and wakes the write pump.
+func (q *outboundQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	q.closed = true
+	q.items = nil
+	q.signal()
+}
+
+// stats returns a snapshot of the queue's counters.
+func (q *outboundQueue) stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return QueueStats{
+		Pending:   len(q.items),
+		Coalesced: q.coalesced,
+		Dropped:   q.dropped,
+	}
+}
@@ -0,0 +1,97 @@
+package ws
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestOutboundQueueCoalescesSnapshots(t *testing.T) {
+	q := newOutboundQueue()
+	q.push(classSnapshot, "metrics", []byte("m1"), nil)
+	q.push(classReliable, "power", []byte("reply"), nil)
+	q.push(classSnapshot, "metrics", []byte("m2"), nil)
+
+	if stats := q.stats(); stats.Pending != 2 || stats.Coalesced != 1 {
+		t.Fatalf("Expected 2 pending and 1 coalesced, got %+v", stats)
+	}
+
+	first, _, _ := q.pop()
+	second, _, _ := q.pop()
+	if string(first) != "m2" || string(second) != "reply" {
+		t.Errorf("Expected latest snapshot in place followed by the reply, got %q, %q", first, second)
+	}
+}
+
+func TestOutboundQueueKeepsReliableMessages(t *testing.T) {
+	q := newOutboundQueue()
+	for i := 0; i < outboundBestEffortLimit; i++ {
+		q.push(classReliable, "script_execute", []byte(strconv.Itoa(i)), nil)
+	}
+
+	// Best-effort traffic is dropped once the soft limit is reached...
+	q.push(classBestEffort, "script_output", []byte("chunk"), nil)
+	// ...but replies and alerts are still queued.
+	if !q.push(classReliable, "battery_alert", []byte("alert"), nil) {
+		t.Fatal("Reliable message should be accepted above the soft limit")
+	}
+
+	stats := q.stats()
+	if stats.Dropped != 1 || stats.Pending != outboundBestEffortLimit+1 {
+		t.Errorf("Expected 1 drop and %d pending, got %+v", outboundBestEffortLimit+1, stats)
+	}
+}
+
+func TestOutboundQueueHardLimit(t *testing.T) {
+	q := newOutboundQueue()
+	for i := 0; i < outboundHardLimit; i++ {
+		q.push(classReliable, "file_read", nil, nil)
+	}
+	if q.push(classReliable, "file_read", nil, nil) {
+		t.Error("Push should fail once the hard limit is reached")
+	}
+}
+
+func TestOutboundQueueClose(t *testing.T) {
+	q := newOutboundQueue()
+	q.push(classReliable, "power", []byte("x"), nil)
+	q.close()
+
+	if _, ok, closed := q.pop(); ok || !closed {
+		t.Error("Closed queue should report closed and hold no messages")
+	}
+	if q.push(classReliable, "power", []byte("y"), nil) {
+		t.Error("Push to a closed queue should fail")
+	}
+}
+
+func TestOutboundTotalsAreShared(t *testing.T) {
+	var totals queueCounters
+	a, b := newOutboundQueue(), newOutboundQueue()
+	a.totals, b.totals = &totals, &totals
+
+	a.push(classSnapshot, "media", nil, nil)
+	a.push(classSnapshot, "media", nil, nil)
+	b.push(classSnapshot, "clipboard_update", nil, nil)
+	b.push(classSnapshot, "clipboard_update", nil, nil)
+
+	if got := totals.coalesced.Load(); got != 2 {
+		t.Errorf("Expected 2 coalesced in totals, got %d", got)
+	}
+}
+
+func TestRepliesAreNotCoalescedWithSnapshots(t *testing.T) {
+	client := NewClient(nil, "127.0.0.1")
+	client.SendEvent("media", map[string]string{"title": "old"})
+	client.ReplySuccess(&ClientMessage{ID: "m1", Type: "media"}, "media", map[string]string{"status": "ok"})
+	client.SendEvent("media", map[string]string{"title": "new"})
+
+	if stats := client.QueueStats(); stats.Pending != 2 || stats.Coalesced != 1 {
+		t.Fatalf("Expected the second snapshot to replace the first only, got %+v", stats)
+	}
+	if resp := readResponse(t, client); resp.ID != "" || resp.Data.(map[string]interface{})["title"] != "new" {
+		t.Errorf("Expected the latest snapshot first, got %+v", resp)
+	}
+	if resp := readResponse(t, client); resp.ID != "m1" {
+		t.Errorf("Expected the reply to be delivered intact, got %+v", resp)
+	}
+}
@@ -0,0 +1,120 @@
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"LinqoraHost/internal/config"
+)
+
+// pendingDecisionRequest is the body of auth_approve / auth_reject, over
+// WebSocket and REST. Scopes and Expires apply to approvals; omitted scopes
+// mean all scopes and an omitted expiry a permanent authorization.
+type pendingDecisionRequest struct {
+	DeviceID string   `json:"deviceId"`
+	Scopes   []string `json:"scopes,omitempty"`
+	Expires  string   `json:"expires,omitempty"`
+}
+
+// scopes validates the requested scopes, defaulting to every scope.
+func (r *pendingDecisionRequest) scopes() ([]string, error) {
+	if r.Scopes == nil {
+		return append([]string(nil), config.AllScopes...), nil
+	}
+	for _, scope := range r.Scopes {
+		if !config.IsValidScope(scope) {
+			return nil, fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	return r.Scopes, nil
+}
+
+// resolvePending applies an approval or rejection made by the admin named by.
+// It returns an HTTP-style status code and message for the caller.
+func (s *WSServer) resolvePending(req pendingDecisionRequest, approve bool, by string) (int, string) {
+	if req.DeviceID == "" {
+		return 400, "deviceId is required"
+	}
+	scopes, err := req.scopes()
+	if err != nil {
+		return 400, err.Error()
+	}
+	expiresAt, err := config.ParseExpiry(req.Expires, time.Now())
+	if err != nil {
+		return 400, err.Error()
+	}
+	if !s.authManager.ResolvePending(req.DeviceID, approve, scopes, expiresAt, by) {
+		return 404, "No pending request for this device"
+	}
+	slog.Info("Pending request resolved remotely", "device_id", req.DeviceID, "approved", approve, "by", by)
+	return 200, ""
+}
+
+// sendToAdmins pushes a message to every connected admin device.
+func (s *WSServer) sendToAdmins(msgType string, data interface{}) {
+	s.clientsMutex.Lock()
+	snapshot := make([]*Client, 0, len(s.clients))
+	for client := range s.clients {
+		if !client.IsClosed() && client.GetDeviceID() != "" {
+			snapshot = append(snapshot, client)
+		}
+	}
+	s.clientsMutex.Unlock()
+
+	for _, client := range snapshot {
+		if s.authManager.IsAdmin(client.GetDeviceID()) {
+			client.SendSuccess(msgType, data)
+		}
+	}
+}
+
+// handlePendingList returns the requests waiting for approval.
+func (s *WSServer) handlePendingList(client *Client, msg *ClientMessage) {
+	client.ReplySuccess(msg, "auth_pending_list", map[string]interface{}{
+		"pending": s.authManager.ListPending(),
+	})
+}
+
+// handlePendingDecision serves auth_approve and auth_reject.
+func (s *WSServer) handlePendingDecision(client *Client, msg *ClientMessage) {
+	var req pendingDecisionRequest
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		client.ReplyError(msg, "Invalid format", 400)
+		return
+	}
+
+	by := "device:" + client.GetDeviceName()
+	if status, message := s.resolvePending(req, msg.Type == "auth_approve", by); status != 200 {
+		client.ReplyError(msg, message, status)
+		return
+	}
+	client.ReplySuccess(msg, msg.Type, map[string]string{"deviceId": req.DeviceID})
+}
+
+// restPendingList handles GET /api/v1/auth/pending.
+func (s *WSServer) restPendingList(w http.ResponseWriter, r *http.Request) {
+	restWriteJSON(w, http.StatusOK, map[string]interface{}{
+		"pending": s.authManager.ListPending(),
+	})
+}
+
+// restPendingDecision handles POST /api/v1/auth/approve and /api/v1/auth/reject.
+func (s *WSServer) restPendingDecision(w http.ResponseWriter, r *http.Request) {
+	var req pendingDecisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		restWriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid body"})
+		return
+	}
+
+	by := "token:" + restCallerFrom(r).token
+	approve := strings.HasSuffix(r.URL.Path, "/approve")
+	if status, message := s.resolvePending(req, approve, by); status != 200 {
+		restWriteJSON(w, status, map[string]string{"error": message})
+		return
+	}
+	restWriteJSON(w, http.StatusOK, map[string]interface{}{"deviceId": req.DeviceID, "approved": approve})
+}
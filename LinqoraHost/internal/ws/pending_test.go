@@ -0,0 +1,122 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"LinqoraHost/internal/auth"
+	"LinqoraHost/internal/config"
+	"LinqoraHost/internal/interfaces"
+)
+
+func TestAdminDevicesResolvePendingRequests(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	cfg := config.DefaultConfig()
+	cfg.AuthorizedDevs["admin-phone"] = config.DeviceAuth{DeviceID: "admin-phone", Scopes: config.AllScopes, Admin: true}
+	cfg.AuthorizedDevs["plain-phone"] = config.DeviceAuth{DeviceID: "plain-phone", Scopes: config.AllScopes}
+	am := auth.NewAuthManager(cfg, make(chan interfaces.PendingAuthRequest, 1))
+	server := NewWSServer(cfg, am)
+	am.SetEventBus(server.Events())
+
+	admin := NewClient(nil, "127.0.0.1")
+	admin.SetDeviceID("admin-phone")
+	plain := NewClient(nil, "127.0.0.1")
+	plain.SetDeviceID("plain-phone")
+	server.clients[admin] = true
+	server.clients[plain] = true
+
+	am.RequestAuthorization("New Phone", "new-phone", "10.0.0.5")
+
+	// The push is published asynchronously.
+	deadline := time.Now().Add(2 * time.Second)
+	for admin.queue.stats().Pending == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if resp := readResponse(t, admin); resp.Type != "auth_pending_request" {
+		t.Fatalf("Expected an auth_pending_request push, got %s", resp.Type)
+	}
+	if _, ok, _ := plain.queue.pop(); ok {
+		t.Error("Non-admin devices must not receive the push")
+	}
+
+	server.handleClientMessage(plain, &ClientMessage{Type: "auth_pending_list"})
+	if resp := readResponse(t, plain); resp.Error == nil || *resp.Error.Code != 403 {
+		t.Fatalf("Expected 403 for a non-admin device, got %+v", resp)
+	}
+
+	server.handleClientMessage(admin, &ClientMessage{Type: "auth_pending_list"})
+	if resp := readResponse(t, admin); resp.Error != nil {
+		t.Fatalf("Expected the pending list, got %+v", resp.Error)
+	}
+
+	bad, _ := json.Marshal(pendingDecisionRequest{DeviceID: "new-phone", Expires: "soon"})
+	server.handleClientMessage(admin, &ClientMessage{Type: "auth_approve", Data: bad})
+	if resp := readResponse(t, admin); resp.Error == nil || *resp.Error.Code != 400 {
+		t.Fatalf("Expected 400 for an invalid expiry, got %+v", resp)
+	}
+
+	data, _ := json.Marshal(pendingDecisionRequest{DeviceID: "new-phone", Scopes: []string{config.ScopeMedia}, Expires: "1h"})
+	server.handleClientMessage(admin, &ClientMessage{ID: "a1", Type: "auth_approve", Data: data})
+
+	// The decision is pushed to admins before the reply is sent.
+	if resp := readResponse(t, admin); resp.Type != "auth_decision" {
+		t.Fatalf("Expected an auth_decision push, got %s", resp.Type)
+	}
+	if resp := readResponse(t, admin); resp.ID != "a1" || resp.Error != nil {
+		t.Fatalf("Expected a successful reply, got %+v", resp)
+	}
+	if !am.HasScope("new-phone", config.ScopeMedia) || am.HasScope("new-phone", config.ScopeShell) {
+		t.Error("Expected the approved device to hold only the media scope")
+	}
+	if until := cfg.AuthorizedDevs["new-phone"].ExpiresAt; until == nil || time.Until(*until) > time.Hour {
+		t.Errorf("Expected the approval to expire within an hour, got %v", until)
+	}
+
+	server.handleClientMessage(admin, &ClientMessage{Type: "auth_reject", Data: data})
+	if resp := readResponse(t, admin); resp.Error == nil || *resp.Error.Code != 404 {
+		t.Errorf("Expected 404 once nothing is pending, got %+v", resp)
+	}
+}
+
+func TestRESTPendingRequiresAdminToken(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.SharedSecret = "secret"
+	server := NewWSServer(cfg, &tokenAuthManager{token: "lqt_admin", scopes: []string{config.ScopeAdmin}})
+	var approve RESTRoute
+	for _, route := range server.registry.Routes() {
+		if route.Path == "/api/v1/auth/approve" {
+			approve = route
+		}
+	}
+	ts := httptest.NewServer(server.restHandler(approve))
+	defer ts.Close()
+
+	call := func(credential string) int {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(`{"deviceId":"new-phone"}`))
+		if credential != "" {
+			req.Header.Set("Authorization", "Bearer "+credential)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := call(""); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without credentials, got %d", code)
+	}
+	if code := call("secret"); code != http.StatusForbidden {
+		t.Errorf("Expected the shared secret to be refused with 403, got %d", code)
+	}
+	// The token passes; nothing is pending, so the handler answers 404.
+	if code := call("lqt_admin"); code != http.StatusNotFound {
+		t.Errorf("Expected an admin token to reach the handler, got %d", code)
+	}
+}
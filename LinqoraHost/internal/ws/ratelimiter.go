@@ -39,6 +39,12 @@ func newClientRateLimiter() *clientRateLimiter {
 
 // Allow checks if a message should be permitted, consuming one token if available.
 func (r *clientRateLimiter) Allow() bool {
+	return r.AllowN(1)
+}
+
+// AllowN checks if a message costing n tokens should be permitted, consuming
+// the tokens only when enough are available.
+func (r *clientRateLimiter) AllowN(n int) bool {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -51,9 +57,9 @@ func (r *clientRateLimiter) Allow() bool {
 		r.tokens = r.capacity
 	}
 
-	if r.tokens < 1.0 {
+	if r.tokens < float64(n) {
 		return false
 	}
-	r.tokens--
+	r.tokens -= float64(n)
 	return true
 }
@@ -45,3 +45,14 @@ func TestRateLimiterRefillsOverTime(t *testing.T) {
 		t.Fatal("Allow should succeed after waiting for token refill")
 	}
 }
+
+func TestRateLimiterAllowNConsumesCost(t *testing.T) {
+	rl := newClientRateLimiter()
+
+	if !rl.AllowN(rateLimitBurst) {
+		t.Fatal("AllowN should accept a cost equal to the burst size")
+	}
+	if rl.AllowN(10) {
+		t.Fatal("AllowN should reject a cost larger than the remaining tokens")
+	}
+}
@@ -0,0 +1,151 @@
+package ws
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// HandlerFunc processes a single message received from a client.
+type HandlerFunc func(client *Client, msg *ClientMessage)
+
+// Handler describes a WebSocket message type and the policy the dispatcher
+// applies before invoking it.
+type Handler struct {
+	// Type is the message type this handler responds to.
+	Type string
+	// Handle is invoked once all checks have passed.
+	Handle HandlerFunc
+	// AuthExempt allows the message before the device is authorised.
+	AuthExempt bool
+	// Room, when set, requires the client to have joined that room.
+	Room string
+	// Scope, when set, requires the device to have been granted that
+	// permission scope (see config.AllScopes).
+	Scope string
+	// Admin, when set, requires a device the operator has marked as admin.
+	Admin bool
+	// Confirm names the action class (see config.ConfirmActions) of a
+	// destructive message. When the operator guards that class, the message
+	// waits until someone at the host allows it.
+	Confirm string
+	// StepUp requires a fresh step-up whatever config.StepUpTypes says. A
+	// device with nothing to step up with waits for the host instead, as if
+	// Confirm were guarded.
+	StepUp bool
+	// Cost is the number of rate-limit tokens consumed per message.
+	// Values below 1 are treated as 1.
+	Cost int
+	// RateLimitExempt skips the per-client token bucket entirely.
+	RateLimitExempt bool
+	// Async runs Handle in its own goroutine so long-running work does not
+	// block the client's read pump.
+	Async bool
+	// NoAudit keeps high-frequency messages (pings, pointer movement) out of
+	// the audit log, where they would drown everything else.
+	NoAudit bool
+}
+
+// cost returns the effective rate-limit cost of the handler.
+func (h *Handler) cost() int {
+	if h.Cost < 1 {
+		return 1
+	}
+	return h.Cost
+}
+
+// RESTRoute describes an HTTP endpoint served next to the WebSocket.
+// The server wraps Handle with bearer-token authentication and a method check.
+type RESTRoute struct {
+	Path   string
+	Method string
+	Handle http.HandlerFunc
+	// Scope, when set, is required of API tokens calling the route. The
+	// shared secret is not limited by scopes.
+	Scope string
+	// TokenOnly refuses the shared secret, so that only an API token that
+	// holds Scope can call the route.
+	TokenOnly bool
+	// Confirm is the action class of the route, as in Handler.Confirm.
+	Confirm string
+}
+
+// Registry holds the message handlers and REST routes known to the server.
+// Features register themselves at startup, before the server is started.
+type Registry struct {
+	mu       sync.RWMutex
+	handlers map[string]*Handler
+	routes   []RESTRoute
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		handlers: make(map[string]*Handler),
+	}
+}
+
+// Register adds a message handler. Like http.ServeMux it panics on
+// programming errors: an empty type, a nil function or a duplicate type.
+func (r *Registry) Register(h Handler) {
+	if h.Type == "" || h.Handle == nil {
+		panic("ws: handler requires a type and a function")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.handlers[h.Type]; exists {
+		panic(fmt.Sprintf("ws: handler for %q already registered", h.Type))
+	}
+	r.handlers[h.Type] = &h
+}
+
+// RegisterREST adds an HTTP route. It panics if the path is already taken.
+func (r *Registry) RegisterREST(route RESTRoute) {
+	if route.Path == "" || route.Handle == nil {
+		panic("ws: REST route requires a path and a function")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.routes {
+		if existing.Path == route.Path {
+			panic(fmt.Sprintf("ws: REST route %q already registered", route.Path))
+		}
+	}
+	r.routes = append(r.routes, route)
+}
+
+// Lookup returns the handler registered for a message type.
+func (r *Registry) Lookup(msgType string) (*Handler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	h, ok := r.handlers[msgType]
+	return h, ok
+}
+
+// Types returns the registered message types in sorted order.
+func (r *Registry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]string, 0, len(r.handlers))
+	for t := range r.handlers {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Routes returns a copy of the registered REST routes.
+func (r *Registry) Routes() []RESTRoute {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]RESTRoute, len(r.routes))
+	copy(out, r.routes)
+	return out
+}
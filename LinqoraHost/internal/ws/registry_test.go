@@ -0,0 +1,77 @@
+package ws
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"LinqoraHost/internal/config"
+)
+
+func TestRegistryRegisterAndLookup(t *testing.T) {
+	r := NewRegistry()
+	r.Register(Handler{Type: "b", Handle: func(*Client, *ClientMessage) {}})
+	r.Register(Handler{Type: "a", Handle: func(*Client, *ClientMessage) {}, Cost: 3})
+
+	h, ok := r.Lookup("a")
+	if !ok || h.cost() != 3 {
+		t.Fatalf("Expected handler a with cost 3, got %v %v", h, ok)
+	}
+	if h, _ := r.Lookup("b"); h.cost() != 1 {
+		t.Errorf("Expected default cost 1, got %d", h.cost())
+	}
+	if _, ok := r.Lookup("missing"); ok {
+		t.Error("Lookup of unregistered type should fail")
+	}
+	if got := r.Types(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("Expected sorted types [a b], got %v", got)
+	}
+}
+
+func TestRegistryDuplicatePanics(t *testing.T) {
+	r := NewRegistry()
+	r.Register(Handler{Type: "x", Handle: func(*Client, *ClientMessage) {}})
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Registering a duplicate type should panic")
+		}
+	}()
+	r.Register(Handler{Type: "x", Handle: func(*Client, *ClientMessage) {}})
+}
+
+func TestRegistryRoutes(t *testing.T) {
+	r := NewRegistry()
+	r.RegisterREST(RESTRoute{Path: "/api/v1/x", Method: http.MethodGet, Handle: func(http.ResponseWriter, *http.Request) {}})
+
+	if routes := r.Routes(); len(routes) != 1 || routes[0].Path != "/api/v1/x" {
+		t.Errorf("Unexpected routes: %v", routes)
+	}
+}
+
+func TestDispatchExternalHandler(t *testing.T) {
+	server := NewWSServer(config.DefaultConfig(), &MockAuthManager{})
+	client := NewClient(nil, "127.0.0.1")
+
+	called := false
+	server.Registry().Register(Handler{Type: "custom", Handle: func(c *Client, msg *ClientMessage) {
+		called = true
+	}})
+
+	server.handleClientMessage(client, &ClientMessage{Type: "custom"})
+	if !called {
+		t.Error("Externally registered handler was not dispatched")
+	}
+}
+
+func TestDispatchRequiresRoom(t *testing.T) {
+	server := NewWSServer(config.DefaultConfig(), &MockAuthManager{})
+	client := NewClient(nil, "127.0.0.1")
+
+	server.handleClientMessage(client, &ClientMessage{ID: "m1", Type: "media"})
+
+	resp := readResponse(t, client)
+	if resp.Error == nil || *resp.Error.Code != 403 || resp.ID != "m1" {
+		t.Errorf("Expected 403 for media outside the media room, got %+v", resp)
+	}
+}
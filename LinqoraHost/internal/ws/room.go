@@ -1,7 +1,6 @@
 package ws
 
 import (
-	"encoding/json"
 	"log/slog"
 	"sync"
 )
@@ -11,6 +10,8 @@ type Room struct {
 	Name    string
 	Clients map[*Client]bool
 	mu      sync.Mutex
+	// deliver, when set, decides whether a member receives a broadcast.
+	deliver func(*Client) bool
 }
 
 // NewRoom initialises a new room with the specified name.
@@ -39,13 +40,7 @@ func (r *Room) RemoveClient(client *Client) {
 // SendToAllClients broadcasts a message to all subscribers in the room, optionally excluding one.
 // The implementation uses a client list snapshot to avoid holding the lock during network I/O.
 func (r *Room) SendToAllClients(messageType string, message interface{}, excludeClient *Client) {
-	// Serialise once — all clients receive the same bytes.
 	successResponse := NewSuccessResponse(messageType, message)
-	jsonMsg, err := json.Marshal(successResponse)
-	if err != nil {
-		slog.Error("Error marshaling broadcast message", "room", r.Name, "err", err)
-		return
-	}
 
 	// Build a snapshot of active clients under a short lock.
 	r.mu.Lock()
@@ -57,9 +52,26 @@ func (r *Room) SendToAllClients(messageType string, message interface{}, exclude
 	}
 	r.mu.Unlock()
 
+	// Serialise once per encoding — clients sharing a codec receive the same bytes.
+	encoded := make(map[string][]byte)
+
 	// Send without holding the lock so that join/leave are not blocked.
 	for _, client := range snapshot {
-		client.sendMessage(jsonMsg)
+		if r.deliver != nil && !r.deliver(client) {
+			continue
+		}
+		codec := client.Codec()
+		payload, ok := encoded[codec.Name()]
+		if !ok {
+			var err error
+			payload, err = codec.Marshal(successResponse)
+			if err != nil {
+				slog.Error("Error marshaling broadcast message", "room", r.Name, "codec", codec.Name(), "err", err)
+				continue
+			}
+			encoded[codec.Name()] = payload
+		}
+		client.sendEvent(messageType, payload)
 	}
 }
 
@@ -16,6 +16,8 @@ type RoomManager struct {
 	Rooms     map[string]*Room
 	mu        sync.Mutex
 	listeners []RoomListener
+	// deliver, when set, decides whether a member receives a broadcast.
+	deliver func(roomName string, client *Client) bool
 }
 
 // NewRoomManager creates a new RoomManager instance.
@@ -33,6 +35,15 @@ func (rm *RoomManager) AddRoomListener(listener RoomListener) {
 	rm.listeners = append(rm.listeners, listener)
 }
 
+// SetDeliveryCheck makes rooms skip members for which deliver returns false
+// when broadcasting, such as a device that lost the scope the room needs
+// after joining it. It must be called before any room is created.
+func (rm *RoomManager) SetDeliveryCheck(deliver func(roomName string, client *Client) bool) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.deliver = deliver
+}
+
 // notifyFirstClientJoined alerts listeners that a room has gained its first client.
 func (rm *RoomManager) notifyFirstClientJoined(roomName string) {
 	for _, listener := range rm.listeners {
@@ -57,6 +68,9 @@ func (rm *RoomManager) GetOrCreateRoom(roomName string) *Room {
 	}
 
 	room := NewRoom(roomName)
+	if deliver := rm.deliver; deliver != nil {
+		room.deliver = func(client *Client) bool { return deliver(roomName, client) }
+	}
 	rm.Rooms[roomName] = room
 	return room
 }
@@ -2,7 +2,9 @@ package ws
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
@@ -11,14 +13,21 @@ import (
 	"runtime"
 	"strings"
 	"sync"
-	"syscall"
+	"sync/atomic"
 	"time"
 
+	"LinqoraHost/internal/audit"
+	"LinqoraHost/internal/auth"
+	"LinqoraHost/internal/ca"
 	"LinqoraHost/internal/capabilities"
+	"LinqoraHost/internal/certutils"
 	"LinqoraHost/internal/clipboard"
 	"LinqoraHost/internal/collectors"
 	"LinqoraHost/internal/config"
+	"LinqoraHost/internal/confirm"
 	"LinqoraHost/internal/deviceinfo"
+	"LinqoraHost/internal/events"
+	"LinqoraHost/internal/execpolicy"
 	"LinqoraHost/internal/filebrowser"
 	"LinqoraHost/internal/keyboard"
 	"LinqoraHost/internal/media"
@@ -41,16 +50,26 @@ import (
 // WSServer represents the primary WebSocket server coordinating communication
 // between the host and remote clients.
 type WSServer struct {
-	config                *config.ServerConfig
+	config                atomic.Pointer[config.ServerConfig]
 	httpServer            *http.Server
 	roomManager           *RoomManager
 	broadcaster           *Broadcaster
+	bus                   *events.Bus
+	registry              *Registry
+	sessions              *SessionStore
+	blocks                *deviceBlocks
+	confirmations         *confirm.Broker
 	clients               map[*Client]bool
+	eventStreams          map[*Client]bool
 	clientsMutex          sync.Mutex
 	upgrader              websocket.Upgrader
 	authManager           interfaces.AuthManagerInterface
 	scriptManager         *scheduler.Manager
+	auditLog              atomic.Pointer[audit.Log]
+	ca                    *ca.Authority
+	certs                 atomic.Pointer[certutils.CertReloader]
 	batteryAlertCollector *collectors.BatteryAlertCollector
+	outboundTotals        queueCounters
 	ctx                   context.Context
 	cancel                context.CancelFunc
 }
@@ -61,12 +80,18 @@ func NewWSServer(config *config.ServerConfig, authManager interfaces.AuthManager
 	roomManager := NewRoomManager()
 
 	server := &WSServer{
-		config:        config,
 		roomManager:   roomManager,
+		bus:           events.NewBus(),
+		registry:      NewRegistry(),
+		sessions:      NewSessionStore(),
+		blocks:        newDeviceBlocks(),
+		confirmations: confirm.NewBroker(),
 		clients:       make(map[*Client]bool),
+		eventStreams:  make(map[*Client]bool),
 		authManager:   authManager,
 		scriptManager: scheduler.NewManager(scheduler.DefaultScriptsPath()),
 		upgrader: websocket.Upgrader{
+			Subprotocols: Subprotocols(),
 			CheckOrigin: func(r *http.Request) bool {
 				// Native clients (mobile app) send no Origin header.
 				// Browsers always set Origin, so rejecting non-empty Origin blocks
@@ -78,68 +103,190 @@ func NewWSServer(config *config.ServerConfig, authManager interfaces.AuthManager
 		cancel: cancel,
 	}
 
+	server.config.Store(config)
+	// A device keeps its rooms if a scope is taken away, but stops
+	// receiving what the scope guards.
+	roomManager.SetDeliveryCheck(func(room string, client *Client) bool {
+		return server.mayReceive(client, roomScopes[room])
+	})
 	server.scriptManager.SeedDefaults()
+	server.scriptManager.SetGuard(server.scriptGuard)
+	if err := config.ExecPolicy.Validate(); err != nil {
+		slog.Warn("Exec policy has mistakes; commands reaching a broken rule are refused", "err", err)
+	}
 
-	broadcaster := NewBroadcaster(roomManager)
-	server.broadcaster = broadcaster
+	server.registerBuiltinHandlers()
+	server.registerBuiltinRoutes()
 
-	// Initialise collectors
-	metricsCollector := collectors.NewMetricsCollector(broadcaster.GetMetricsBroadcaster())
-	mediaCollector := collectors.NewMediaCollector(broadcaster.GetMediaBroadcaster())
-	clipboardCollector := collectors.NewClipboardCollector(broadcaster.GetClipboardBroadcaster())
+	// Room-scoped events go to their rooms, server-wide events to everyone.
+	server.broadcaster = NewBroadcaster(roomManager, server.bus)
+	server.subscribeServerEvents()
 
-	// Register collector manager
-	collectorManager := collectors.NewCollectorManager(metricsCollector, mediaCollector, clipboardCollector)
+	// Initialise collectors; the collector manager starts and stops them
+	// as their rooms gain and lose members.
+	collectorManager := collectors.NewCollectorManager(
+		collectors.NewMetricsCollector(server.bus),
+		collectors.NewMediaCollector(server.bus),
+		collectors.NewClipboardCollector(server.bus),
+	)
 	roomManager.AddRoomListener(collectorManager)
 
 	// Initialise and start the battery alert collector (always active, not room-based).
-	batteryAlertCollector := collectors.NewBatteryAlertCollector(server.broadcastToAll)
+	batteryAlertCollector := collectors.NewBatteryAlertCollector(server.bus)
 	server.batteryAlertCollector = batteryAlertCollector
 	batteryAlertCollector.Start()
 
 	// Start inactivity monitoring
 	server.StartInactiveClientsMonitor()
 
+	// Revoke and disconnect guests whose authorization has expired
+	server.StartExpirySweeper()
+
 	// Start the lock-state monitor
-	power.StartLockStateMonitor(ctx)
+	power.StartLockStateMonitor(ctx, server.bus)
 
-	// Start cron loop: fires scheduled scripts and broadcasts results to all clients.
-	server.scriptManager.StartCronLoop(ctx, func(scriptID string) {
-		slog.Info("Cron trigger", "script", scriptID)
-		result, err := server.scriptManager.Execute(scriptID, func(chunk scheduler.OutputChunk) {
-			server.broadcastToAll("script_output", chunk)
-		})
-		if err != nil {
-			slog.Error("Scheduled script failed", "script", scriptID, "err", err)
-			return
+	// Start cron loop: scheduled script output and results are published to the bus.
+	server.scriptManager.StartCronLoop(ctx, server.bus)
+
+	return server
+}
+
+// DeviceConnection reports an authorised device connecting or disconnecting.
+type DeviceConnection struct {
+	DeviceID   string `json:"deviceId"`
+	DeviceName string `json:"deviceName"`
+	IP         string `json:"ip"`
+	Connected  bool   `json:"connected"`
+	// Resumed is set when the connection resumed an earlier session.
+	Resumed bool `json:"resumed,omitempty"`
+}
+
+// DeviceConnectionTopic carries every authorised connect and disconnect.
+var DeviceConnectionTopic = events.NewTopic[DeviceConnection]("device_connection")
+
+// Config returns the configuration the server currently runs with. It is
+// replaced as a whole by UseConfig, never modified in place, so a caller
+// should load it once per use.
+func (s *WSServer) Config() *config.ServerConfig {
+	return s.config.Load()
+}
+
+// UseConfig makes fresh the configuration the server runs with, after the
+// auth manager has adopted it with Reload.
+func (s *WSServer) UseConfig(fresh *config.ServerConfig) {
+	s.config.Store(fresh)
+}
+
+// Events returns the server's event bus. Other packages publish to it (for
+// example auth decisions) or subscribe to it before Start is called.
+func (s *WSServer) Events() *events.Bus {
+	return s.bus
+}
+
+// subscribeServerEvents forwards events that concern every client, rather
+// than the members of one room, to all connections.
+func (s *WSServer) subscribeServerEvents() {
+	events.Subscribe(s.bus, collectors.BatteryAlertTopic, func(alert collectors.BatteryAlert) {
+		s.broadcastToAll("battery_alert", alert)
+	})
+	events.Subscribe(s.bus, power.LockStateTopic, func(state power.LockState) {
+		s.broadcastToAll("lock_state", state)
+	})
+	events.Subscribe(s.bus, scheduler.ScriptOutputTopic, func(chunk scheduler.OutputChunk) {
+		s.broadcastToAll("script_output", chunk)
+	})
+	events.Subscribe(s.bus, scheduler.ScriptResultTopic, func(result scheduler.ScriptResult) {
+		// Interactive runs are answered to the requester directly.
+		if result.Trigger == scheduler.TriggerSchedule {
+			s.broadcastToAll("script_execute", result)
 		}
-		server.broadcastToAll("script_execute", map[string]interface{}{
-			"id":          result.ID,
-			"exit_code":   result.ExitCode,
-			"stdout":      result.Stdout,
-			"stderr":      result.Stderr,
-			"duration_ms": result.Duration,
-			"triggered":   "schedule",
-		})
 	})
+	events.Subscribe(s.bus, auth.PendingRequestTopic, func(request interfaces.PendingAuthRequest) {
+		s.sendToAdmins("auth_pending_request", request)
+	})
+	events.Subscribe(s.bus, auth.DecisionTopic, func(decision auth.Decision) {
+		s.sendToAdmins("auth_decision", decision)
+	})
+	events.Subscribe(s.bus, auth.LockoutTopic, func(lockout auth.Lockout) {
+		s.sendToAdmins("auth_lockout", lockout)
+	})
+}
 
-	return server
+// Registry exposes the handler registry so that features living in other
+// packages can add message types and REST routes before Start is called.
+func (s *WSServer) Registry() *Registry {
+	return s.registry
+}
+
+// serverEventScopes maps server-wide events that carry sensitive data to
+// the scope a client needs to receive them.
+var serverEventScopes = map[string]string{
+	"script_output":  config.ScopeScripts,
+	"script_execute": config.ScopeScripts,
 }
 
-// broadcastToAll sends a message to every connected client regardless of room membership.
+// mayReceive reports whether client holds scope, through its device or, for
+// an event stream, the credential that opened it.
+func (s *WSServer) mayReceive(client *Client, scope string) bool {
+	if scope == "" {
+		return true
+	}
+	if client.streamCaller != nil {
+		return client.streamCaller.allowed(scope)
+	}
+	return s.authManager.HasScope(client.GetDeviceID(), scope)
+}
+
+// broadcastToAll sends a message to every connected client and event stream
+// regardless of room membership, skipping those without the scope the
+// message type requires.
 func (s *WSServer) broadcastToAll(msgType string, data interface{}) {
+	scope := serverEventScopes[msgType]
+
 	s.clientsMutex.Lock()
-	snapshot := make([]*Client, 0, len(s.clients))
+	snapshot := make([]*Client, 0, len(s.clients)+len(s.eventStreams))
 	for client := range s.clients {
 		if !client.IsClosed() {
 			snapshot = append(snapshot, client)
 		}
 	}
+	for stream := range s.eventStreams {
+		if !stream.IsClosed() {
+			snapshot = append(snapshot, stream)
+		}
+	}
 	s.clientsMutex.Unlock()
 
 	for _, client := range snapshot {
-		client.SendSuccess(msgType, data) //nolint:errcheck
+		if s.mayReceive(client, scope) {
+			client.SendEvent(msgType, data) //nolint:errcheck
+		}
 	}
+
+	// Devices that dropped off recently get the event when they resume.
+	s.sessions.Buffer(NewSuccessResponse(msgType, data), func(deviceID string) bool {
+		return scope == "" || s.authManager.HasScope(deviceID, scope)
+	})
+}
+
+// OutboundStats returns server-wide outbound queue counters: messages
+// currently pending across all clients, and snapshots coalesced or
+// best-effort messages dropped since the server started.
+func (s *WSServer) OutboundStats() QueueStats {
+	stats := QueueStats{
+		Coalesced: s.outboundTotals.coalesced.Load(),
+		Dropped:   s.outboundTotals.dropped.Load(),
+	}
+
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+	for client := range s.clients {
+		stats.Pending += client.QueueStats().Pending
+	}
+	for stream := range s.eventStreams {
+		stats.Pending += stream.QueueStats().Pending
+	}
+	return stats
 }
 
 // Start begins listening for incoming WebSocket connections.
@@ -150,33 +297,33 @@ func (s *WSServer) Start(parentCtx context.Context) error {
 	})
 
 	// REST API endpoints.
-	mux.HandleFunc("/api/v1/info", s.restInfo)
-	mux.HandleFunc("/api/v1/processes", s.restProcesses)
-	mux.HandleFunc("/api/v1/processes/kill", s.restKillProcess)
-	mux.HandleFunc("/api/v1/qr", s.restQR)
-	mux.HandleFunc("/api/v1/metrics", s.restMetrics)
-	mux.HandleFunc("/api/v1/scripts", s.restScripts)
-	mux.HandleFunc("/api/v1/scripts/execute", s.restScriptExecute)
-	mux.HandleFunc("/api/v1/media", s.restMedia)
-	mux.HandleFunc("/api/v1/power", s.restPower)
-	mux.HandleFunc("/api/v1/keyboard/type", s.restKeyboardType)
+	for _, route := range s.registry.Routes() {
+		mux.HandleFunc(route.Path, s.restHandler(route))
+	}
 
+	cfg := s.Config()
 	s.httpServer = &http.Server{
-		Addr:    fmt.Sprintf(":%d", s.config.Port),
-		Handler: mux,
+		Addr:      fmt.Sprintf(":%d", cfg.Port),
+		Handler:   mux,
+		TLSConfig: s.clientCertTLSConfig(),
+	}
+	if cfg.EnableTLS {
+		reloader, err := s.startCertReloader()
+		if err != nil {
+			return err
+		}
+		s.httpServer.TLSConfig = s.tlsConfig(reloader)
 	}
 
 	serverErr := make(chan error, 1)
 
 	go func() {
 		var err error
-		slog.Info("WebSocket server started", "port", s.config.Port)
+		slog.Info("WebSocket server started", "port", cfg.Port)
 
-		if s.config.EnableTLS {
-			err = s.httpServer.ListenAndServeTLS(
-				s.config.CertFile,
-				s.config.KeyFile,
-			)
+		if cfg.EnableTLS {
+			// The certificate comes from TLSConfig.GetCertificate.
+			err = s.httpServer.ListenAndServeTLS("", "")
 		} else {
 			err = s.httpServer.ListenAndServe()
 		}
@@ -217,6 +364,10 @@ func (s *WSServer) Shutdown() error {
 		client.Conn.Close()
 		delete(s.clients, client)
 	}
+	// Event streams end once their queue is closed; the handler cleans up.
+	for stream := range s.eventStreams {
+		stream.Close()
+	}
 	s.clientsMutex.Unlock()
 
 	if err := s.httpServer.Shutdown(ctx); err != nil {
@@ -238,6 +389,9 @@ func (s *WSServer) StopServer() error {
 // handleWSConnection upgrades an HTTP connection to a WebSocket connection.
 func (s *WSServer) handleWSConnection(w http.ResponseWriter, r *http.Request) {
 	slog.Info("WebSocket connection attempt", "remote_addr", r.RemoteAddr)
+	if !s.allowRemote(w, r) {
+		return
+	}
 
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -246,23 +400,45 @@ func (s *WSServer) handleWSConnection(w http.ResponseWriter, r *http.Request) {
 	}
 
 	client := NewClient(conn, r.RemoteAddr)
-	if s.config.EnableE2EE && s.config.SharedSecret != "" {
-		client.SetE2EEKey(DeriveKey(s.config.SharedSecret))
+	client.certDeviceID = s.certificateDevice(r)
+	client.SetCodec(CodecForSubprotocol(conn.Subprotocol()))
+	client.queue.totals = &s.outboundTotals
+	client.sessions = s.sessions
+	client.onAuthorized = s.onClientAuthorized
+	if cfg := s.Config(); cfg.EnableE2EE && cfg.SharedSecret != "" {
+		client.SetE2EEKey(DeriveKey(cfg.SharedSecret))
 	}
 
-	s.clientsMutex.Lock()
-	s.clients[client] = true
-	s.clientsMutex.Unlock()
+	if code, reason, ok := s.admitClient(client); !ok {
+		slog.Warn("Connection refused", "remote_addr", r.RemoteAddr, "reason", reason)
+		closeConn(client, code, reason)
+		return
+	}
 
 	go client.StartWritePump()
 	go client.StartReadPump(func(msg *ClientMessage) {
 		s.handleClientMessage(client, msg)
 	}, func() {
-		s.removeClient(client)
-		s.roomManager.RemoveClientFromAllRooms(client)
+		s.disconnectClient(client)
 	})
 }
 
+// disconnectClient unregisters a client and leaves its rooms. The client's
+// session, if any, is detached first so that it remembers those rooms.
+func (s *WSServer) disconnectClient(client *Client) {
+	detached := s.sessions.Detach(client, client.RoomNames())
+	s.removeClient(client)
+	s.roomManager.RemoveClientFromAllRooms(client)
+
+	if detached {
+		events.Publish(s.bus, DeviceConnectionTopic, DeviceConnection{
+			DeviceID:   client.GetDeviceID(),
+			DeviceName: client.GetDeviceName(),
+			IP:         client.GetIP(),
+		})
+	}
+}
+
 // removeClient cleans up client resources and removes them from the server registry.
 func (s *WSServer) removeClient(client *Client) {
 	if client == nil {
@@ -289,6 +465,9 @@ func (s *WSServer) StartInactiveClientsMonitor() {
 			select {
 			case <-ticker.C:
 				s.checkInactiveClients()
+				if purged := s.sessions.PurgeExpired(); purged > 0 {
+					slog.Info("Expired detached sessions", "count", purged)
+				}
 			case <-s.ctx.Done():
 				return
 			}
@@ -310,130 +489,286 @@ func (s *WSServer) checkInactiveClients() {
 
 	for _, client := range inactiveClients {
 		slog.Info("Disconnecting inactive client (no PING for over 2 minutes)", "device", client.DeviceName)
+		closeConn(client, websocket.CloseGoingAway, "inactive client timeout (no PING)")
+		s.disconnectClient(client)
+	}
+}
 
-		closeMsg := websocket.FormatCloseMessage(
-			websocket.CloseGoingAway,
-			"inactive client timeout (no PING)",
-		)
-		client.Conn.WriteControl(
-			websocket.CloseMessage,
-			closeMsg,
-			time.Now().Add(time.Second),
-		)
-
-		client.Conn.Close()
-		s.removeClient(client)
-		s.roomManager.RemoveClientFromAllRooms(client)
+// closeConn sends a close frame with code and reason, then closes the socket.
+func closeConn(client *Client, code int, reason string) {
+	if client.Conn == nil {
+		return
 	}
+	client.Conn.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, reason),
+		time.Now().Add(time.Second),
+	)
+	client.Conn.Close()
 }
 
-// handleClientMessage routes incoming client messages to appropriate handlers.
+// handleClientMessage dispatches an incoming message through the handler
+// registry, enforcing rate limits, authorisation and room membership first.
 func (s *WSServer) handleClientMessage(client *Client, msg *ClientMessage) {
-	// Skip authorization check for authentication-related messages
-	authExempt := map[string]bool{
-		"auth_request":            true,
-		"auth_check":              true,
-		"ping":                    true,
-		"auth_challenge_response": true,
+	client.received.Add(1)
+	handler, known := s.registry.Lookup(msg.Type)
+
+	var record *auditRecord
+	if known {
+		record = s.beginAudit(client, msg, handler)
+		defer record.end()
 	}
 
-	if !authExempt[msg.Type] {
-		if !s.authManager.IsAuthorized(client.GetDeviceID()) {
-			client.SendError(msg.Type, "Unauthorized access", 401)
-			return
+	cost := 1
+	if known {
+		if handler.RateLimitExempt {
+			cost = 0
+		} else {
+			cost = handler.cost()
 		}
 	}
+	if cost > 0 && !client.limiter.AllowN(cost) {
+		slog.Warn("Rate limit exceeded for client", "device", client.DeviceName, "type", msg.Type)
+		client.ReplyError(msg, "Rate limit exceeded, slow down", 429)
+		return
+	}
 
-	switch msg.Type {
-	case "ping":
-		client.UpdateLastPingTime()
-		s.handlePingMessage(client, msg)
-	case "host_info":
-		s.handleHostInfoMessage(client)
-	case "join_room":
-		s.handleJoinRoomMessage(client, msg)
-	case "leave_room":
-		s.handleLeaveRoomMessage(client, msg)
-	case "media":
-		s.handleMediaCommand(client, msg)
-	case "power":
-		s.handlePowerCommand(client, msg)
-	case "mouse":
-		s.handleMouseCommand(client, msg)
-	case "script_list":
-		s.handleScriptList(client)
-	case "script_add":
-		s.handleScriptAdd(client, msg)
-	case "script_update":
-		s.handleScriptUpdate(client, msg)
-	case "script_delete":
-		s.handleScriptDelete(client, msg)
-	case "script_stop":
-		s.handleScriptStop(client, msg)
-	case "script_execute":
-		s.handleScriptExecute(client, msg)
-	case "monitor_list":
-		s.handleMonitorList(client)
-	case "monitor_cmd":
-		s.handleMonitorCommand(client, msg)
-	case "monitor_set_resolution":
-		s.handleMonitorSetResolution(client, msg)
-	case "monitor_set_primary":
-		s.handleMonitorSetPrimary(client, msg)
-	case "file_list":
-		s.handleFileList(client, msg)
-	case "file_read":
-		s.handleFileRead(client, msg)
-	case "file_write":
-		s.handleFileWrite(client, msg)
-	case "keyboard":
-		s.handleKeyboardCommand(client, msg)
-	case "keyboard_type":
-		s.handleKeyboardTypeCommand(client, msg)
-	case "platform_caps":
-		s.handlePlatformCaps(client)
-	case "clipboard_set":
-		s.handleClipboardSet(client, msg)
-	case "display_cmd":
-		s.handleDisplayCommand(client, msg)
-	case "process_list":
-		s.handleProcessList(client)
-	case "process_kill":
-		s.handleProcessKill(client, msg)
-	case "startup_list":
-		s.handleStartupList(client)
-	case "startup_set":
-		s.handleStartupSet(client, msg)
-	case "battery_alert_config":
-		s.handleBatteryAlertConfig(client, msg)
-	case "shell_exec":
-		s.handleShellExec(client, msg)
-	case "auth_request":
-		if s.authManager != nil {
-			s.authManager.HandleAuthRequest(client, msg)
-		} else {
-			slog.Error("authManager is nil")
-			client.SendError("auth_request", "Internal server error", 500)
+	if !known {
+		slog.Warn("Unknown message type", "type", msg.Type)
+		return
+	}
+
+	// A kicked and blocked device may not use its connections or authenticate again.
+	deviceID := client.GetDeviceID()
+	if deviceID == "" && handler.AuthExempt {
+		deviceID = messageDeviceID(msg)
+	}
+	if until, blocked := s.blocks.blockedUntil(deviceID); blocked {
+		refuseBlocked(client, msg, until)
+		return
+	}
+
+	if !handler.AuthExempt {
+		if !s.authManager.IsAuthorized(client.GetDeviceID()) {
+			client.ReplyError(msg, "Unauthorized access", 401)
+			return
 		}
-	case "auth_check":
-		if s.authManager != nil {
-			s.authManager.HandleAuthCheck(client)
-		} else {
-			client.SendError("auth_check", "Internal server error", 500)
+		if msg.Type != "key_exchange" && s.requiresKeyExchange(client) {
+			client.ReplyError(msg, "Key exchange required", 403)
+			return
 		}
-	case "auth_challenge_response":
-		if s.authManager != nil {
-			s.authManager.HandleChallengeResponse(client, msg)
-		} else {
-			client.SendError("auth_challenge_response", "Internal server error", 500)
+		if s.replayable(client) && (handler.Confirm != "" || handler.StepUp || s.Config().RequiresStepUp(msg.Type)) {
+			slog.Warn("Sensitive message refused without key exchange", "device", client.DeviceName, "type", msg.Type)
+			client.ReplyError(msg, "Key exchange required for this action", 403)
+			return
 		}
-	default:
-		slog.Warn("Unknown message type", "type", msg.Type)
+	}
+
+	if handler.Scope != "" && !s.authManager.HasScope(client.GetDeviceID(), handler.Scope) {
+		slog.Warn("Message outside device scopes", "device", client.DeviceName, "type", msg.Type, "scope", handler.Scope)
+		client.ReplyError(msg, fmt.Sprintf("Missing permission: %s", handler.Scope), 403)
+		return
+	}
+
+	if handler.Admin && !s.authManager.IsAdmin(client.GetDeviceID()) {
+		client.ReplyError(msg, "Admin rights required", 403)
+		return
+	}
+
+	if handler.Room != "" && !s.roomManager.IsClientInRoom(handler.Room, client) {
+		client.ReplyError(msg, fmt.Sprintf("Client not in %s room", handler.Room), 403)
+		return
+	}
+
+	if s.needsStepUp(client, msg, handler) {
+		s.holdForStepUp(client, msg, handler, record.detach())
+		return
+	}
+
+	s.runHandler(client, msg, handler, record)
+}
+
+// runHandler runs a message that passed the dispatcher's checks, after the
+// host confirmed it if its action is guarded. The caller ends record unless
+// it was handed to a goroutine.
+func (s *WSServer) runHandler(client *Client, msg *ClientMessage, handler *Handler, record *auditRecord) {
+	if s.needsConfirmation(handler.Confirm) {
+		go s.confirmThenHandle(client, msg, handler, record.detach())
+		return
+	}
+
+	if handler.Async {
+		record := record.detach()
+		go func() {
+			defer record.end()
+			defer func() {
+				if r := recover(); r != nil {
+					slog.Error("Panic recovered in async handler", "type", msg.Type, "err", r)
+				}
+			}()
+			handler.Handle(client, msg)
+		}()
+		return
+	}
+
+	handler.Handle(client, msg)
+}
+
+// requiresKeyExchange reports whether the client must complete key_exchange
+// before anything other than authentication is served.
+func (s *WSServer) requiresKeyExchange(client *Client) bool {
+	cfg := s.Config()
+	return cfg.EnableE2EE && cfg.RequireKeyExchange && !client.HasSessionKey()
+}
+
+// replayable reports whether the client's envelopes could be replayed: with
+// E2EE on, a client that skipped key_exchange encrypts every connection
+// under the same key and without sequence numbers, so a captured envelope
+// is accepted again on a new connection. Sensitive actions are refused on
+// such connections even while RequireKeyExchange is off.
+func (s *WSServer) replayable(client *Client) bool {
+	return s.Config().EnableE2EE && !client.HasSessionKey()
+}
+
+// handleAuthRequest forwards an authorization request to the auth manager.
+func (s *WSServer) handleAuthRequest(client *Client, msg *ClientMessage) {
+	if s.authManager == nil {
+		slog.Error("authManager is nil")
+		client.ReplyError(msg, "Internal server error", 500)
+		return
+	}
+	s.authManager.HandleAuthRequest(client.ForRequest(msg), msg)
+}
+
+// handleAuthCheck reports the client's current authorization status.
+func (s *WSServer) handleAuthCheck(client *Client, msg *ClientMessage) {
+	if s.authManager == nil {
+		client.ReplyError(msg, "Internal server error", 500)
+		return
+	}
+	s.authManager.HandleAuthCheck(client.ForRequest(msg))
+}
+
+// handleChallengeResponse forwards the client's HMAC answer to the auth manager.
+func (s *WSServer) handleChallengeResponse(client *Client, msg *ClientMessage) {
+	if s.authManager == nil {
+		client.ReplyError(msg, "Internal server error", 500)
+		return
+	}
+	s.authManager.HandleChallengeResponse(client.ForRequest(msg), msg)
+}
+
+// handlePairRequest forwards a pairing code to the auth manager.
+func (s *WSServer) handlePairRequest(client *Client, msg *ClientMessage) {
+	if s.authManager == nil {
+		client.ReplyError(msg, "Internal server error", 500)
+		return
+	}
+	s.authManager.HandlePairRequest(client.ForRequest(msg), msg)
+}
+
+// onClientAuthorized issues a resume token once the auth layer has granted
+// the client access. Repeated grants (e.g. auth_check polling) reuse it.
+func (s *WSServer) onClientAuthorized(client *Client) {
+	token, created, err := s.sessions.Issue(client)
+	if err != nil {
+		slog.Error("Failed to issue resume token", "device", client.DeviceName, "err", err)
+		return
+	}
+	if created {
+		events.Publish(s.bus, DeviceConnectionTopic, DeviceConnection{
+			DeviceID:   client.GetDeviceID(),
+			DeviceName: client.GetDeviceName(),
+			IP:         client.GetIP(),
+			Connected:  true,
+		})
+	}
+	client.SendSuccess("session_token", map[string]interface{}{
+		"token":         token,
+		"grace_seconds": int(resumeGracePeriod.Seconds()),
+	})
+}
+
+// handleSessionResume restores a session dropped within the grace period:
+// the device identity, its rooms and the events it missed meanwhile.
+func (s *WSServer) handleSessionResume(client *Client, msg *ClientMessage) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(msg.Data, &req); err != nil || req.Token == "" {
+		client.ReplyError(msg, "Invalid format", 400)
+		return
+	}
+
+	resumed, err := s.sessions.Resume(req.Token, client)
+	if err != nil {
+		slog.Info("Session resume refused", "ip", client.IP, "err", err)
+		client.ReplyError(msg, "Session cannot be resumed, authenticate again", 401)
+		return
+	}
+
+	// Authorisation may have been revoked while the device was away.
+	if until, blocked := s.blocks.blockedUntil(resumed.DeviceID); blocked {
+		s.sessions.Discard(client)
+		refuseBlocked(client, msg, until)
+		return
+	}
+	if !s.authManager.IsAuthorized(resumed.DeviceID) {
+		s.sessions.Discard(client)
+		client.ReplyError(msg, "Session cannot be resumed, authenticate again", 401)
+		return
+	}
+
+	if resumed.Previous != nil {
+		// The old connection has not timed out yet: take over its rooms.
+		slog.Info("Replacing stale connection on resume", "device", resumed.DeviceName)
+		resumed.Rooms = resumed.Previous.RoomNames()
+		s.disconnectClient(resumed.Previous)
+	}
+
+	client.SetDeviceID(resumed.DeviceID)
+	client.SetDeviceName(resumed.DeviceName)
+	// Scopes may have been narrowed while the device was away, so rooms
+	// are checked again as if joined afresh.
+	rooms := resumed.Rooms[:0:0]
+	for _, room := range resumed.Rooms {
+		if scope, ok := s.roomScopeMissing(resumed.DeviceID, room); ok {
+			slog.Info("Room not restored on resume, scope missing", "device", resumed.DeviceName, "room", room, "scope", scope)
+			continue
+		}
+		s.roomManager.AddClientToRoom(room, client)
+		rooms = append(rooms, room)
+	}
+	resumed.Rooms = rooms
+
+	events.Publish(s.bus, DeviceConnectionTopic, DeviceConnection{
+		DeviceID:   resumed.DeviceID,
+		DeviceName: resumed.DeviceName,
+		IP:         client.GetIP(),
+		Connected:  true,
+		Resumed:    true,
+	})
+
+	slog.Info("Session resumed", "device", resumed.DeviceName, "rooms", len(resumed.Rooms),
+		"replayed", len(resumed.Backlog), "missed", resumed.Missed)
+
+	client.ReplySuccess(msg, "session_resume", map[string]interface{}{
+		"token":         resumed.Token,
+		"grace_seconds": int(resumeGracePeriod.Seconds()),
+		"rooms":         resumed.Rooms,
+		"replayed":      len(resumed.Backlog),
+		"missed":        resumed.Missed,
+	})
+
+	for _, event := range resumed.Backlog {
+		client.sendResponse(event)
 	}
 }
 
 // handlePingMessage responds to client heartbeats.
 func (s *WSServer) handlePingMessage(client *Client, msg *ClientMessage) {
+	client.UpdateLastPingTime()
+
 	var timestamp interface{} = time.Now().UnixMilli()
 	if len(msg.Data) > 0 {
 		var pingData map[string]interface{}
@@ -442,13 +777,13 @@ func (s *WSServer) handlePingMessage(client *Client, msg *ClientMessage) {
 		}
 	}
 
-	client.SendSuccess("pong", map[string]interface{}{
+	client.ReplySuccess(msg, "pong", map[string]interface{}{
 		"timestamp": timestamp,
 	})
 }
 
 // handleHostInfoMessage provides system and hardware specifications to the client.
-func (s *WSServer) handleHostInfoMessage(client *Client) {
+func (s *WSServer) handleHostInfoMessage(client *Client, msg *ClientMessage) {
 	cpuInfo, _ := metrics.GetCPUInfo()
 	deviceInfo := deviceinfo.GetDeviceInfo()
 	ramInfo, _ := metrics.GetRAMInfo()
@@ -480,11 +815,30 @@ func (s *WSServer) handleHostInfoMessage(client *Client) {
 		"platformVersion": platformVersion,
 	}
 
-	client.SendSuccess("host_info", hostInfo)
+	client.ReplySuccess(msg, "host_info", hostInfo)
+}
+
+// roomScopes maps rooms that stream sensitive data to the scope required to join them.
+var roomScopes = map[string]string{
+	"media":     config.ScopeMedia,
+	"clipboard": config.ScopeClipboard,
+}
+
+// roomScopeMissing reports the scope deviceID lacks to join room, if any.
+func (s *WSServer) roomScopeMissing(deviceID, room string) (string, bool) {
+	scope, ok := roomScopes[room]
+	if !ok || s.authManager.HasScope(deviceID, scope) {
+		return "", false
+	}
+	return scope, true
 }
 
 // handleJoinRoomMessage subscribes the client to a broadcast room.
 func (s *WSServer) handleJoinRoomMessage(client *Client, msg *ClientMessage) {
+	if scope, missing := s.roomScopeMissing(client.GetDeviceID(), msg.Room); missing {
+		client.ReplyError(msg, fmt.Sprintf("Missing permission: %s", scope), 403)
+		return
+	}
 	s.roomManager.AddClientToRoom(msg.Room, client)
 }
 
@@ -495,14 +849,9 @@ func (s *WSServer) handleLeaveRoomMessage(client *Client, msg *ClientMessage) {
 
 // handleMediaCommand processes volume and playback control requests.
 func (s *WSServer) handleMediaCommand(client *Client, msg *ClientMessage) {
-	if !s.roomManager.IsClientInRoom("media", client) {
-		client.SendError("media", "Client not in media room", 403)
-		return
-	}
-
 	var data map[string]interface{}
 	if err := json.Unmarshal(msg.Data, &data); err != nil {
-		client.SendError("media", "Invalid format", 400)
+		client.ReplyError(msg, "Invalid format", 400)
 		return
 	}
 
@@ -510,7 +859,7 @@ func (s *WSServer) handleMediaCommand(client *Client, msg *ClientMessage) {
 	value, ok2 := data["value"].(float64)
 
 	if !ok1 || !ok2 {
-		client.SendError("media", "Invalid parameters", 400)
+		client.ReplyError(msg, "Invalid parameters", 400)
 		return
 	}
 
@@ -519,11 +868,11 @@ func (s *WSServer) handleMediaCommand(client *Client, msg *ClientMessage) {
 		Value:  int(value),
 	})
 	if err != nil {
-		client.SendError("media", err.Error(), 500)
+		client.ReplyError(msg, err.Error(), 500)
 		return
 	}
 
-	client.SendSuccess("media", map[string]interface{}{
+	client.ReplySuccess(msg, "media", map[string]interface{}{
 		"action": int(action),
 		"value":  int(value),
 		"status": "success",
@@ -531,8 +880,8 @@ func (s *WSServer) handleMediaCommand(client *Client, msg *ClientMessage) {
 }
 
 // handleScriptList returns all registered scripts.
-func (s *WSServer) handleScriptList(client *Client) {
-	client.SendSuccess("script_list", map[string]interface{}{
+func (s *WSServer) handleScriptList(client *Client, msg *ClientMessage) {
+	client.ReplySuccess(msg, "script_list", map[string]interface{}{
 		"scripts": s.scriptManager.List(),
 	})
 }
@@ -541,28 +890,28 @@ func (s *WSServer) handleScriptList(client *Client) {
 func (s *WSServer) handleScriptAdd(client *Client, msg *ClientMessage) {
 	var script scheduler.Script
 	if err := json.Unmarshal(msg.Data, &script); err != nil {
-		client.SendError("script_add", "Invalid script data", 400)
+		client.ReplyError(msg, "Invalid script data", 400)
 		return
 	}
 	if err := s.scriptManager.Add(script); err != nil {
-		client.SendError("script_add", err.Error(), 500)
+		client.ReplyError(msg, err.Error(), 500)
 		return
 	}
-	client.SendSuccess("script_add", script)
+	client.ReplySuccess(msg, "script_add", script)
 }
 
 // handleScriptUpdate modifies an existing script definition.
 func (s *WSServer) handleScriptUpdate(client *Client, msg *ClientMessage) {
 	var script scheduler.Script
 	if err := json.Unmarshal(msg.Data, &script); err != nil {
-		client.SendError("script_update", "Invalid script data", 400)
+		client.ReplyError(msg, "Invalid script data", 400)
 		return
 	}
 	if err := s.scriptManager.Update(script); err != nil {
-		client.SendError("script_update", err.Error(), 500)
+		client.ReplyError(msg, err.Error(), 500)
 		return
 	}
-	client.SendSuccess("script_update", script)
+	client.ReplySuccess(msg, "script_update", script)
 }
 
 // handleScriptDelete removes a script definition from the host.
@@ -571,14 +920,14 @@ func (s *WSServer) handleScriptDelete(client *Client, msg *ClientMessage) {
 		ID string `json:"id"`
 	}
 	if err := json.Unmarshal(msg.Data, &req); err != nil || req.ID == "" {
-		client.SendError("script_delete", "Invalid script ID", 400)
+		client.ReplyError(msg, "Invalid script ID", 400)
 		return
 	}
 	if err := s.scriptManager.Delete(req.ID); err != nil {
-		client.SendError("script_delete", err.Error(), 500)
+		client.ReplyError(msg, err.Error(), 500)
 		return
 	}
-	client.SendSuccess("script_delete", req)
+	client.ReplySuccess(msg, "script_delete", req)
 }
 
 // handleScriptStop terminates a running script process.
@@ -587,51 +936,55 @@ func (s *WSServer) handleScriptStop(client *Client, msg *ClientMessage) {
 		ID string `json:"id"`
 	}
 	if err := json.Unmarshal(msg.Data, &req); err != nil || req.ID == "" {
-		client.SendError("script_stop", "Invalid script ID", 400)
+		client.ReplyError(msg, "Invalid script ID", 400)
 		return
 	}
 	s.scriptManager.Stop(req.ID)
-	client.SendSuccess("script_stop", req)
+	client.ReplySuccess(msg, "script_stop", req)
 }
 
-// handleScriptExecute starts a script and routes its output back to the client.
+// handleScriptExecute runs a script and routes its output back to the client.
+// It is registered as async, so it may block until the script finishes.
 func (s *WSServer) handleScriptExecute(client *Client, msg *ClientMessage) {
 	var req struct {
 		ID string `json:"id"`
 	}
 	if err := json.Unmarshal(msg.Data, &req); err != nil || req.ID == "" {
-		client.SendError("script_execute", "Invalid script ID", 400)
+		client.ReplyError(msg, "Invalid script ID", 400)
 		return
 	}
 
-	go func() {
-		onOutput := func(chunk scheduler.OutputChunk) {
-			client.SendSuccess("script_output", chunk)
-		}
+	onOutput := func(chunk scheduler.OutputChunk) {
+		client.ReplySuccess(msg, "script_output", chunk)
+	}
 
-		result, err := s.scriptManager.Execute(req.ID, onOutput)
-		if err != nil {
-			client.SendError("script_execute", err.Error(), 404)
-			return
-		}
-		client.SendSuccess("script_execute", map[string]interface{}{
-			"id":          result.ID,
-			"exit_code":   result.ExitCode,
-			"stdout":      result.Stdout,
-			"stderr":      result.Stderr,
-			"duration_ms": result.Duration,
-		})
-	}()
+	result, err := s.scriptManager.ExecuteFor(req.ID, client.GetDeviceID(), onOutput)
+	if errors.Is(err, scheduler.ErrDenied) {
+		client.ReplyError(msg, err.Error(), 403)
+		return
+	}
+	if err != nil {
+		client.ReplyError(msg, err.Error(), 404)
+		return
+	}
+	events.Publish(s.bus, scheduler.ScriptResultTopic, scheduler.ScriptResult{RunResult: result, Trigger: scheduler.TriggerClient})
+	client.ReplySuccess(msg, "script_execute", map[string]interface{}{
+		"id":          result.ID,
+		"exit_code":   result.ExitCode,
+		"stdout":      result.Stdout,
+		"stderr":      result.Stderr,
+		"duration_ms": result.Duration,
+	})
 }
 
 // handleMonitorList returns all connected monitors and their current settings.
-func (s *WSServer) handleMonitorList(client *Client) {
+func (s *WSServer) handleMonitorList(client *Client, msg *ClientMessage) {
 	list, err := monitors.GetMonitors()
 	if err != nil {
-		client.SendError("monitor_list", err.Error(), 500)
+		client.ReplyError(msg, err.Error(), 500)
 		return
 	}
-	client.SendSuccess("monitor_list", map[string]interface{}{
+	client.ReplySuccess(msg, "monitor_list", map[string]interface{}{
 		"monitors": list,
 	})
 }
@@ -646,7 +999,7 @@ func (s *WSServer) handleMonitorCommand(client *Client, msg *ClientMessage) {
 		Rate      int    `json:"rate"`
 	}
 	if err := json.Unmarshal(msg.Data, &data); err != nil {
-		client.SendError("monitor_cmd", "Invalid format", 400)
+		client.ReplyError(msg, "Invalid format", 400)
 		return
 	}
 
@@ -657,16 +1010,16 @@ func (s *WSServer) handleMonitorCommand(client *Client, msg *ClientMessage) {
 	case "set_primary":
 		err = monitors.SetPrimary(data.MonitorID)
 	default:
-		client.SendError("monitor_cmd", "Unknown action", 400)
+		client.ReplyError(msg, "Unknown action", 400)
 		return
 	}
 
 	if err != nil {
-		client.SendError("monitor_cmd", err.Error(), 500)
+		client.ReplyError(msg, err.Error(), 500)
 		return
 	}
 
-	client.SendSuccess("monitor_cmd", map[string]interface{}{"status": "ok"})
+	client.ReplySuccess(msg, "monitor_cmd", map[string]interface{}{"status": "ok"})
 }
 
 // handleMonitorSetResolution sets the resolution of a specific monitor.
@@ -678,14 +1031,14 @@ func (s *WSServer) handleMonitorSetResolution(client *Client, msg *ClientMessage
 		RefreshRate int    `json:"refresh_rate"`
 	}
 	if err := json.Unmarshal(msg.Data, &data); err != nil {
-		client.SendError("monitor_set_resolution", "Invalid format", 400)
+		client.ReplyError(msg, "Invalid format", 400)
 		return
 	}
 	if err := monitors.SetResolution(data.MonitorID, data.Width, data.Height, data.RefreshRate); err != nil {
-		client.SendError("monitor_set_resolution", err.Error(), 500)
+		client.ReplyError(msg, err.Error(), 500)
 		return
 	}
-	client.SendSuccess("monitor_set_resolution", map[string]any{"status": "ok"})
+	client.ReplySuccess(msg, "monitor_set_resolution", map[string]any{"status": "ok"})
 }
 
 // handleMonitorSetPrimary designates the specified monitor as the primary display.
@@ -694,14 +1047,14 @@ func (s *WSServer) handleMonitorSetPrimary(client *Client, msg *ClientMessage) {
 		MonitorID string `json:"monitor_id"`
 	}
 	if err := json.Unmarshal(msg.Data, &data); err != nil {
-		client.SendError("monitor_set_primary", "Invalid format", 400)
+		client.ReplyError(msg, "Invalid format", 400)
 		return
 	}
 	if err := monitors.SetPrimary(data.MonitorID); err != nil {
-		client.SendError("monitor_set_primary", err.Error(), 500)
+		client.ReplyError(msg, err.Error(), 500)
 		return
 	}
-	client.SendSuccess("monitor_set_primary", map[string]any{"status": "ok"})
+	client.ReplySuccess(msg, "monitor_set_primary", map[string]any{"status": "ok"})
 }
 
 // handleFileList lists directory contents.
@@ -716,11 +1069,11 @@ func (s *WSServer) handleFileList(client *Client, msg *ClientMessage) {
 
 	list, err := filebrowser.ListDir(data.Path)
 	if err != nil {
-		client.SendError("file_list", err.Error(), 500)
+		client.ReplyError(msg, err.Error(), 500)
 		return
 	}
 
-	client.SendSuccess("file_list", map[string]interface{}{
+	client.ReplySuccess(msg, "file_list", map[string]interface{}{
 		"path":  data.Path,
 		"files": list,
 	})
@@ -735,11 +1088,11 @@ func (s *WSServer) handleFileRead(client *Client, msg *ClientMessage) {
 
 	content, err := filebrowser.ReadFile(data.Path)
 	if err != nil {
-		client.SendError("file_read", err.Error(), 500)
+		client.ReplyError(msg, err.Error(), 500)
 		return
 	}
 
-	client.SendSuccess("file_read", map[string]interface{}{
+	client.ReplySuccess(msg, "file_read", map[string]interface{}{
 		"path":    data.Path,
 		"content": content, // JSON marshal will base64 encode this
 	})
@@ -752,32 +1105,32 @@ func (s *WSServer) handleFileWrite(client *Client, msg *ClientMessage) {
 		Content []byte `json:"content"`
 	}
 	if err := json.Unmarshal(msg.Data, &data); err != nil {
-		client.SendError("file_write", "Invalid format", 400)
+		client.ReplyError(msg, "Invalid format", 400)
 		return
 	}
 
 	if err := filebrowser.WriteFile(data.Path, data.Content); err != nil {
-		client.SendError("file_write", err.Error(), 500)
+		client.ReplyError(msg, err.Error(), 500)
 		return
 	}
 
-	client.SendSuccess("file_write", map[string]interface{}{"status": "ok"})
+	client.ReplySuccess(msg, "file_write", map[string]interface{}{"status": "ok"})
 }
 
 // handleMouseCommand performs cursor movement or button clicks.
 func (s *WSServer) handleMouseCommand(client *Client, msg *ClientMessage) {
 	var cmd mouse.MouseCommand
 	if err := json.Unmarshal(msg.Data, &cmd); err != nil {
-		client.SendError("mouse", "Invalid format", 400)
+		client.ReplyError(msg, "Invalid format", 400)
 		return
 	}
 	if err := mouse.HandleMouseCommand(cmd); err != nil {
-		client.SendError("mouse", err.Error(), 500)
+		client.ReplyError(msg, err.Error(), 500)
 		return
 	}
 	// Move events don't need a success reply — reduces latency and bandwidth.
 	if cmd.Action != mouse.ActionMove {
-		client.SendSuccess("mouse", map[string]interface{}{"action": cmd.Action})
+		client.ReplySuccess(msg, "mouse", map[string]interface{}{"action": cmd.Action})
 	}
 }
 
@@ -785,18 +1138,18 @@ func (s *WSServer) handleMouseCommand(client *Client, msg *ClientMessage) {
 func (s *WSServer) handleKeyboardCommand(client *Client, msg *ClientMessage) {
 	var cmd keyboard.KeyCommand
 	if err := json.Unmarshal(msg.Data, &cmd); err != nil {
-		client.SendError("keyboard", "Invalid format", 400)
+		client.ReplyError(msg, "Invalid format", 400)
 		return
 	}
 	if !keyboard.ValidKey(cmd.Key) {
-		client.SendError("keyboard", fmt.Sprintf("Unknown key: %s", cmd.Key), 400)
+		client.ReplyError(msg, fmt.Sprintf("Unknown key: %s", cmd.Key), 400)
 		return
 	}
 	if err := keyboard.HandleKeyCommand(cmd); err != nil {
-		client.SendError("keyboard", err.Error(), 500)
+		client.ReplyError(msg, err.Error(), 500)
 		return
 	}
-	client.SendSuccess("keyboard", map[string]interface{}{"key": cmd.Key})
+	client.ReplySuccess(msg, "keyboard", map[string]interface{}{"key": cmd.Key})
 }
 
 // handleKeyboardTypeCommand injects a text string as Unicode keystrokes.
@@ -805,25 +1158,26 @@ func (s *WSServer) handleKeyboardTypeCommand(client *Client, msg *ClientMessage)
 		Text string `json:"text"`
 	}
 	if err := json.Unmarshal(msg.Data, &data); err != nil || data.Text == "" {
-		client.SendError("keyboard_type", "text field required", 400)
+		client.ReplyError(msg, "text field required", 400)
 		return
 	}
 	if len([]rune(data.Text)) > 1000 {
-		client.SendError("keyboard_type", "text exceeds 1000 characters", 400)
+		client.ReplyError(msg, "text exceeds 1000 characters", 400)
 		return
 	}
 	if err := keyboard.TypeText(data.Text); err != nil {
-		client.SendError("keyboard_type", err.Error(), 500)
+		client.ReplyError(msg, err.Error(), 500)
 		return
 	}
-	client.SendSuccess("keyboard_type", map[string]interface{}{"status": "ok"})
+	client.ReplySuccess(msg, "keyboard_type", map[string]interface{}{"status": "ok"})
 }
 
 // handlePlatformCaps returns the capability flags, platform name, and API version for the host.
-func (s *WSServer) handlePlatformCaps(client *Client) {
-	client.SendSuccess("platform_caps", map[string]interface{}{
+func (s *WSServer) handlePlatformCaps(client *Client, msg *ClientMessage) {
+	client.ReplySuccess(msg, "platform_caps", map[string]interface{}{
 		"platform":    capabilities.Platform(),
 		"features":    capabilities.Get(),
+		"messages":    s.registry.Types(),
 		"api_version": version.API,
 		"app_version": version.App,
 	})
@@ -835,14 +1189,14 @@ func (s *WSServer) handleClipboardSet(client *Client, msg *ClientMessage) {
 		Text string `json:"text"`
 	}
 	if err := json.Unmarshal(msg.Data, &data); err != nil {
-		client.SendError("clipboard_set", "Invalid format", 400)
+		client.ReplyError(msg, "Invalid format", 400)
 		return
 	}
 	if err := clipboard.Set(data.Text); err != nil {
-		client.SendError("clipboard_set", err.Error(), 500)
+		client.ReplyError(msg, err.Error(), 500)
 		return
 	}
-	client.SendSuccess("clipboard_set", map[string]interface{}{"status": "ok"})
+	client.ReplySuccess(msg, "clipboard_set", map[string]interface{}{"status": "ok"})
 }
 
 // handleDisplayCommand routes sleep/wake/brightness commands to the monitors package.
@@ -852,7 +1206,7 @@ func (s *WSServer) handleDisplayCommand(client *Client, msg *ClientMessage) {
 		Brightness int    `json:"brightness"` // 0-100, used when action == "brightness"
 	}
 	if err := json.Unmarshal(msg.Data, &data); err != nil {
-		client.SendError("display_cmd", "Invalid format", 400)
+		client.ReplyError(msg, "Invalid format", 400)
 		return
 	}
 
@@ -865,27 +1219,27 @@ func (s *WSServer) handleDisplayCommand(client *Client, msg *ClientMessage) {
 	case "brightness":
 		err = monitors.SetBrightness(data.Brightness)
 	default:
-		client.SendError("display_cmd", "Unknown action", 400)
+		client.ReplyError(msg, "Unknown action", 400)
 		return
 	}
 
 	if err != nil {
-		client.SendError("display_cmd", err.Error(), 500)
+		client.ReplyError(msg, err.Error(), 500)
 		return
 	}
-	client.SendSuccess("display_cmd", map[string]interface{}{"status": "ok"})
+	client.ReplySuccess(msg, "display_cmd", map[string]interface{}{"status": "ok"})
 }
 
 // ── Process management ────────────────────────────────────────────────────────
 
 // handleProcessList returns a snapshot of all running processes.
-func (s *WSServer) handleProcessList(client *Client) {
+func (s *WSServer) handleProcessList(client *Client, msg *ClientMessage) {
 	procs, err := process.List()
 	if err != nil {
-		client.SendError("process_list", err.Error(), 500)
+		client.ReplyError(msg, err.Error(), 500)
 		return
 	}
-	client.SendSuccess("process_list", map[string]interface{}{
+	client.ReplySuccess(msg, "process_list", map[string]interface{}{
 		"processes": procs,
 	})
 }
@@ -896,26 +1250,26 @@ func (s *WSServer) handleProcessKill(client *Client, msg *ClientMessage) {
 		PID int32 `json:"pid"`
 	}
 	if err := json.Unmarshal(msg.Data, &req); err != nil || req.PID == 0 {
-		client.SendError("process_kill", "Invalid PID", 400)
+		client.ReplyError(msg, "Invalid PID", 400)
 		return
 	}
 	if err := process.Kill(req.PID); err != nil {
-		client.SendError("process_kill", err.Error(), 500)
+		client.ReplyError(msg, err.Error(), 500)
 		return
 	}
-	client.SendSuccess("process_kill", map[string]interface{}{"pid": req.PID})
+	client.ReplySuccess(msg, "process_kill", map[string]interface{}{"pid": req.PID})
 }
 
 // ── Startup management ────────────────────────────────────────────────────────
 
 // handleStartupList returns all startup entries visible to the current user.
-func (s *WSServer) handleStartupList(client *Client) {
+func (s *WSServer) handleStartupList(client *Client, msg *ClientMessage) {
 	entries, err := startup.ListEntries()
 	if err != nil {
-		client.SendError("startup_list", err.Error(), 500)
+		client.ReplyError(msg, err.Error(), 500)
 		return
 	}
-	client.SendSuccess("startup_list", map[string]interface{}{
+	client.ReplySuccess(msg, "startup_list", map[string]interface{}{
 		"entries": entries,
 	})
 }
@@ -927,14 +1281,14 @@ func (s *WSServer) handleStartupSet(client *Client, msg *ClientMessage) {
 		Enabled bool   `json:"enabled"`
 	}
 	if err := json.Unmarshal(msg.Data, &req); err != nil || req.Name == "" {
-		client.SendError("startup_set", "Invalid request", 400)
+		client.ReplyError(msg, "Invalid request", 400)
 		return
 	}
 	if err := startup.SetEntry(req.Name, req.Enabled); err != nil {
-		client.SendError("startup_set", err.Error(), 500)
+		client.ReplyError(msg, err.Error(), 500)
 		return
 	}
-	client.SendSuccess("startup_set", map[string]interface{}{
+	client.ReplySuccess(msg, "startup_set", map[string]interface{}{
 		"name":    req.Name,
 		"enabled": req.Enabled,
 	})
@@ -948,63 +1302,67 @@ func (s *WSServer) handleBatteryAlertConfig(client *Client, msg *ClientMessage)
 		Threshold int `json:"threshold"`
 	}
 	if err := json.Unmarshal(msg.Data, &req); err != nil {
-		client.SendError("battery_alert_config", "Invalid format", 400)
+		client.ReplyError(msg, "Invalid format", 400)
 		return
 	}
 	if req.Threshold < 0 || req.Threshold > 100 {
-		client.SendError("battery_alert_config", "Threshold must be 0–100", 400)
+		client.ReplyError(msg, "Threshold must be 0–100", 400)
 		return
 	}
 	if s.batteryAlertCollector != nil {
 		s.batteryAlertCollector.SetThreshold(req.Threshold)
 	}
-	client.SendSuccess("battery_alert_config", map[string]interface{}{
+	client.ReplySuccess(msg, "battery_alert_config", map[string]interface{}{
 		"threshold": req.Threshold,
 	})
 }
 
 // handleShellExec runs an arbitrary shell command and returns combined output.
+// It is registered as async, so the command may run for up to 30 seconds.
 func (s *WSServer) handleShellExec(client *Client, msg *ClientMessage) {
 	var data map[string]interface{}
 	if err := json.Unmarshal(msg.Data, &data); err != nil {
-		client.SendError("shell_exec", "Invalid format", 400)
+		client.ReplyError(msg, "Invalid format", 400)
 		return
 	}
 
-	go func() {
-		rawCmd, _ := data["command"].(string)
-		if rawCmd == "" {
-			client.SendError("shell_exec", "command is required", 400)
-			return
-		}
-		parts := strings.Fields(rawCmd)
-		if len(parts) == 0 {
-			client.SendError("shell_exec", "empty command", 400)
-			return
-		}
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-		var cmd *exec.Cmd
-		if runtime.GOOS == "windows" {
-			cmd = exec.CommandContext(ctx, "cmd", append([]string{"/C"}, parts...)...)
-			cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	rawCmd, _ := data["command"].(string)
+	if rawCmd == "" {
+		client.ReplyError(msg, "command is required", 400)
+		return
+	}
+	if strings.TrimSpace(rawCmd) == "" {
+		client.ReplyError(msg, "empty command", 400)
+		return
+	}
+	workDir, _ := data["workDir"].(string)
+
+	policy := &s.Config().ExecPolicy
+	decision := execpolicy.CheckShell(policy, client.GetDeviceID(), rawCmd, workDir)
+	logExecDecision("shell", client.GetDeviceID(), rawCmd, decision)
+	if !decision.Allowed {
+		client.ReplyError(msg, "Blocked by exec policy: "+decision.Reason, 403)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cmd := shellCommand(ctx, rawCmd)
+	cmd.Dir = decision.Dir
+	cmd.Env = execpolicy.Env(policy)
+	out, err := cmd.CombinedOutput()
+	exitCode := 0
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			exitCode = exitErr.ExitCode()
 		} else {
-			cmd = exec.CommandContext(ctx, "sh", "-c", rawCmd)
-		}
-		out, err := cmd.CombinedOutput()
-		exitCode := 0
-		if err != nil {
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				exitCode = exitErr.ExitCode()
-			} else {
-				exitCode = -1
-			}
+			exitCode = -1
 		}
-		client.SendSuccess("shell_exec", map[string]interface{}{
-			"output":    string(out),
-			"exit_code": exitCode,
-		})
-	}()
+	}
+	client.ReplySuccess(msg, "shell_exec", map[string]interface{}{
+		"output":    string(out),
+		"exit_code": exitCode,
+	})
 }
 
 // ── REST helpers ──────────────────────────────────────────────────────────────
@@ -1029,19 +1387,99 @@ func getLANIP() string {
 	return ""
 }
 
-// restAuth checks the Authorization: Bearer header against the shared secret.
-// If no shared secret is configured, the request is allowed (dev mode).
-func (s *WSServer) restAuth(r *http.Request) bool {
-	if s.config.SharedSecret == "" {
+// restCaller identifies who made a REST request. The shared secret grants
+// every scope; an API token only the scopes it was created with.
+type restCaller struct {
+	token  string // token name, empty for the shared secret
+	scopes []string
+}
+
+// allowed reports whether the caller may use something guarded by scope.
+func (c *restCaller) allowed(scope string) bool {
+	if scope == "" || c.token == "" {
 		return true
 	}
-	header := r.Header.Get("Authorization")
-	if len(header) > 7 && header[:7] == "Bearer " {
-		return header[7:] == s.config.SharedSecret
+	for _, s := range c.scopes {
+		if s == scope {
+			return true
+		}
 	}
 	return false
 }
 
+// name describes the caller in logs and prompts.
+func (c *restCaller) name() string {
+	if c.token == "" {
+		return "shared secret"
+	}
+	return "API token " + c.token
+}
+
+type restCallerKey struct{}
+
+// restCallerFrom returns the caller that restHandler attached to r.
+func restCallerFrom(r *http.Request) *restCaller {
+	if caller, ok := r.Context().Value(restCallerKey{}).(*restCaller); ok {
+		return caller
+	}
+	return &restCaller{}
+}
+
+// restAuth checks the Authorization: Bearer header against the shared secret
+// and the configured API tokens, both in constant time. A request without a
+// valid credential is refused, even when neither is configured.
+func (s *WSServer) restAuth(r *http.Request) (*restCaller, bool) {
+	header := r.Header.Get("Authorization")
+	if len(header) <= 7 || header[:7] != "Bearer " {
+		return nil, false
+	}
+	token := header[7:]
+
+	if secret := s.Config().SharedSecret; secret != "" &&
+		subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1 {
+		return &restCaller{}, true
+	}
+	if name, scopes, ok := s.authManager.AuthenticateToken(token); ok {
+		return &restCaller{token: name, scopes: scopes}, true
+	}
+	return nil, false
+}
+
+// restHandler wraps a registered route with bearer-token authentication,
+// the route's scope and a method check so individual endpoints only contain
+// their own logic.
+func (s *WSServer) restHandler(route RESTRoute) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		caller, ok := s.restAuth(r)
+		w, done := s.auditREST(w, r, caller)
+		defer done()
+		if !s.allowRemote(w, r) {
+			return
+		}
+		if !ok {
+			restWriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		if route.TokenOnly && caller.token == "" {
+			restWriteJSON(w, http.StatusForbidden, map[string]string{"error": "an API token with scope " + route.Scope + " is required"})
+			return
+		}
+		if !caller.allowed(route.Scope) {
+			slog.Warn("API token used outside its scopes", "token", caller.token, "path", route.Path, "scope", route.Scope)
+			restWriteJSON(w, http.StatusForbidden, map[string]string{"error": "missing scope: " + route.Scope})
+			return
+		}
+		if route.Method != "" && r.Method != route.Method {
+			restWriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		if s.needsConfirmation(route.Confirm) && !s.restConfirm(w, r, route, caller) {
+			return
+		}
+		route.Handle(w, r.WithContext(context.WithValue(r.Context(), restCallerKey{}, caller)))
+	}
+}
+
 // restWriteJSON serialises v as JSON and writes it to w with the given status code.
 func restWriteJSON(w http.ResponseWriter, status int, v interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -1051,32 +1489,28 @@ func restWriteJSON(w http.ResponseWriter, status int, v interface{}) {
 
 // restInfo returns basic host information.
 func (s *WSServer) restInfo(w http.ResponseWriter, r *http.Request) {
-	if !s.restAuth(r) {
-		restWriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
-		return
-	}
-	if r.Method != http.MethodGet {
-		restWriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
-		return
-	}
 	devInfo := deviceinfo.GetDeviceInfo()
 	restWriteJSON(w, http.StatusOK, map[string]interface{}{
 		"hostname": devInfo.Hostname,
 		"os":       devInfo.OS,
-		"port":     s.config.Port,
+		"port":     s.Config().Port,
+	})
+}
+
+// restStats handles GET /api/v1/stats — connection and outbound queue counters.
+func (s *WSServer) restStats(w http.ResponseWriter, r *http.Request) {
+	s.clientsMutex.Lock()
+	clientCount := len(s.clients)
+	s.clientsMutex.Unlock()
+
+	restWriteJSON(w, http.StatusOK, map[string]interface{}{
+		"clients":  clientCount,
+		"outbound": s.OutboundStats(),
 	})
 }
 
 // restProcesses handles GET /api/v1/processes — returns the process list.
 func (s *WSServer) restProcesses(w http.ResponseWriter, r *http.Request) {
-	if !s.restAuth(r) {
-		restWriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
-		return
-	}
-	if r.Method != http.MethodGet {
-		restWriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
-		return
-	}
 	procs, err := process.List()
 	if err != nil {
 		restWriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
@@ -1087,14 +1521,6 @@ func (s *WSServer) restProcesses(w http.ResponseWriter, r *http.Request) {
 
 // restKillProcess handles POST /api/v1/processes/kill — kills a process by PID.
 func (s *WSServer) restKillProcess(w http.ResponseWriter, r *http.Request) {
-	if !s.restAuth(r) {
-		restWriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
-		return
-	}
-	if r.Method != http.MethodPost {
-		restWriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
-		return
-	}
 	var req struct {
 		PID int32 `json:"pid"`
 	}
@@ -1111,36 +1537,54 @@ func (s *WSServer) restKillProcess(w http.ResponseWriter, r *http.Request) {
 
 // restQR handles GET /api/v1/qr — returns a deep-link URL for client pairing.
 func (s *WSServer) restQR(w http.ResponseWriter, r *http.Request) {
-	if !s.restAuth(r) {
-		restWriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
-		return
-	}
-	if r.Method != http.MethodGet {
-		restWriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
-		return
-	}
 	devInfo := deviceinfo.GetDeviceInfo()
 	host := getLANIP()
 	if host == "" {
 		host = devInfo.Hostname
 	}
-	url := fmt.Sprintf("linqora://%s:%d", host, s.config.Port)
+	cfg := s.Config()
+	fp := s.ServedFingerprint()
 	restWriteJSON(w, http.StatusOK, map[string]interface{}{
-		"url": url,
-		"tls": s.config.EnableTLS,
+		"url":         ConnectURL(host, cfg.Port, fp),
+		"tls":         cfg.EnableTLS,
+		"fingerprint": fp,
 	})
 }
 
+// ConnectURL returns the deep link the app scans to connect. With TLS it
+// carries the certificate's SHA-256 fingerprint, which the app pins.
+func ConnectURL(host string, port int, fingerprint string) string {
+	link := fmt.Sprintf("linqora://%s:%d", host, port)
+	if fingerprint != "" {
+		link += "?fp=" + fingerprint
+	}
+	return link
+}
+
+// CertFingerprint returns the fingerprint of the certificate cfg serves, or
+// "" without TLS.
+func CertFingerprint(cfg *config.ServerConfig) string {
+	if !cfg.EnableTLS {
+		return ""
+	}
+	fp, err := certutils.Fingerprint(cfg.CertFile)
+	if err != nil {
+		slog.Warn("Cannot read the certificate fingerprint", "err", err)
+		return ""
+	}
+	return fp
+}
+
 // handlePowerCommand executes system power actions like Lock, Restart, or Shutdown.
 func (s *WSServer) handlePowerCommand(client *Client, msg *ClientMessage) {
 	var powerCmd power.PowerCommand
 	if err := json.Unmarshal(msg.Data, &powerCmd); err != nil {
-		client.SendError("power", "Invalid format", 400)
+		client.ReplyError(msg, "Invalid format", 400)
 		return
 	}
 
 	if power.IsDeviceLocked() {
-		client.SendSuccess("power", map[string]interface{}{
+		client.ReplySuccess(msg, "power", map[string]interface{}{
 			"action": powerCmd.Action,
 			"status": "locked",
 		})
@@ -1148,7 +1592,7 @@ func (s *WSServer) handlePowerCommand(client *Client, msg *ClientMessage) {
 	}
 
 	if powerCmd.Action == power.Lock {
-		client.SendSuccess("power", map[string]interface{}{
+		client.ReplySuccess(msg, "power", map[string]interface{}{
 			"action": powerCmd.Action,
 			"status": "executing",
 		})
@@ -1172,11 +1616,11 @@ func (s *WSServer) handlePowerCommand(client *Client, msg *ClientMessage) {
 
 	if locked {
 		lockTime := power.GetLockTime()
-		client.SendError("power", fmt.Sprintf("Device is locked (since %s)", lockTime.Format("15:04:05")), 403)
+		client.ReplyError(msg, fmt.Sprintf("Device is locked (since %s)", lockTime.Format("15:04:05")), 403)
 		return
 	}
 
-	client.SendSuccess("power", map[string]interface{}{
+	client.ReplySuccess(msg, "power", map[string]interface{}{
 		"action": powerCmd.Action,
 		"status": "executing",
 	})
@@ -1193,50 +1637,26 @@ func (s *WSServer) handlePowerCommand(client *Client, msg *ClientMessage) {
 
 // restMetrics handles GET /api/v1/metrics — current system performance snapshot.
 func (s *WSServer) restMetrics(w http.ResponseWriter, r *http.Request) {
-	if !s.restAuth(r) {
-		restWriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
-		return
-	}
-	if r.Method != http.MethodGet {
-		restWriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
-		return
-	}
 	cpuMetrics, _ := metrics.GetCPUMetrics()
 	ramMetrics, _ := metrics.GetRamMetrics()
 	batteryInfo, _ := metrics.GetBatteryInfo()
 	restWriteJSON(w, http.StatusOK, map[string]interface{}{
-		"cpu":         cpuMetrics,
-		"ram":         ramMetrics,
-		"gpu_load":    metrics.GetGPULoadPercent(),
-		"gpu_temp":    metrics.GetGPUTemperature(),
-		"battery":     batteryInfo,
+		"cpu":      cpuMetrics,
+		"ram":      ramMetrics,
+		"gpu_load": metrics.GetGPULoadPercent(),
+		"gpu_temp": metrics.GetGPUTemperature(),
+		"battery":  batteryInfo,
 	})
 }
 
 // restScripts handles GET /api/v1/scripts — list all registered scripts.
 func (s *WSServer) restScripts(w http.ResponseWriter, r *http.Request) {
-	if !s.restAuth(r) {
-		restWriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
-		return
-	}
-	if r.Method != http.MethodGet {
-		restWriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
-		return
-	}
 	restWriteJSON(w, http.StatusOK, map[string]interface{}{"scripts": s.scriptManager.List()})
 }
 
 // restScriptExecute handles POST /api/v1/scripts/execute — run a script and return output.
 // Body: {"id": "script-id"}
 func (s *WSServer) restScriptExecute(w http.ResponseWriter, r *http.Request) {
-	if !s.restAuth(r) {
-		restWriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
-		return
-	}
-	if r.Method != http.MethodPost {
-		restWriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
-		return
-	}
 	var req struct {
 		ID string `json:"id"`
 	}
@@ -1245,24 +1665,21 @@ func (s *WSServer) restScriptExecute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	result, err := s.scriptManager.Execute(req.ID, nil)
+	if errors.Is(err, scheduler.ErrDenied) {
+		restWriteJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		restWriteJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
+	events.Publish(s.bus, scheduler.ScriptResultTopic, scheduler.ScriptResult{RunResult: result, Trigger: scheduler.TriggerREST})
 	restWriteJSON(w, http.StatusOK, result)
 }
 
 // restMedia handles POST /api/v1/media — send a media or volume command.
 // Body: {"action": <int>, "value": <int>}  (see media.MediaCommand)
 func (s *WSServer) restMedia(w http.ResponseWriter, r *http.Request) {
-	if !s.restAuth(r) {
-		restWriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
-		return
-	}
-	if r.Method != http.MethodPost {
-		restWriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
-		return
-	}
 	var cmd media.MediaCommand
 	if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
 		restWriteJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid body"})
@@ -1278,14 +1695,6 @@ func (s *WSServer) restMedia(w http.ResponseWriter, r *http.Request) {
 // restPower handles POST /api/v1/power — trigger a power action.
 // Body: {"action": "shutdown"|"restart"|"lock"|"sleep"}
 func (s *WSServer) restPower(w http.ResponseWriter, r *http.Request) {
-	if !s.restAuth(r) {
-		restWriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
-		return
-	}
-	if r.Method != http.MethodPost {
-		restWriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
-		return
-	}
 	var req struct {
 		Action string `json:"action"`
 	}
@@ -1317,14 +1726,6 @@ func (s *WSServer) restPower(w http.ResponseWriter, r *http.Request) {
 // restKeyboardType handles POST /api/v1/keyboard/type — inject text as keystrokes.
 // Body: {"text": "hello world"}
 func (s *WSServer) restKeyboardType(w http.ResponseWriter, r *http.Request) {
-	if !s.restAuth(r) {
-		restWriteJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
-		return
-	}
-	if r.Method != http.MethodPost {
-		restWriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
-		return
-	}
 	var req struct {
 		Text string `json:"text"`
 	}
@@ -1,10 +1,12 @@
 package ws
 
 import (
+	"LinqoraHost/internal/auth"
 	"LinqoraHost/internal/config"
 	"LinqoraHost/internal/interfaces"
 	"encoding/json"
 	"testing"
+	"time"
 )
 
 type mockConn struct {
@@ -19,15 +21,36 @@ func (m *mockConn) WriteMessage(messageType int, data []byte) error {
 type MockAuthManager struct{}
 
 func (m *MockAuthManager) RequestAuthorization(deviceName, deviceID, ip string) bool { return true }
-func (m *MockAuthManager) RespondToAuthRequest(deviceID string, approved bool)       {}
-func (m *MockAuthManager) IsAuthorized(deviceID string) bool                         { return true }
-func (m *MockAuthManager) CheckPendingResult(deviceID string) (bool, bool)           { return true, true }
-func (m *MockAuthManager) RevokeAuth(deviceID string)                                {}
+func (m *MockAuthManager) RespondToAuthRequest(deviceID string, approved bool, scopes []string, expiresAt *time.Time) {
+}
+func (m *MockAuthManager) IsAuthorized(deviceID string) bool               { return true }
+func (m *MockAuthManager) HasScope(deviceID, scope string) bool            { return true }
+func (m *MockAuthManager) CheckPendingResult(deviceID string) (bool, bool) { return true, true }
+func (m *MockAuthManager) ListPending() []interfaces.PendingAuthRequest    { return nil }
+func (m *MockAuthManager) ResolvePending(deviceID string, approved bool, scopes []string, expiresAt *time.Time, by string) bool {
+	return false
+}
+func (m *MockAuthManager) IsAdmin(deviceID string) bool                   { return false }
+func (m *MockAuthManager) RevokeAuth(deviceID string)                     {}
+func (m *MockAuthManager) CertificateDevice(deviceID, serial string) bool { return false }
+func (m *MockAuthManager) StepUpChallenge(deviceID string) (string, string, error) {
+	return "", "", auth.ErrStepUpUnavailable
+}
+func (m *MockAuthManager) VerifyStepUp(client interfaces.WSClient, token, response string) bool {
+	return false
+}
+func (m *MockAuthManager) SetStepUpKey(deviceID, key string) error { return nil }
+func (m *MockAuthManager) SweepExpired() []string                  { return nil }
+func (m *MockAuthManager) AuthenticateToken(token string) (string, []string, bool) {
+	return "", nil, false
+}
 func (m *MockAuthManager) HandleAuthRequest(client interfaces.WSClient, msg interfaces.WSMessage) {
 }
 func (m *MockAuthManager) HandleAuthCheck(client interfaces.WSClient) {}
 func (m *MockAuthManager) HandleChallengeResponse(client interfaces.WSClient, msg interfaces.WSMessage) {
 }
+func (m *MockAuthManager) HandlePairRequest(client interfaces.WSClient, msg interfaces.WSMessage) {
+}
 
 func TestServerRouting(t *testing.T) {
 	cfg := config.DefaultConfig()
@@ -71,3 +94,135 @@ func TestE2EERouting(t *testing.T) {
 
 	server.handleClientMessage(client, &innerMsg)
 }
+
+// nextFrame pops the next queued frame from the client's outbound queue,
+// sealed as the write pump would send it.
+func nextFrame(t *testing.T, client *Client) []byte {
+	t.Helper()
+	raw, ok, _, err := client.popFrame()
+	if err != nil {
+		t.Fatalf("Sealing failed: %v", err)
+	}
+	if !ok {
+		t.Fatal("Expected a queued response")
+	}
+	return raw
+}
+
+// readResponse pops the next queued JSON response from the client.
+func readResponse(t *testing.T, client *Client) ServerResponse {
+	t.Helper()
+	var resp ServerResponse
+	if err := json.Unmarshal(nextFrame(t, client), &resp); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	return resp
+}
+
+func TestReplyEchoesCorrelationID(t *testing.T) {
+	server := NewWSServer(config.DefaultConfig(), &MockAuthManager{})
+	client := NewClient(nil, "127.0.0.1")
+
+	server.handleClientMessage(client, &ClientMessage{ID: "req-1", Type: "ping"})
+
+	resp := readResponse(t, client)
+	if resp.Type != "pong" || resp.ID != "req-1" {
+		t.Errorf("Expected pong with id req-1, got %s with id %q", resp.Type, resp.ID)
+	}
+
+	client.ForRequest(&ClientMessage{ID: "req-2"}).SendError("auth_request", "boom", 500)
+	if resp := readResponse(t, client); resp.ID != "req-2" {
+		t.Errorf("Expected auth reply to carry id req-2, got %q", resp.ID)
+	}
+}
+
+func TestBroadcastHasNoCorrelationID(t *testing.T) {
+	server := NewWSServer(config.DefaultConfig(), &MockAuthManager{})
+	client := NewClient(nil, "127.0.0.1")
+	server.clients[client] = true
+
+	server.broadcastToAll("battery_alert", map[string]int{"percent": 10})
+
+	if resp := readResponse(t, client); resp.ID != "" {
+		t.Errorf("Broadcast must not carry a correlation id, got %q", resp.ID)
+	}
+}
+
+// scopedAuthManager authorises every device but only grants the listed scopes.
+type scopedAuthManager struct {
+	MockAuthManager
+	scopes map[string]bool
+}
+
+func (m *scopedAuthManager) HasScope(deviceID, scope string) bool { return m.scopes[scope] }
+
+func TestDispatcherEnforcesScopes(t *testing.T) {
+	auth := &scopedAuthManager{scopes: map[string]bool{config.ScopeMedia: true}}
+	server := NewWSServer(config.DefaultConfig(), auth)
+	client := NewClient(nil, "127.0.0.1")
+	client.SetDeviceID("phone")
+
+	server.handleClientMessage(client, &ClientMessage{ID: "req-1", Type: "shell_exec"})
+	resp := readResponse(t, client)
+	if resp.ID != "req-1" || resp.Error == nil || resp.Error.Code == nil || *resp.Error.Code != 403 {
+		t.Fatalf("Expected 403 for shell_exec without the shell scope, got %+v", resp)
+	}
+
+	server.handleClientMessage(client, &ClientMessage{Type: "join_room", Room: "clipboard"})
+	if resp := readResponse(t, client); resp.Error == nil {
+		t.Error("Expected joining the clipboard room to be refused")
+	}
+	if server.roomManager.IsClientInRoom("clipboard", client) {
+		t.Error("Client should not be in the clipboard room")
+	}
+
+	server.handleClientMessage(client, &ClientMessage{Type: "join_room", Room: "media"})
+	if !server.roomManager.IsClientInRoom("media", client) {
+		t.Error("Expected the media scope to allow joining the media room")
+	}
+}
+
+func TestUseConfigSwapsWhileServing(t *testing.T) {
+	server := NewWSServer(config.DefaultConfig(), &MockAuthManager{})
+	client := NewClient(nil, "127.0.0.1")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			server.requiresKeyExchange(client)
+		}
+	}()
+	fresh := config.DefaultConfig()
+	fresh.EnableE2EE, fresh.RequireKeyExchange = true, true
+	server.UseConfig(fresh)
+	<-done
+
+	if !server.requiresKeyExchange(client) {
+		t.Error("Expected the new config to be in effect")
+	}
+}
+
+func TestBroadcastsSkipDevicesWithoutScope(t *testing.T) {
+	am := &scopedAuthManager{scopes: map[string]bool{config.ScopeClipboard: true}}
+	server := NewWSServer(config.DefaultConfig(), am)
+	client, _ := connectAuthorized(t, server, "dev-1")
+	server.roomManager.AddClientToRoom("clipboard", client)
+
+	server.broadcastToAll("script_output", map[string]string{"line": "secret"})
+	if pending := client.queue.stats().Pending; pending != 0 {
+		t.Fatalf("Expected no script output for a device without the scripts scope, got %d queued", pending)
+	}
+
+	server.roomManager.SendToRoom("clipboard", "clipboard_update", map[string]string{"text": "one"}, nil)
+	if resp := readResponse(t, client); resp.Type != "clipboard_update" {
+		t.Fatalf("Expected the clipboard update, got %+v", resp)
+	}
+
+	// The scope is taken away while the device stays in the room.
+	delete(am.scopes, config.ScopeClipboard)
+	server.roomManager.SendToRoom("clipboard", "clipboard_update", map[string]string{"text": "two"}, nil)
+	if pending := client.queue.stats().Pending; pending != 0 {
+		t.Errorf("Expected no clipboard update once the scope is gone, got %d queued", pending)
+	}
+}
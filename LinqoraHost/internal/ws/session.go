@@ -0,0 +1,269 @@
+package ws
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// resumeGracePeriod is how long a disconnected session can be resumed.
+	resumeGracePeriod = 2 * time.Minute
+	// sessionBacklogSize is the number of missed events kept per session.
+	// Older events are discarded first.
+	sessionBacklogSize = 100
+)
+
+var (
+	errSessionUnknown = errors.New("unknown or expired resume token")
+	errSessionExpired = errors.New("resume grace period elapsed")
+)
+
+// session tracks an authorised connection so that the device can reconnect
+// without repeating the auth handshake and catch up on what it missed.
+type session struct {
+	token      string
+	deviceID   string
+	deviceName string
+	// client is the most recent connection; attached is false once it is gone.
+	client     *Client
+	attached   bool
+	rooms      []string
+	detachedAt time.Time
+	backlog    []ServerResponse
+	missed     int
+}
+
+// buffer appends an event to the backlog, discarding the oldest entry once
+// the backlog is full. Must be called with the store's lock held.
+func (s *session) buffer(resp ServerResponse) {
+	if len(s.backlog) >= sessionBacklogSize {
+		s.backlog[0] = ServerResponse{}
+		s.backlog = s.backlog[1:]
+		s.missed++
+	}
+	s.backlog = append(s.backlog, resp)
+}
+
+// resumedSession is the state handed back to a connection that resumed.
+type resumedSession struct {
+	Token      string
+	DeviceID   string
+	DeviceName string
+	Rooms      []string
+	Backlog    []ServerResponse
+	// Missed counts events that did not fit in the backlog.
+	Missed int
+	// Previous is the old connection if it had not been noticed as gone yet.
+	Previous *Client
+}
+
+// SessionStore issues resume tokens and keeps detached sessions for the
+// grace period.
+type SessionStore struct {
+	mu       sync.Mutex
+	byToken  map[string]*session
+	byClient map[*Client]*session
+	grace    time.Duration
+	now      func() time.Time
+}
+
+// NewSessionStore creates an empty store using resumeGracePeriod.
+func NewSessionStore() *SessionStore {
+	return &SessionStore{
+		byToken:  make(map[string]*session),
+		byClient: make(map[*Client]*session),
+		grace:    resumeGracePeriod,
+		now:      time.Now,
+	}
+}
+
+// Issue returns the resume token of the client's session, creating the
+// session on first use. created reports whether the session is new.
+func (st *SessionStore) Issue(client *Client) (token string, created bool, err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if sess, ok := st.byClient[client]; ok {
+		return sess.token, false, nil
+	}
+
+	token, err = newResumeToken()
+	if err != nil {
+		return "", false, err
+	}
+
+	sess := &session{
+		token:      token,
+		deviceID:   client.GetDeviceID(),
+		deviceName: client.GetDeviceName(),
+		client:     client,
+		attached:   true,
+	}
+	st.byToken[token] = sess
+	st.byClient[client] = sess
+	return token, true, nil
+}
+
+// Detach marks the client's session as disconnected and remembers the rooms
+// it had joined. Clients without a session are ignored, as are repeated
+// calls; the result reports whether a session was detached.
+func (st *SessionStore) Detach(client *Client, rooms []string) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	sess, ok := st.byClient[client]
+	if !ok || !sess.attached || sess.client != client {
+		return false
+	}
+	sess.attached = false
+	sess.rooms = rooms
+	sess.detachedAt = st.now()
+	return true
+}
+
+// Buffer stores an event for every detached session whose device deliver
+// accepts. Snapshot messages are skipped: the device gets a fresh one as
+// soon as it rejoins the room. deliver is called without st.mu held, as it
+// typically asks the auth manager.
+func (st *SessionStore) Buffer(resp ServerResponse, deliver func(deviceID string) bool) {
+	if classify(resp.Type) == classSnapshot {
+		return
+	}
+
+	st.mu.Lock()
+	detached := make([]*session, 0)
+	for _, sess := range st.byToken {
+		if !sess.attached {
+			detached = append(detached, sess)
+		}
+	}
+	st.mu.Unlock()
+
+	accepted := detached[:0]
+	for _, sess := range detached {
+		if deliver(sess.deviceID) {
+			accepted = append(accepted, sess)
+		}
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for _, sess := range accepted {
+		if !sess.attached && st.byToken[sess.token] == sess {
+			sess.buffer(resp)
+		}
+	}
+}
+
+// BufferFor stores an event addressed to a client whose connection is gone,
+// such as the result of a long-running command. It reports whether the
+// client had a session to hold it.
+func (st *SessionStore) BufferFor(client *Client, resp ServerResponse) bool {
+	if classify(resp.Type) == classSnapshot {
+		return false
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	sess, ok := st.byClient[client]
+	if !ok {
+		return false
+	}
+	sess.buffer(resp)
+	return true
+}
+
+// Resume hands the session identified by token over to client. The token is
+// rotated: the returned state carries the new token and the old one stops
+// working. A session still bound to a live connection is taken over, which
+// covers a device switching networks before the old socket timed out.
+func (st *SessionStore) Resume(token string, client *Client) (*resumedSession, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	sess, ok := st.byToken[token]
+	if !ok {
+		return nil, errSessionUnknown
+	}
+	if !sess.attached && st.now().Sub(sess.detachedAt) > st.grace {
+		st.remove(sess)
+		return nil, errSessionExpired
+	}
+
+	newToken, err := newResumeToken()
+	if err != nil {
+		return nil, err
+	}
+
+	var previous *Client
+	if sess.attached {
+		previous = sess.client
+	}
+
+	resumed := &resumedSession{
+		Token:      newToken,
+		DeviceID:   sess.deviceID,
+		DeviceName: sess.deviceName,
+		Rooms:      sess.rooms,
+		Backlog:    sess.backlog,
+		Missed:     sess.missed,
+		Previous:   previous,
+	}
+
+	st.remove(sess)
+	sess.token = newToken
+	sess.client = client
+	sess.attached = true
+	sess.rooms = nil
+	sess.backlog = nil
+	sess.missed = 0
+	st.byToken[newToken] = sess
+	st.byClient[client] = sess
+	return resumed, nil
+}
+
+// Discard drops the session bound to client, if any.
+func (st *SessionStore) Discard(client *Client) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if sess, ok := st.byClient[client]; ok {
+		st.remove(sess)
+	}
+}
+
+// PurgeExpired drops detached sessions whose grace period has elapsed.
+func (st *SessionStore) PurgeExpired() int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	now := st.now()
+	purged := 0
+	for _, sess := range st.byToken {
+		if !sess.attached && now.Sub(sess.detachedAt) > st.grace {
+			st.remove(sess)
+			purged++
+		}
+	}
+	return purged
+}
+
+// remove unlinks a session from both indexes. Must be called with st.mu held.
+func (st *SessionStore) remove(sess *session) {
+	delete(st.byToken, sess.token)
+	if st.byClient[sess.client] == sess {
+		delete(st.byClient, sess.client)
+	}
+}
+
+// newResumeToken returns 32 random bytes, hex encoded.
+func newResumeToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
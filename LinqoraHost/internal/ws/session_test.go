@@ -0,0 +1,199 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"LinqoraHost/internal/config"
+)
+
+// connectAuthorized registers a client with the server the way
+// handleWSConnection does and grants it access, returning its resume token.
+func connectAuthorized(t *testing.T, server *WSServer, deviceID string) (*Client, string) {
+	t.Helper()
+	client := NewClient(nil, "127.0.0.1")
+	client.sessions = server.sessions
+	client.onAuthorized = server.onClientAuthorized
+	client.SetDeviceID(deviceID)
+	client.SetDeviceName("Phone")
+	server.clients[client] = true
+
+	client.MarkAuthorized()
+
+	resp := readResponse(t, client)
+	if resp.Type != "session_token" {
+		t.Fatalf("Expected session_token, got %s", resp.Type)
+	}
+	data := resp.Data.(map[string]interface{})
+	return client, data["token"].(string)
+}
+
+func resumeMessage(token string) *ClientMessage {
+	data, _ := json.Marshal(map[string]string{"token": token})
+	return &ClientMessage{ID: "resume-1", Type: "session_resume", Data: data}
+}
+
+func TestSessionResumeRestoresRoomsAndReplaysBacklog(t *testing.T) {
+	server := NewWSServer(config.DefaultConfig(), &MockAuthManager{})
+	old, token := connectAuthorized(t, server, "dev-1")
+	server.roomManager.AddClientToRoom("alerts", old)
+
+	old.Close()
+	old.SendSuccess("script_execute", map[string]int{"exit_code": 0})
+	server.disconnectClient(old)
+
+	server.broadcastToAll("battery_alert", map[string]int{"percent": 10})
+	server.broadcastToAll("metrics", map[string]int{"cpu": 1})
+
+	fresh := NewClient(nil, "127.0.0.1")
+	fresh.sessions = server.sessions
+	server.handleClientMessage(fresh, resumeMessage(token))
+
+	resp := readResponse(t, fresh)
+	if resp.Type != "session_resume" || resp.ID != "resume-1" || resp.Error != nil {
+		t.Fatalf("Expected successful session_resume reply, got %+v", resp)
+	}
+	data := resp.Data.(map[string]interface{})
+	if data["replayed"].(float64) != 2 {
+		t.Errorf("Expected 2 replayed events, got %v", data["replayed"])
+	}
+	if data["token"] == token {
+		t.Error("Expected the resume token to be rotated")
+	}
+
+	for _, want := range []string{"script_execute", "battery_alert"} {
+		if got := readResponse(t, fresh).Type; got != want {
+			t.Errorf("Expected replayed %s, got %s", want, got)
+		}
+	}
+	if _, ok, _ := fresh.queue.pop(); ok {
+		t.Error("Snapshot messages must not be replayed")
+	}
+
+	if fresh.GetDeviceID() != "dev-1" {
+		t.Errorf("Expected device identity to be restored, got %q", fresh.GetDeviceID())
+	}
+	if !server.roomManager.IsClientInRoom("alerts", fresh) {
+		t.Error("Expected room membership to be restored")
+	}
+
+	other := NewClient(nil, "127.0.0.1")
+	server.handleClientMessage(other, resumeMessage(token))
+	if resp := readResponse(t, other); resp.Error == nil || resp.Error.Code == nil || *resp.Error.Code != 401 {
+		t.Errorf("Expected the old token to be rejected, got %+v", resp)
+	}
+}
+
+func TestSessionResumeTakesOverLiveConnection(t *testing.T) {
+	server := NewWSServer(config.DefaultConfig(), &MockAuthManager{})
+	old, token := connectAuthorized(t, server, "dev-1")
+	server.roomManager.AddClientToRoom("alerts", old)
+
+	fresh := NewClient(nil, "127.0.0.1")
+	server.handleClientMessage(fresh, resumeMessage(token))
+
+	if resp := readResponse(t, fresh); resp.Error != nil {
+		t.Fatalf("Expected resume to succeed, got %+v", resp.Error)
+	}
+	if !old.IsClosed() {
+		t.Error("Expected the stale connection to be closed")
+	}
+	if !server.roomManager.IsClientInRoom("alerts", fresh) {
+		t.Error("Expected rooms of the stale connection to move over")
+	}
+}
+
+func TestSessionResumeExpiresAfterGrace(t *testing.T) {
+	store := NewSessionStore()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	client := NewClient(nil, "127.0.0.1")
+	token, _, err := store.Issue(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Detach(client, nil)
+
+	now = now.Add(resumeGracePeriod + time.Second)
+	if _, err := store.Resume(token, NewClient(nil, "127.0.0.1")); err != errSessionExpired {
+		t.Errorf("Expected errSessionExpired, got %v", err)
+	}
+	if _, err := store.Resume(token, NewClient(nil, "127.0.0.1")); err != errSessionUnknown {
+		t.Errorf("Expected an expired session to be forgotten, got %v", err)
+	}
+}
+
+func TestSessionBacklogIsBounded(t *testing.T) {
+	store := NewSessionStore()
+	client := NewClient(nil, "127.0.0.1")
+	token, _, _ := store.Issue(client)
+	store.Detach(client, nil)
+
+	for i := 0; i < sessionBacklogSize+5; i++ {
+		store.Buffer(NewSuccessResponse("battery_alert", i), func(string) bool { return true })
+	}
+
+	resumed, err := store.Resume(token, NewClient(nil, "127.0.0.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resumed.Backlog) != sessionBacklogSize || resumed.Missed != 5 {
+		t.Errorf("Expected %d events and 5 missed, got %d and %d",
+			sessionBacklogSize, len(resumed.Backlog), resumed.Missed)
+	}
+	if first := resumed.Backlog[0].Data.(int); first != 5 {
+		t.Errorf("Expected the oldest events to be discarded, first is %d", first)
+	}
+}
+
+func TestSessionResumeChecksRoomScopes(t *testing.T) {
+	am := &scopedAuthManager{scopes: map[string]bool{config.ScopeMedia: true, config.ScopeClipboard: true}}
+	server := NewWSServer(config.DefaultConfig(), am)
+	old, token := connectAuthorized(t, server, "dev-1")
+	for _, room := range []string{"media", "clipboard", "metrics"} {
+		server.roomManager.AddClientToRoom(room, old)
+	}
+	old.Close()
+	server.disconnectClient(old)
+
+	// The clipboard scope is taken away while the device is offline.
+	delete(am.scopes, config.ScopeClipboard)
+
+	fresh := NewClient(nil, "127.0.0.1")
+	fresh.sessions = server.sessions
+	server.handleClientMessage(fresh, resumeMessage(token))
+	resp := readResponse(t, fresh)
+	if resp.Error != nil {
+		t.Fatalf("Expected the session to resume, got %+v", resp.Error)
+	}
+	if rooms := resp.Data.(map[string]interface{})["rooms"].([]interface{}); len(rooms) != 2 {
+		t.Errorf("Expected two rooms to be restored, got %v", rooms)
+	}
+	if server.roomManager.IsClientInRoom("clipboard", fresh) {
+		t.Error("Expected the clipboard room to be dropped")
+	}
+	if !server.roomManager.IsClientInRoom("media", fresh) || !server.roomManager.IsClientInRoom("metrics", fresh) {
+		t.Error("Expected the rooms still in scope to be restored")
+	}
+}
+
+func TestSessionBacklogSkipsEventsOutsideScopes(t *testing.T) {
+	am := &scopedAuthManager{scopes: map[string]bool{config.ScopeMedia: true}}
+	server := NewWSServer(config.DefaultConfig(), am)
+	old, token := connectAuthorized(t, server, "dev-1")
+	old.Close()
+	server.disconnectClient(old)
+
+	server.broadcastToAll("script_output", map[string]string{"line": "secret"})
+	server.broadcastToAll("battery_alert", map[string]int{"percent": 10})
+
+	resumed, err := server.sessions.Resume(token, NewClient(nil, "127.0.0.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resumed.Backlog) != 1 || resumed.Backlog[0].Type != "battery_alert" {
+		t.Errorf("Expected only battery_alert to be kept for the device, got %+v", resumed.Backlog)
+	}
+}
@@ -0,0 +1,151 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"LinqoraHost/internal/auth"
+	"LinqoraHost/internal/config"
+)
+
+// heldMessage is a sensitive message waiting for its step-up.
+type heldMessage struct {
+	msg     *ClientMessage
+	handler *Handler
+	record  *auditRecord
+}
+
+// steppedUp reports whether the client is within the grace period of a
+// step-up.
+func (c *Client) steppedUp(now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return now.Before(c.stepUpUntil)
+}
+
+// holdStepUp makes held the message waiting for a step-up and returns the
+// one it replaces, if any.
+func (c *Client) holdStepUp(held *heldMessage) *heldMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	previous := c.stepUpHeld
+	c.stepUpHeld = held
+	return previous
+}
+
+// takeStepUp removes and returns the message waiting for a step-up. A
+// successful step-up also starts a grace period of length grace.
+func (c *Client) takeStepUp(passed bool, grace time.Duration) *heldMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	held := c.stepUpHeld
+	c.stepUpHeld = nil
+	if passed {
+		c.stepUpUntil = time.Now().Add(grace)
+	}
+	return held
+}
+
+// needsStepUp reports whether msg must wait for a step-up before it runs.
+func (s *WSServer) needsStepUp(client *Client, msg *ClientMessage, handler *Handler) bool {
+	if !handler.StepUp && !s.Config().RequiresStepUp(msg.Type) {
+		return false
+	}
+	return !client.steppedUp(time.Now())
+}
+
+// holdForStepUp challenges the client and keeps msg until the answer
+// arrives. A client has one message waiting at most; a newer one replaces
+// it.
+func (s *WSServer) holdForStepUp(client *Client, msg *ClientMessage, handler *Handler, record *auditRecord) {
+	token, method, err := s.authManager.StepUpChallenge(client.GetDeviceID())
+	if errors.Is(err, auth.ErrStepUpUnavailable) && handler.StepUp {
+		go s.confirmThenHandle(client, msg, handler, record)
+		return
+	}
+	if err != nil {
+		slog.Warn("Sensitive message refused, step-up unavailable", "device", client.GetDeviceName(), "type", msg.Type, "err", err)
+		client.ReplyError(msg, "Step-up required, but no PIN or shared secret is set", 403)
+		record.end()
+		return
+	}
+
+	if previous := client.holdStepUp(&heldMessage{msg: msg, handler: handler, record: record}); previous != nil {
+		previous.refuse(client, "Replaced by a newer request", 409)
+	}
+	client.ReplySuccess(msg, "step_up_required", map[string]interface{}{
+		"token":          token,
+		"method":         method,
+		"requestType":    msg.Type,
+		"timeoutSeconds": int(auth.StepUpChallengeTTL.Seconds()),
+	})
+}
+
+// refuse answers the held message with an error and closes its audit record.
+func (h *heldMessage) refuse(client *Client, message string, code int) {
+	client.ReplyError(h.msg, message, code)
+	h.record.end()
+}
+
+// handleStepUpResponse checks the answer to a step-up challenge and then
+// runs the message that was waiting for it.
+func (s *WSServer) handleStepUpResponse(client *Client, msg *ClientMessage) {
+	var data struct {
+		Token string `json:"token"`
+		HMAC  string `json:"hmac"`
+	}
+	if err := json.Unmarshal(msg.Data, &data); err != nil {
+		client.ReplyError(msg, "Invalid format", 400)
+		return
+	}
+
+	passed := s.authManager.VerifyStepUp(client, data.Token, data.HMAC)
+	grace := s.Config().StepUpWindow()
+	held := client.takeStepUp(passed, grace)
+	if !passed {
+		client.ReplyError(msg, "Step-up verification failed", 401)
+		if held != nil {
+			held.refuse(client, "Step-up verification failed", 401)
+		}
+		return
+	}
+
+	client.ReplySuccess(msg, "step_up_response", map[string]interface{}{
+		"graceSeconds": int(grace.Seconds()),
+	})
+	if held == nil {
+		return
+	}
+	// The device may have been revoked while the message waited.
+	if !s.authManager.IsAuthorized(client.GetDeviceID()) {
+		held.refuse(client, "Unauthorized access", 401)
+		return
+	}
+	s.runHandler(client, held.msg, held.handler, held.record)
+	held.record.end()
+}
+
+// handleStepUpPIN stores the key the device derives from its user's PIN.
+// The dispatcher has already made the device step up with its current
+// secret, or had the host allow it when it had none.
+func (s *WSServer) handleStepUpPIN(client *Client, msg *ClientMessage) {
+	var data struct {
+		Key string `json:"key"`
+	}
+	if err := json.Unmarshal(msg.Data, &data); err != nil {
+		client.ReplyError(msg, "Invalid format", 400)
+		return
+	}
+	if err := config.ValidateStepUpKey(data.Key); err != nil {
+		client.ReplyError(msg, err.Error(), 400)
+		return
+	}
+	if err := s.authManager.SetStepUpKey(client.GetDeviceID(), data.Key); err != nil {
+		slog.Error("Failed to set step-up PIN", "device", client.GetDeviceName(), "err", err)
+		client.ReplyError(msg, "Failed to set PIN", 500)
+		return
+	}
+	client.ReplySuccess(msg, "step_up_pin", map[string]interface{}{"success": true})
+}
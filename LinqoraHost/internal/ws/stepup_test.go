@@ -0,0 +1,114 @@
+package ws
+
+import (
+	"testing"
+
+	"LinqoraHost/internal/auth"
+	"LinqoraHost/internal/config"
+	"LinqoraHost/internal/interfaces"
+)
+
+// stepUpAuthManager accepts the answer "good" to its step-up challenges,
+// or has no secret to step up with when unavailable is set.
+type stepUpAuthManager struct {
+	MockAuthManager
+	unavailable bool
+}
+
+func (m *stepUpAuthManager) StepUpChallenge(string) (string, string, error) {
+	if m.unavailable {
+		return "", "", auth.ErrStepUpUnavailable
+	}
+	return "tok", auth.StepUpPIN, nil
+}
+
+func (m *stepUpAuthManager) VerifyStepUp(_ interfaces.WSClient, token, response string) bool {
+	return token == "tok" && response == "good"
+}
+
+func stepUpServer(t *testing.T, am interfaces.AuthManagerInterface) (*WSServer, *Client, *int) {
+	t.Helper()
+	cfg := config.DefaultConfig()
+	cfg.StepUpTypes = []string{"sensitive"}
+	server := NewWSServer(cfg, am)
+	ran := new(int)
+	server.Registry().Register(Handler{Type: "sensitive", Handle: func(c *Client, m *ClientMessage) {
+		*ran++
+		c.ReplySuccess(m, "sensitive", nil)
+	}})
+	client, _ := connectAuthorized(t, server, "phone")
+	return server, client, ran
+}
+
+func stepUpAnswer(hmac string) *ClientMessage {
+	return &ClientMessage{ID: "a", Type: "step_up_response", Data: []byte(`{"token":"tok","hmac":"` + hmac + `"}`)}
+}
+
+func TestSensitiveMessageWaitsForStepUp(t *testing.T) {
+	server, client, ran := stepUpServer(t, &stepUpAuthManager{})
+
+	server.handleClientMessage(client, &ClientMessage{ID: "s", Type: "sensitive"})
+	resp := readResponse(t, client)
+	if resp.Type != "step_up_required" || resp.ID != "s" || *ran != 0 {
+		t.Fatalf("Expected a step-up challenge before the handler, got %+v (ran %d)", resp, *ran)
+	}
+	if data := resp.Data.(map[string]interface{}); data["token"] != "tok" || data["method"] != auth.StepUpPIN {
+		t.Errorf("Unexpected challenge %+v", data)
+	}
+
+	server.handleClientMessage(client, stepUpAnswer("good"))
+	if resp := readResponse(t, client); resp.Type != "step_up_response" || resp.Error != nil {
+		t.Fatalf("Expected the step-up to pass, got %+v", resp)
+	}
+	if resp := readResponse(t, client); resp.Type != "sensitive" || resp.ID != "s" || *ran != 1 {
+		t.Fatalf("Expected the held message to run, got %+v (ran %d)", resp, *ran)
+	}
+
+	// Within the grace period the next one runs at once.
+	server.handleClientMessage(client, &ClientMessage{ID: "s2", Type: "sensitive"})
+	if resp := readResponse(t, client); resp.Type != "sensitive" || *ran != 2 {
+		t.Errorf("Expected the grace period to cover the next message, got %+v", resp)
+	}
+}
+
+func TestFailedStepUpDropsHeldMessage(t *testing.T) {
+	server, client, ran := stepUpServer(t, &stepUpAuthManager{})
+
+	server.handleClientMessage(client, &ClientMessage{ID: "s", Type: "sensitive"})
+	readResponse(t, client)
+	server.handleClientMessage(client, stepUpAnswer("bad"))
+	if resp := readResponse(t, client); resp.Error == nil || *resp.Error.Code != 401 || resp.ID != "a" {
+		t.Fatalf("Expected the answer to be refused, got %+v", resp)
+	}
+	if resp := readResponse(t, client); resp.Error == nil || resp.ID != "s" || *ran != 0 {
+		t.Fatalf("Expected the held message to be refused, got %+v (ran %d)", resp, *ran)
+	}
+
+	// The refused message is gone: a correct answer now runs nothing.
+	server.handleClientMessage(client, stepUpAnswer("good"))
+	readResponse(t, client)
+	if _, ok, _ := client.queue.pop(); ok || *ran != 0 {
+		t.Error("A refused message ran after a later step-up")
+	}
+}
+
+func TestStepUpUnavailable(t *testing.T) {
+	server, client, ran := stepUpServer(t, &stepUpAuthManager{unavailable: true})
+
+	server.handleClientMessage(client, &ClientMessage{ID: "s", Type: "sensitive"})
+	if resp := readResponse(t, client); resp.Error == nil || *resp.Error.Code != 403 || *ran != 0 {
+		t.Fatalf("Expected a sensitive message to be refused without secrets, got %+v", resp)
+	}
+
+	// A first PIN is put to the host instead.
+	server.handleClientMessage(client, &ClientMessage{ID: "p", Type: "step_up_pin", Data: []byte(`{"key":"00"}`)})
+	req := <-server.Confirmations().Requests()
+	if req.Action != config.ConfirmStepUpPIN {
+		t.Fatalf("Expected the host to be asked, got %+v", req)
+	}
+	readResponse(t, client)
+	server.Confirmations().Respond(req.ID, false)
+	if resp := awaitResponse(t, client); resp.Error == nil || *resp.Error.Code != 403 {
+		t.Errorf("Expected the denied PIN to fail, got %+v", resp)
+	}
+}